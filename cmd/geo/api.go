@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/app/incidents"
+	outboxapp "github.com/m1ll3r1337/geo-notifications-service/internal/app/outbox"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/app/webhooks"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/http"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/http/handlers"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/breaker"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/config"
+	incidentsdb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/incidents"
+	listendb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/listen"
+	outboxdb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/outbox"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/txrunner"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/uow"
+	webhooksdb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/webhooks"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/logger"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/metrics"
+	incidentscache "github.com/m1ll3r1337/geo-notifications-service/internal/platform/redis/cache"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/secretbox"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/spatial"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/urlpolicy"
+)
+
+// newIncidentsCache wraps the incidents repository with the Redis cache, or
+// returns nil when the process has no cache.
+func newIncidentsCache(cfg config.Config, log *logger.Logger, in *infra, m *metrics.Registry) *incidentscache.CachedRepository {
+	if in.cacheRdb == nil {
+		return nil
+	}
+	return incidentscache.New(
+		in.cacheRdb,
+		incidentsdb.New(in.db),
+		incidentscache.WithTTL(time.Duration(cfg.Cache.ActiveIncidentsTTLSeconds)*time.Second),
+		incidentscache.WithTimeout(cfg.Cache.Timeout),
+		incidentscache.WithBreaker(breaker.Config{
+			FailureThreshold: cfg.Cache.BreakerFailureThreshold,
+			OpenTimeout:      cfg.Cache.BreakerOpenTimeout,
+		}),
+		incidentscache.WithCells(cfg.Cache.CellPrecision, cfg.Cache.CellTTL),
+		incidentscache.WithCellInvalidateLimit(cfg.Cache.CellInvalidateLimit),
+		incidentscache.WithLogger(log),
+		incidentscache.WithMetrics(m),
+	)
+}
+
+// newIncidentsIndex builds the in-memory spatial index of active incidents
+// and the runners that keep it fresh: it reloads whenever an incident write
+// is announced on the incidents NOTIFY channel.
+func newIncidentsIndex(cfg config.Config, log *logger.Logger, in *infra, m *metrics.Registry) (*spatial.Index, []func(context.Context) error) {
+	listener := listendb.New(cfg.DB.URL, incidentsdb.NotifyChannel, log)
+	index := spatial.New(
+		incidentsdb.New(in.db),
+		log,
+		spatial.WithPollInterval(cfg.Index.PollInterval),
+		spatial.WithWakeup(listener, cfg.Index.SafetyInterval),
+		spatial.WithMaxAge(cfg.Index.MaxAge),
+		spatial.WithVerify(cfg.Index.VerifyRate),
+		spatial.WithMetrics(m),
+	)
+	return index, []func(context.Context) error{listener.Run, index.Run}
+}
+
+// newAPIRouter wires the HTTP API. cache is nil without the incidents cache
+// and index without the spatial index.
+func newAPIRouter(cfg config.Config, log *logger.Logger, logLevel logger.Level, in *infra, m *metrics.Registry, cache *incidentscache.CachedRepository, index *spatial.Index, sysHandler *handlers.System) (*gin.Engine, error) {
+	// --- Incidents module wiring ---
+	var (
+		incRepo    incidents.IncidentsRepository = incidentsdb.New(in.db)
+		incSvcOpts []incidents.Option
+	)
+	if cache != nil {
+		incRepo = cache
+		incSvcOpts = append(incSvcOpts, incidents.WithInvalidator(cache))
+	}
+	if index != nil {
+		incSvcOpts = append(incSvcOpts, incidents.WithNearbyFinder(index))
+	}
+	incEow := uow.New(in.db)
+	incTxRunner := txrunner.NewIncidentsTxRunner(incEow)
+	incSvc := incidents.NewService(incRepo, incTxRunner, incSvcOpts...)
+	incHandlers := handlers.NewIncidents(incSvc, time.Duration(cfg.Stats.TimeWindowMinutes)*time.Minute)
+
+	// --- Webhooks module wiring ---
+	urlPolicy, err := webhookURLPolicy(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook url policy: %w", err)
+	}
+
+	secrets, err := secretbox.New(cfg.Security.SecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key: %w", err)
+	}
+	subsRepo := webhooksdb.New(in.db, webhooksdb.WithSecrets(secrets), webhooksdb.WithLogger(log))
+	deliveriesRepo := webhooksdb.NewDeliveries(in.db)
+	whSvc := webhooks.NewService(subsRepo, deliveriesRepo, webhooks.WithURLPolicy(urlPolicy))
+	whHandlers := handlers.NewWebhooks(whSvc)
+
+	// --- Outbox admin ---
+	outboxSvc := outboxapp.NewService(outboxdb.New(in.db))
+	outboxHandlers := handlers.NewOutbox(outboxSvc)
+
+	return http.NewRouter(log, logLevel, m, incHandlers, whHandlers, outboxHandlers, sysHandler, cfg.Security.ApiKey), nil
+}
+
+func webhookURLPolicy(cfg config.Config) (urlpolicy.Policy, error) {
+	allow, err := urlpolicy.ParsePrefixes(cfg.Workers.Webhook.URLAllowCIDRs)
+	if err != nil {
+		return urlpolicy.Policy{}, err
+	}
+	deny, err := urlpolicy.ParsePrefixes(cfg.Workers.Webhook.URLDenyCIDRs)
+	if err != nil {
+		return urlpolicy.Policy{}, err
+	}
+	return urlpolicy.Policy{Allow: allow, Deny: deny, RequireHTTPS: cfg.Production()}, nil
+}
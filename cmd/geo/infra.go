@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/http/handlers"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/config"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/db"
+	healthdb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/health"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/heartbeat"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/leader"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/logger"
+	incidentscache "github.com/m1ll3r1337/geo-notifications-service/internal/platform/redis/cache"
+	healthredis "github.com/m1ll3r1337/geo-notifications-service/internal/platform/redis/health"
+	redisqueue "github.com/m1ll3r1337/geo-notifications-service/internal/platform/redis/queue"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/spatial"
+)
+
+// infra holds the connections a process needs. Redis clients are nil when
+// the process does not use them.
+type infra struct {
+	db *sqlx.DB
+	// cacheRdb backs the incidents cache, used by the API only.
+	cacheRdb *redis.Client
+	// queueRdb backs the redis queue backend, used by the relay and the
+	// webhook worker.
+	queueRdb *redis.Client
+}
+
+func openInfra(ctx context.Context, cfg config.Config, log *logger.Logger, r role) (*infra, error) {
+	in := &infra{}
+
+	// --- DB ---
+	sqlDB, err := db.Open(ctx, db.Config{
+		URL:             cfg.DB.URL,
+		MaxIdleConns:    cfg.DB.MaxIdleConns,
+		MaxOpenConns:    cfg.DB.MaxOpenConns,
+		ConnMaxLifetime: cfg.DB.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DB.ConnMaxIdleTime,
+		PingTimeout:     cfg.DB.PingTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("db init: %w", err)
+	}
+	in.db = sqlDB
+
+	if err := db.StatusCheck(ctx, sqlDB); err != nil {
+		in.Close()
+		return nil, fmt.Errorf("db not ready: %w", err)
+	}
+
+	// --- Redis ---
+	// Redis backs the incidents cache and the redis queue backend; with both
+	// turned off the service runs on Postgres alone.
+	if r.api && cfg.Cache.Enabled {
+		// The cache fails open, so the API starts without it and uses it
+		// once Redis comes up.
+		in.cacheRdb = redis.NewClient(&redis.Options{
+			Addr:                  cfg.Redis.Addr,
+			Password:              cfg.Redis.Password,
+			DB:                    cfg.Redis.CacheDB,
+			ContextTimeoutEnabled: true,
+		})
+		if err := in.cacheRdb.Ping(ctx).Err(); err != nil {
+			log.Error(ctx, "startup", "status", "redis cache unavailable, starting without cache", "error", err)
+		}
+	}
+
+	if (r.relay || r.worker) && cfg.Queue.Backend == queueBackendRedis {
+		in.queueRdb = redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.QueueDB,
+		})
+		if err := in.queueRdb.Ping(ctx).Err(); err != nil {
+			in.Close()
+			return nil, fmt.Errorf("redis queue init: %w", err)
+		}
+	}
+
+	log.Info(ctx, "startup", "status", "connections ready", "redis_cache", in.cacheRdb != nil, "redis_queue", in.queueRdb != nil)
+	return in, nil
+}
+
+func (in *infra) Close() {
+	if in.queueRdb != nil {
+		_ = in.queueRdb.Close()
+	}
+	if in.cacheRdb != nil {
+		_ = in.cacheRdb.Close()
+	}
+	if in.db != nil {
+		_ = in.db.Close()
+	}
+}
+
+// heartbeats are the loops checked by the liveness probe; nil for loops the
+// process does not run.
+type heartbeats struct {
+	relay  *heartbeat.Heartbeat
+	worker *heartbeat.Heartbeat
+}
+
+func newHeartbeats(cfg config.Config, r role) heartbeats {
+	var hb heartbeats
+	if r.relay {
+		hb.relay = heartbeat.New("outbox_relay", cfg.Health.HeartbeatTimeout)
+	}
+	if r.worker {
+		hb.worker = heartbeat.New("webhook_worker", cfg.Health.HeartbeatTimeout)
+	}
+	return hb
+}
+
+// healthDeps lists the dependencies the process uses. Postgres and the
+// queue are critical; without the cache or the spatial index the API only
+// gets slower, and the leader lease and outbox backlog are reported for
+// information. monitor, when set, adds consumer group lag to the queue's
+// status, cache whether it is bypassed, and elector reports the current
+// leader.
+func (in *infra) healthDeps(cfg config.Config, monitor *redisqueue.Monitor, cache *incidentscache.CachedRepository, index *spatial.Index, elector *leader.Elector, hb heartbeats) []handlers.Dependency {
+	deps := []handlers.Dependency{{
+		Name:     "postgres",
+		Pinger:   healthdb.NewPostgresPinger(in.db),
+		Critical: true,
+	}}
+	if in.queueRdb != nil {
+		dep := handlers.Dependency{
+			Name:     "redis_queue",
+			Pinger:   healthredis.NewRedisPinger(in.queueRdb),
+			Critical: true,
+		}
+		if monitor != nil {
+			dep.Reporter = monitor
+		}
+		deps = append(deps, dep)
+	}
+	if in.cacheRdb != nil {
+		dep := handlers.Dependency{
+			Name:   "redis_cache",
+			Pinger: healthredis.NewRedisPinger(in.cacheRdb),
+		}
+		if cache != nil {
+			dep.Reporter = cache
+		}
+		deps = append(deps, dep)
+	}
+	if elector != nil {
+		deps = append(deps, handlers.Dependency{
+			Name:     "leader",
+			Pinger:   elector,
+			Reporter: elector,
+		})
+	}
+	if hb.relay != nil {
+		deps = append(deps,
+			handlers.Dependency{Name: "outbox_relay", Pinger: hb.relay, Reporter: hb.relay, Live: true},
+			handlers.Dependency{
+				Name:     "outbox_backlog",
+				Reporter: healthdb.NewOutboxBacklog(in.db, cfg.Health.OutboxMaxPending, cfg.Health.OutboxMaxAge),
+			},
+		)
+	}
+	if index != nil {
+		deps = append(deps, handlers.Dependency{Name: "spatial_index", Reporter: index})
+	}
+	if hb.worker != nil {
+		deps = append(deps, handlers.Dependency{Name: "webhook_worker", Pinger: hb.worker, Reporter: hb.worker, Live: true})
+	}
+	return deps
+}
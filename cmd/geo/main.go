@@ -0,0 +1,230 @@
+// Command geo runs the service. The first argument selects what the process
+// runs, so the API can be scaled apart from the workers:
+//
+//	api             HTTP API
+//	relay           outbox relay, queue trimming and retention cleanup
+//	webhook-worker  webhook delivery
+//	all             all of the above in one process (the default)
+//
+// Every process serves the probes GET /livez and GET /readyz (and the older
+// GET /api/v1/health) on GEO_HTTP_ADDR, checking only the dependencies it
+// uses, and its Prometheus metrics on GET /metrics.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/http"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/http/handlers"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/config"
+	leaderdb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/leader"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/leader"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/logger"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/metrics"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/spatial"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/tracing"
+)
+
+// role is the set of components a process runs.
+type role struct {
+	api    bool
+	relay  bool
+	worker bool
+}
+
+var roles = map[string]role{
+	"api":            {api: true},
+	"relay":          {relay: true},
+	"webhook-worker": {worker: true},
+	"all":            {api: true, relay: true, worker: true},
+}
+
+func main() {
+	name := "all"
+	if len(os.Args) > 1 {
+		name = os.Args[1]
+	}
+	r, ok := roles[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\nusage: geo [api|relay|webhook-worker|all]\n", name)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading config:", err)
+		os.Exit(1)
+	}
+
+	logLevel := logger.ParseLogLevel(cfg.Log.Level)
+	log := logger.New(os.Stdout, logLevel, "GEO")
+
+	ctx := context.Background()
+	log.Info(ctx, "startup", "command", name, "GOMAXPROCS", runtime.GOMAXPROCS(0))
+	log.BuildInfo(ctx)
+
+	if err := run(ctx, cfg, log, logLevel, r); err != nil {
+		log.Error(ctx, "startup", "status", "failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, cfg config.Config, log *logger.Logger, logLevel logger.Level, r role) error {
+	if cfg.Queue.Backend == queueBackendMemory && !(r.relay && r.worker) {
+		return fmt.Errorf("queue backend %q needs the relay and the webhook worker in one process", queueBackendMemory)
+	}
+
+	if cfg.Tracing.Enabled {
+		shutdown, err := tracing.Setup(ctx, tracing.Config{
+			ServiceName: cfg.Tracing.ServiceName,
+			Endpoint:    cfg.Tracing.Endpoint,
+			Insecure:    cfg.Tracing.Insecure,
+			SampleRatio: cfg.Tracing.SampleRatio,
+		})
+		if err != nil {
+			return fmt.Errorf("tracing: %w", err)
+		}
+		defer func() {
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			defer cancel()
+			if err := shutdown(flushCtx); err != nil {
+				log.Error(ctx, "shutdown", "status", "trace export flush failed", "error", err)
+			}
+		}()
+	}
+
+	infra, err := openInfra(ctx, cfg, log, r)
+	if err != nil {
+		return err
+	}
+	defer infra.Close()
+
+	m := metrics.New()
+	m.RegisterDB(infra.db.DB, "postgres")
+
+	q, err := newQueue(cfg, infra, log)
+	if err != nil {
+		return fmt.Errorf("queue: %w", err)
+	}
+
+	var elector *leader.Elector
+	if r.relay && cfg.Leader.Enabled {
+		elector = leader.New(
+			leaderdb.New(infra.db),
+			"relay",
+			log,
+			leader.WithTTL(cfg.Leader.TTL),
+			leader.WithHolder(cfg.Leader.Holder),
+		)
+	}
+
+	cache := newIncidentsCache(cfg, log, infra, m)
+
+	var (
+		index        *spatial.Index
+		indexRunners []func(context.Context) error
+	)
+	if r.api && cfg.Index.Enabled {
+		index, indexRunners = newIncidentsIndex(cfg, log, infra, m)
+	}
+
+	hb := newHeartbeats(cfg, r)
+	sysHandler := handlers.NewSystem(log, infra.healthDeps(cfg, q.monitor, cache, index, elector, hb),
+		handlers.WithCheckTimeout(cfg.Health.CheckTimeout),
+		handlers.WithCacheTTL(cfg.Health.CacheTTL),
+	)
+
+	// --- HTTP ---
+	router := http.NewHealthRouter(log, logLevel, m, sysHandler)
+	if r.api {
+		router, err = newAPIRouter(cfg, log, logLevel, infra, m, cache, index, sysHandler)
+		if err != nil {
+			return err
+		}
+	}
+	s := http.NewServer(http.Config{Addr: cfg.HTTP.Addr}, router, logger.NewStdLogger(log, logger.LevelError))
+
+	serverErrors := make(chan error, 1)
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+
+	go func() {
+		log.Info(ctx, "startup", "status", "server started", "addr", cfg.HTTP.Addr)
+		serverErrors <- s.Start()
+	}()
+
+	// --- Workers ---
+	workerCtx, workerCancel := context.WithCancel(ctx)
+	defer workerCancel()
+
+	runners := indexRunners
+	if r.relay {
+		rs, err := newRelayRunners(cfg, log, infra, q, elector, m, hb.relay)
+		if err != nil {
+			return err
+		}
+		runners = append(runners, rs...)
+	}
+	if r.worker {
+		rs, err := newWorkerRunners(cfg, log, infra, q, m, hb.worker)
+		if err != nil {
+			return err
+		}
+		runners = append(runners, rs...)
+	}
+
+	g, gctx := errgroup.WithContext(workerCtx)
+	for _, run := range runners {
+		g.Go(func() error { return run(gctx) })
+	}
+
+	stopServer := func() {
+		shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			log.Error(ctx, "could not stop server gracefully", "error", err)
+			_ = s.Close()
+		}
+	}
+
+	workerFailed := false
+	select {
+	case err := <-serverErrors:
+		log.Error(ctx, "startup", "status", "server failed", "error", err)
+
+	case <-gctx.Done():
+		// A runner failed and the group stopped the others. Serving on
+		// without them would answer from a stale index or stop delivery
+		// without anything showing it, so the process goes down too.
+		workerFailed = true
+		log.Error(ctx, "shutdown", "status", "worker failed, shutting down")
+		workerCancel()
+		stopServer()
+
+	case sig := <-shutdown:
+		log.Info(ctx, "shutdown", "status", "shutdown started", "signal", sig)
+		defer log.Info(ctx, "shutdown", "status", "shutdown complete", "signal", sig)
+
+		workerCancel()
+		stopServer()
+	}
+
+	workerCancel()
+	switch err := g.Wait(); {
+	case workerFailed:
+		return fmt.Errorf("worker: %w", err)
+	case err != nil && !errors.Is(err, context.Canceled):
+		log.Error(ctx, "shutdown", "status", "workers failed to stop gracefully", "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/config"
+	listendb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/listen"
+	queuedb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/queue"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/logger"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
+	redisqueue "github.com/m1ll3r1337/geo-notifications-service/internal/platform/redis/queue"
+)
+
+const (
+	queueBackendRedis    = "redis"
+	queueBackendPostgres = "postgres"
+	queueBackendMemory   = "memory"
+)
+
+// eventQueue carries dispatched outbox events to the webhook worker. listener
+// is set for backends whose consumer needs its LISTEN connection run, and
+// monitor for backends that need trimming. All fields are nil in processes
+// that run neither the relay nor the worker.
+type eventQueue struct {
+	publisher queue.Publisher
+	consumer  queue.Consumer
+	listener  *listendb.Listener
+	monitor   *redisqueue.Monitor
+}
+
+func newQueue(cfg config.Config, in *infra, log *logger.Logger) (eventQueue, error) {
+	redelivery := queue.Redelivery{
+		Visibility:    cfg.Workers.Webhook.ReclaimIdle,
+		MaxDeliveries: cfg.Workers.Webhook.MaxDeliveries,
+	}
+
+	switch cfg.Queue.Backend {
+	case queueBackendRedis:
+		if in.queueRdb == nil {
+			return eventQueue{}, nil
+		}
+		return eventQueue{
+			publisher: redisqueue.NewPublisher(in.queueRdb, cfg.Workers.OutboxRelay.Stream, cfg.Queue.Partitions),
+			consumer: redisqueue.NewConsumer(
+				in.queueRdb,
+				cfg.Workers.Webhook.Stream,
+				cfg.Workers.Webhook.Group,
+				cfg.Workers.Webhook.Consumer,
+				redelivery,
+				log,
+				redisqueue.WithPartitions(cfg.Queue.Partitions),
+				redisqueue.WithLease(cfg.Queue.PartitionLease),
+			),
+			monitor: redisqueue.NewMonitor(
+				in.queueRdb,
+				cfg.Workers.Webhook.Stream,
+				cfg.Workers.Webhook.Group,
+				cfg.Queue.Partitions,
+				log,
+				redisqueue.WithTrimInterval(cfg.Queue.TrimInterval),
+				redisqueue.WithMaxBacklog(cfg.Queue.MaxBacklog),
+			),
+		}, nil
+
+	case queueBackendPostgres:
+		listener := listendb.New(cfg.DB.URL, queuedb.NotifyChannel, log)
+		q := queuedb.New(in.db, redelivery, log, queuedb.WithWakeup(listener))
+		return eventQueue{publisher: q, consumer: q, listener: listener}, nil
+
+	case queueBackendMemory:
+		q := queue.NewMemory(redelivery, 0, log)
+		return eventQueue{publisher: q, consumer: q}, nil
+	}
+	return eventQueue{}, fmt.Errorf("unknown queue backend %q", cfg.Queue.Backend)
+}
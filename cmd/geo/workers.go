@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/breaker"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/config"
+	leaderdb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/leader"
+	listendb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/listen"
+	outboxdb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/outbox"
+	retentiondb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/retention"
+	webhooksdb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/webhooks"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/heartbeat"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/leader"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/logger"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/metrics"
+	deduperedis "github.com/m1ll3r1337/geo-notifications-service/internal/platform/redis/dedupe"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/secretbox"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/workers/cleanup"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/workers/outboxrelay"
+	webhookworker "github.com/m1ll3r1337/geo-notifications-service/internal/workers/webhook"
+)
+
+// outboxScrapeTimeout bounds the outbox backlog query run on every scrape.
+const outboxScrapeTimeout = 5 * time.Second
+
+// newRelayRunners wires the outbox relay and the maintenance that goes with
+// it: stream trimming and retention cleanup. With an elector they run only
+// while this instance leads. The outbox backlog is exported on every relay
+// instance, leader or not.
+func newRelayRunners(cfg config.Config, log *logger.Logger, in *infra, q eventQueue, elector *leader.Elector, m *metrics.Registry, hb *heartbeat.Heartbeat) ([]func(context.Context) error, error) {
+	if q.publisher == nil {
+		return nil, fmt.Errorf("no queue publisher for backend %q", cfg.Queue.Backend)
+	}
+
+	m.Register(metrics.NewOutboxCollector(outboxdb.New(in.db), outboxScrapeTimeout))
+
+	outboxListener := listendb.New(cfg.DB.URL, outboxdb.NotifyChannel, log)
+	relayOpts := []outboxrelay.Option{
+		outboxrelay.WithPollInterval(cfg.Workers.OutboxRelay.PollInterval),
+		outboxrelay.WithWakeup(outboxListener, cfg.Workers.OutboxRelay.SafetyInterval),
+		outboxrelay.WithMetrics(m),
+		outboxrelay.WithHeartbeat(hb),
+	}
+	if elector != nil {
+		relayOpts = append(relayOpts, outboxrelay.WithFence(leaderdb.NewFence(elector.Name(), elector.Holder(), elector.Token)))
+	}
+	outboxRelay := outboxrelay.New(in.db, q.publisher, log, relayOpts...)
+
+	cleanupCfg := cfg.Workers.Cleanup
+	cleanupWorker := cleanup.New(
+		retentiondb.New(in.db),
+		cleanup.Retention{
+			OutboxDispatched: cleanupCfg.OutboxDispatchedRetention,
+			OutboxDead:       cleanupCfg.OutboxDeadRetention,
+			Deliveries:       cleanupCfg.DeliveriesRetention,
+			LocationChecks:   cleanupCfg.LocationChecksRetention,
+		},
+		log,
+		cleanup.WithInterval(cleanupCfg.Interval),
+		cleanup.WithBatch(cleanupCfg.BatchSize, cleanupCfg.BatchPause),
+		cleanup.WithPartitionsAhead(cleanupCfg.PartitionsAhead),
+	)
+
+	singletons := []leader.Job{outboxRelay.Run, cleanupWorker.Run}
+	if q.monitor != nil {
+		singletons = append(singletons, q.monitor.Run)
+	}
+
+	runners := []func(context.Context) error{outboxListener.Run}
+	if elector == nil {
+		for _, job := range singletons {
+			runners = append(runners, job)
+		}
+		return runners, nil
+	}
+	return append(runners, func(ctx context.Context) error {
+		return elector.Run(ctx, singletons...)
+	}), nil
+}
+
+// newWorkerRunners wires the webhook delivery worker.
+func newWorkerRunners(cfg config.Config, log *logger.Logger, in *infra, q eventQueue, m *metrics.Registry, hb *heartbeat.Heartbeat) ([]func(context.Context) error, error) {
+	if q.consumer == nil {
+		return nil, fmt.Errorf("no queue consumer for backend %q", cfg.Queue.Backend)
+	}
+
+	contentMode, err := events.ParseMode(cfg.Workers.Webhook.ContentMode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook content mode: %w", err)
+	}
+
+	urlPolicy, err := webhookURLPolicy(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook url policy: %w", err)
+	}
+
+	secrets, err := secretbox.New(cfg.Security.SecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key: %w", err)
+	}
+	subsRepo := webhooksdb.New(in.db, webhooksdb.WithSecrets(secrets), webhooksdb.WithLogger(log))
+	deliveriesRepo := webhooksdb.NewDeliveries(in.db)
+
+	var dedupe webhookworker.Dedupe = deliveriesRepo
+	if in.queueRdb != nil {
+		dedupe = deduperedis.New(in.queueRdb, 24*time.Hour)
+	}
+
+	webhookWorker := webhookworker.New(
+		q.consumer,
+		cfg.Workers.Webhook.URL,
+		log,
+		webhookworker.WithTimeout(cfg.Workers.Webhook.Timeout),
+		webhookworker.WithTLSFiles(webhookworker.TLSFiles{
+			CAFile:   cfg.Workers.Webhook.TLSCAFile,
+			CertFile: cfg.Workers.Webhook.TLSCertFile,
+			KeyFile:  cfg.Workers.Webhook.TLSKeyFile,
+		}),
+		webhookworker.WithContentMode(contentMode),
+		webhookworker.WithSubscriptions(subsRepo, cfg.Workers.Webhook.SubscriptionsRefresh),
+		webhookworker.WithDeliveries(deliveriesRepo),
+		webhookworker.WithDedupe(dedupe),
+		webhookworker.WithVerification(subsRepo, cfg.Workers.Webhook.VerifyInterval, cfg.Workers.Webhook.ReverifyAfter),
+		webhookworker.WithURLPolicy(urlPolicy),
+		webhookworker.WithConcurrency(cfg.Workers.Webhook.Concurrency),
+		webhookworker.WithDrainTimeout(cfg.Workers.Webhook.DrainTimeout),
+		webhookworker.WithMetrics(m),
+		webhookworker.WithHeartbeat(hb),
+		webhookworker.WithDestinationLimits(webhookworker.DestinationLimits{
+			Concurrency:    cfg.Workers.Webhook.DestinationConcurrency,
+			RPS:            cfg.Workers.Webhook.DestinationRPS,
+			Burst:          cfg.Workers.Webhook.DestinationBurst,
+			AcquireTimeout: cfg.Workers.Webhook.DestinationAcquireTimeout,
+			Breaker: breaker.Config{
+				FailureThreshold: cfg.Workers.Webhook.BreakerFailureThreshold,
+				OpenTimeout:      cfg.Workers.Webhook.BreakerOpenTimeout,
+				HalfOpenMaxCalls: cfg.Workers.Webhook.BreakerHalfOpenMaxCalls,
+			},
+		}),
+	)
+
+	runners := []func(context.Context) error{webhookWorker.Run}
+	if q.listener != nil {
+		runners = append(runners, q.listener.Run)
+	}
+	return runners, nil
+}
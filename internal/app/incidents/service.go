@@ -2,45 +2,92 @@ package incidents
 
 import (
 	"context"
-	"encoding/json"
+	"strconv"
 	"time"
 
 	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
 	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
 )
 
+// IncidentsRepository serves reads; writes go through IncidentsWriter inside
+// a transaction so that they are recorded in the outbox atomically.
 type IncidentsRepository interface {
-	Create(ctx context.Context, in incidents.CreateIncident) (incidents.Incident, error)
 	GetByID(ctx context.Context, id int64) (incidents.Incident, error)
 	List(ctx context.Context, f incidents.ListFilter) ([]incidents.Incident, error)
-	Update(ctx context.Context, id int64, in incidents.UpdateIncident) (incidents.Incident, error)
-	Deactivate(ctx context.Context, id int64) error
 	FindNearby(ctx context.Context, p incidents.Point, limit int) ([]incidents.NearbyIncident, error)
 	CountUniqueUsersSince(ctx context.Context, since time.Time) (int, error)
 }
 
+type IncidentsWriter interface {
+	Create(ctx context.Context, in incidents.CreateIncident) (incidents.Incident, error)
+	GetByIDForUpdate(ctx context.Context, id int64) (incidents.Incident, error)
+	Update(ctx context.Context, id int64, in incidents.UpdateIncident) (incidents.Incident, error)
+	Deactivate(ctx context.Context, id int64) error
+}
+
 type Checker interface {
 	RecordCheck(ctx context.Context, userID string, p incidents.Point, incidentIDs []int64) (int64, error)
 }
 
 type OutboxRepository interface {
-	Enqueue(ctx context.Context, eventType string, payloadJSON string) error
+	// Enqueue stores ev for delivery. Events with the same orderingKey are
+	// delivered in the order they were enqueued.
+	Enqueue(ctx context.Context, ev events.Envelope, orderingKey string) error
+}
+
+// TxRepos are the repositories bound to a single transaction.
+type TxRepos struct {
+	Incidents IncidentsWriter
+	Checker   Checker
+	Outbox    OutboxRepository
 }
 
 type TxRunner interface {
-	WithinTx(ctx context.Context, fn func(ctx context.Context, checker Checker, outbox OutboxRepository) error) error
+	WithinTx(ctx context.Context, fn func(ctx context.Context, repos TxRepos) error) error
+}
+
+// Invalidator is notified after a write to the incident set has committed,
+// with the incidents the write changed as they were before and after it.
+type Invalidator interface {
+	Invalidate(ctx context.Context, changed ...incidents.Incident)
+}
+
+// NearbyFinder matches a location against the active incidents.
+type NearbyFinder interface {
+	FindNearby(ctx context.Context, p incidents.Point, limit int) ([]incidents.NearbyIncident, error)
+}
+
+type Option func(*Service)
+
+// WithInvalidator registers inv to be called after every committed write.
+func WithInvalidator(inv Invalidator) Option {
+	return func(s *Service) { s.invalidators = append(s.invalidators, inv) }
+}
+
+// WithNearbyFinder matches location checks with f instead of the
+// repository.
+func WithNearbyFinder(f NearbyFinder) Option {
+	return func(s *Service) { s.nearby = f }
 }
 
 type Service struct {
-	incRepo IncidentsRepository
-	tx      TxRunner
+	incRepo      IncidentsRepository
+	nearby       NearbyFinder
+	tx           TxRunner
+	invalidators []Invalidator
 }
 
-func NewService(incRepo IncidentsRepository, tx TxRunner) *Service {
-	return &Service{
+func NewService(incRepo IncidentsRepository, tx TxRunner, opts ...Option) *Service {
+	s := &Service{
 		incRepo: incRepo,
+		nearby:  incRepo,
 		tx:      tx,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Service) Create(ctx context.Context, cmd incidents.CreateIncident) (incidents.Incident, error) {
@@ -50,11 +97,23 @@ func (s *Service) Create(ctx context.Context, cmd incidents.CreateIncident) (inc
 		return incidents.Incident{}, errs.Wrap(op, err)
 	}
 
-	inc, err := s.incRepo.Create(ctx, cmd)
+	var inc incidents.Incident
+	err := s.tx.WithinTx(ctx, func(ctx context.Context, repos TxRepos) error {
+		var err error
+		inc, err = repos.Incidents.Create(ctx, cmd)
+		if err != nil {
+			return err
+		}
+
+		return enqueueIncidentEvent(ctx, repos.Outbox, incidents.EventTypeIncidentCreated, inc, incidents.IncidentCreated{
+			Incident: incidents.NewIncidentData(inc),
+		})
+	})
 	if err != nil {
 		return incidents.Incident{}, errs.Wrap(op, err)
 	}
 
+	s.invalidate(ctx, inc)
 	return inc, nil
 }
 
@@ -101,11 +160,34 @@ func (s *Service) Update(ctx context.Context, id int64, cmd incidents.UpdateInci
 		return incidents.Incident{}, errs.Wrap(op, err)
 	}
 
-	inc, err := s.incRepo.Update(ctx, id, cmd)
+	var before, inc incidents.Incident
+	err := s.tx.WithinTx(ctx, func(ctx context.Context, repos TxRepos) error {
+		var err error
+		before, err = repos.Incidents.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		inc, err = repos.Incidents.Update(ctx, id, cmd)
+		if err != nil {
+			return err
+		}
+
+		changes := incidents.Diff(before, inc)
+		if len(changes) == 0 {
+			return nil
+		}
+
+		return enqueueIncidentEvent(ctx, repos.Outbox, incidents.EventTypeIncidentUpdated, inc, incidents.IncidentUpdated{
+			Incident: incidents.NewIncidentData(inc),
+			Changes:  changes,
+		})
+	})
 	if err != nil {
 		return incidents.Incident{}, errs.Wrap(op, err)
 	}
 
+	s.invalidate(ctx, before, inc)
 	return inc, nil
 }
 
@@ -116,13 +198,64 @@ func (s *Service) Deactivate(ctx context.Context, id int64) error {
 		return errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, nil)
 	}
 
-	if err := s.incRepo.Deactivate(ctx, id); err != nil {
+	var inc incidents.Incident
+	err := s.tx.WithinTx(ctx, func(ctx context.Context, repos TxRepos) error {
+		var err error
+		inc, err = repos.Incidents.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := repos.Incidents.Deactivate(ctx, id); err != nil {
+			return err
+		}
+
+		inc.Active = false
+		inc.UpdatedAt = time.Now().UTC()
+		return enqueueIncidentEvent(ctx, repos.Outbox, incidents.EventTypeIncidentDeactivated, inc, incidents.IncidentDeactivated{
+			Incident: incidents.NewIncidentData(inc),
+		})
+	})
+	if err != nil {
 		return errs.Wrap(op, err)
 	}
 
+	s.invalidate(ctx, inc)
 	return nil
 }
 
+func (s *Service) invalidate(ctx context.Context, changed ...incidents.Incident) {
+	for _, inv := range s.invalidators {
+		inv.Invalidate(ctx, changed...)
+	}
+}
+
+func enqueueIncidentEvent(ctx context.Context, outbox OutboxRepository, eventType string, inc incidents.Incident, data any) error {
+	const op = "incidents.service.enqueue_incident_event"
+
+	ev, err := events.New(
+		eventType,
+		events.Schema(eventType, incidents.IncidentEventsVersion),
+		strconv.FormatInt(inc.ID, 10),
+		inc.UpdatedAt,
+		data,
+	)
+	if err != nil {
+		return errs.Wrap(op+".marshal_event", err)
+	}
+
+	if err := outbox.Enqueue(ctx, ev, incidentOrderingKey(inc.ID)); err != nil {
+		return errs.Wrap(op, err)
+	}
+	return nil
+}
+
+// Incident events are ordered per incident and check events per user, so a
+// subscriber never sees an update before the creation it follows.
+func incidentOrderingKey(id int64) string { return "incident:" + strconv.FormatInt(id, 10) }
+
+func userOrderingKey(userID string) string { return "user:" + userID }
+
 type CheckResult struct {
 	Incidents []incidents.NearbyIncident
 	Count     int
@@ -135,18 +268,20 @@ func (s *Service) CheckAndRecord(ctx context.Context, cmd incidents.CheckCommand
 		return nil, errs.Wrap(op, err)
 	}
 
-	inc, err := s.incRepo.FindNearby(ctx, cmd.Point, cmd.Limit)
+	inc, err := s.nearby.FindNearby(ctx, cmd.Point, cmd.Limit)
 	if err != nil {
 		return nil, errs.Wrap(op+".find_nearby", err)
 	}
 
 	incidentIDs := make([]int64, 0, len(inc))
+	snapshots := make([]incidents.IncidentSnapshot, 0, len(inc))
 	for _, it := range inc {
 		incidentIDs = append(incidentIDs, it.IncidentID)
+		snapshots = append(snapshots, incidents.NewIncidentSnapshot(it))
 	}
 
-	err = s.tx.WithinTx(ctx, func(ctx context.Context, checker Checker, outbox OutboxRepository) error {
-		checkID, err := checker.RecordCheck(ctx, cmd.UserID, cmd.Point, incidentIDs)
+	err = s.tx.WithinTx(ctx, func(ctx context.Context, repos TxRepos) error {
+		checkID, err := repos.Checker.RecordCheck(ctx, cmd.UserID, cmd.Point, incidentIDs)
 		if err != nil {
 			return errs.Wrap(op+".record_check", err)
 		}
@@ -155,20 +290,27 @@ func (s *Service) CheckAndRecord(ctx context.Context, cmd incidents.CheckCommand
 			return nil
 		}
 
-		ev := incidents.CheckCompleted{
+		data := incidents.CheckCompleted{
 			CheckID:     checkID,
 			UserID:      cmd.UserID,
 			Point:       cmd.Point,
 			IncidentIDs: incidentIDs,
-			OccurredAt:  time.Now(),
+			OccurredAt:  time.Now().UTC(),
+			Incidents:   snapshots,
 		}
 
-		b, err := json.Marshal(ev)
+		ev, err := events.New(
+			incidents.EventTypeCheckCompleted,
+			events.Schema(incidents.EventTypeCheckCompleted, incidents.CheckCompletedVersion),
+			strconv.FormatInt(checkID, 10),
+			data.OccurredAt,
+			data,
+		)
 		if err != nil {
 			return errs.Wrap(op+".marshal_event", err)
 		}
 
-		if err := outbox.Enqueue(ctx, "location_check", string(b)); err != nil {
+		if err := repos.Outbox.Enqueue(ctx, ev, userOrderingKey(cmd.UserID)); err != nil {
 			return errs.Wrap(op+".enqueue_outbox", err)
 		}
 
@@ -0,0 +1,115 @@
+package incidents
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+)
+
+// memoryWriter keeps incidents in a map; the fake transaction commits
+// immediately.
+type memoryWriter struct {
+	items  map[int64]incidents.Incident
+	lastID int64
+}
+
+func (w *memoryWriter) Create(_ context.Context, in incidents.CreateIncident) (incidents.Incident, error) {
+	w.lastID++
+	inc := incidents.Incident{
+		ID: w.lastID, Title: in.Title, Description: in.Description, Center: in.Center,
+		Radius: in.Radius, Severity: in.Severity, Active: true, UpdatedAt: time.Now().UTC(),
+	}
+	w.items[inc.ID] = inc
+	return inc, nil
+}
+
+func (w *memoryWriter) GetByIDForUpdate(_ context.Context, id int64) (incidents.Incident, error) {
+	return w.items[id], nil
+}
+
+func (w *memoryWriter) Update(_ context.Context, id int64, in incidents.UpdateIncident) (incidents.Incident, error) {
+	inc := w.items[id]
+	if in.Title != nil {
+		inc.Title = *in.Title
+	}
+	if in.Radius != nil {
+		inc.Radius = *in.Radius
+	}
+	w.items[id] = inc
+	return inc, nil
+}
+
+func (w *memoryWriter) Deactivate(_ context.Context, id int64) error {
+	inc := w.items[id]
+	inc.Active = false
+	w.items[id] = inc
+	return nil
+}
+
+type enqueued struct {
+	ev  events.Envelope
+	key string
+}
+
+type memoryOutbox struct{ items []enqueued }
+
+func (o *memoryOutbox) Enqueue(_ context.Context, ev events.Envelope, key string) error {
+	o.items = append(o.items, enqueued{ev: ev, key: key})
+	return nil
+}
+
+type memoryTx struct{ repos TxRepos }
+
+func (tx memoryTx) WithinTx(ctx context.Context, fn func(context.Context, TxRepos) error) error {
+	return fn(ctx, tx.repos)
+}
+
+func TestService_EnqueuesLifecycleEvents(t *testing.T) {
+	ctx := context.Background()
+	outbox := &memoryOutbox{}
+	svc := NewService(nil, memoryTx{repos: TxRepos{
+		Incidents: &memoryWriter{items: map[int64]incidents.Incident{}},
+		Outbox:    outbox,
+	}})
+
+	inc, err := svc.Create(ctx, incidents.CreateIncident{
+		Title: "Flooding", Center: incidents.Point{Lat: 55.75, Lon: 37.61}, Radius: 500, Severity: incidents.SeverityHigh,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	title := "Flood"
+	if _, err := svc.Update(ctx, inc.ID, incidents.UpdateIncident{Title: &title}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	// An update that changes nothing emits no event.
+	if _, err := svc.Update(ctx, inc.ID, incidents.UpdateIncident{Title: &title}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := svc.Deactivate(ctx, inc.ID); err != nil {
+		t.Fatalf("Deactivate: %v", err)
+	}
+
+	want := []string{
+		incidents.EventTypeIncidentCreated,
+		incidents.EventTypeIncidentUpdated,
+		incidents.EventTypeIncidentDeactivated,
+	}
+	if len(outbox.items) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), outbox.items)
+	}
+	for i, it := range outbox.items {
+		if it.ev.Type != want[i] {
+			t.Errorf("event %d: type %q, want %q", i, it.ev.Type, want[i])
+		}
+		if it.key != "incident:1" {
+			t.Errorf("event %d: ordering key %q, want incident:1", i, it.key)
+		}
+		if it.ev.Subject != "1" {
+			t.Errorf("event %d: subject %q, want 1", i, it.ev.Subject)
+		}
+	}
+}
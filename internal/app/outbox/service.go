@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/outbox"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+)
+
+// maxRequeue bounds the ids accepted by one requeue request.
+const maxRequeue = 1000
+
+// Purge deletes in batches of purgeBatchSize with purgeBatchPause in
+// between, like the retention cleanup, so that it never holds locks on the
+// outbox for long.
+const (
+	purgeBatchSize  = 5000
+	purgeBatchPause = 100 * time.Millisecond
+)
+
+type Repository interface {
+	List(ctx context.Context, f outbox.ListFilter) ([]outbox.Event, error)
+	GetByID(ctx context.Context, id int64) (outbox.Event, error)
+	Requeue(ctx context.Context, ids []int64) ([]int64, error)
+	// Purge deletes up to limit events matching f.
+	Purge(ctx context.Context, f outbox.PurgeFilter, limit int) (int64, error)
+	Summary(ctx context.Context) (outbox.Summary, error)
+}
+
+type Service struct {
+	repo Repository
+
+	purgeBatch int
+	purgePause time.Duration
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo, purgeBatch: purgeBatchSize, purgePause: purgeBatchPause}
+}
+
+func (s *Service) List(ctx context.Context, f outbox.ListFilter) ([]outbox.Event, error) {
+	const op = "outbox.service.list"
+
+	if err := f.Validate(); err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+	if f.Limit == 0 {
+		f.Limit = 50
+	}
+
+	items, err := s.repo.List(ctx, f)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+	return items, nil
+}
+
+func (s *Service) GetByID(ctx context.Context, id int64) (outbox.Event, error) {
+	const op = "outbox.service.get_by_id"
+
+	if id <= 0 {
+		return outbox.Event{}, errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, nil)
+	}
+
+	ev, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return outbox.Event{}, errs.Wrap(op, err)
+	}
+	return ev, nil
+}
+
+// Requeue gives the dead events among ids a fresh set of attempts. Ids of
+// events that are not dead are ignored; the requeued ids are returned.
+func (s *Service) Requeue(ctx context.Context, ids []int64) ([]int64, error) {
+	const op = "outbox.service.requeue"
+
+	if len(ids) == 0 || len(ids) > maxRequeue {
+		return nil, errs.E(errs.KindInvalid, "INVALID_IDS", op, "invalid ids", map[string]string{"ids": "must contain 1 to 1000 ids"}, nil)
+	}
+	for _, id := range ids {
+		if id <= 0 {
+			return nil, errs.E(errs.KindInvalid, "INVALID_IDS", op, "invalid ids", map[string]string{"ids": "must be > 0"}, nil)
+		}
+	}
+
+	requeued, err := s.repo.Requeue(ctx, ids)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+	return requeued, nil
+}
+
+// Purge deletes dispatched or dead events created before f.Before, batch by
+// batch until a batch comes back short. On error it returns how many were
+// deleted before it.
+func (s *Service) Purge(ctx context.Context, f outbox.PurgeFilter) (int64, error) {
+	const op = "outbox.service.purge"
+
+	if err := f.Validate(); err != nil {
+		return 0, errs.Wrap(op, err)
+	}
+
+	var total int64
+	for {
+		n, err := s.repo.Purge(ctx, f, s.purgeBatch)
+		if err != nil {
+			return total, errs.Wrap(op, err)
+		}
+		total += n
+		if n < int64(s.purgeBatch) {
+			return total, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return total, errs.Wrap(op, ctx.Err())
+		case <-time.After(s.purgePause):
+		}
+	}
+}
+
+func (s *Service) Summary(ctx context.Context) (outbox.Summary, error) {
+	const op = "outbox.service.summary"
+
+	sum, err := s.repo.Summary(ctx)
+	if err != nil {
+		return outbox.Summary{}, errs.Wrap(op, err)
+	}
+	return sum, nil
+}
@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/outbox"
+)
+
+// purgeRepo pretends to hold left matching events and records the batch
+// sizes it was asked to delete.
+type purgeRepo struct {
+	Repository
+	left    int64
+	batches []int
+}
+
+func (r *purgeRepo) Purge(_ context.Context, _ outbox.PurgeFilter, limit int) (int64, error) {
+	r.batches = append(r.batches, limit)
+	n := min(r.left, int64(limit))
+	r.left -= n
+	return n, nil
+}
+
+func TestService_PurgeDeletesInBatches(t *testing.T) {
+	repo := &purgeRepo{left: 25}
+	svc := NewService(repo)
+	svc.purgeBatch = 10
+	svc.purgePause = 0
+
+	n, err := svc.Purge(context.Background(), outbox.PurgeFilter{Status: outbox.StatusDead, Before: time.Now()})
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if n != 25 {
+		t.Fatalf("expected 25 deleted, got %d", n)
+	}
+	if len(repo.batches) != 3 {
+		t.Fatalf("expected 3 batches, got %v", repo.batches)
+	}
+	for _, b := range repo.batches {
+		if b != 10 {
+			t.Fatalf("expected batches of 10, got %v", repo.batches)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/webhooks"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/urlpolicy"
+)
+
+type SubscriptionsRepository interface {
+	Create(ctx context.Context, in webhooks.CreateSubscription) (webhooks.Subscription, error)
+	GetByID(ctx context.Context, id int64) (webhooks.Subscription, error)
+	List(ctx context.Context, f webhooks.ListFilter) ([]webhooks.Subscription, error)
+	Update(ctx context.Context, id int64, in webhooks.UpdateSubscription) (webhooks.Subscription, error)
+	Delete(ctx context.Context, id int64) error
+	RequestVerification(ctx context.Context, id int64) (webhooks.Subscription, error)
+}
+
+type DeliveriesRepository interface {
+	List(ctx context.Context, subscriptionID int64, f webhooks.DeliveryFilter) ([]webhooks.Delivery, error)
+}
+
+type Option func(*Service)
+
+// WithURLPolicy rejects subscription URLs the policy would block on
+// delivery. Only the scheme and literal addresses are checked here; resolved
+// addresses are checked by the worker on every connection.
+func WithURLPolicy(p urlpolicy.Policy) Option {
+	return func(s *Service) { s.policy = &p }
+}
+
+type Service struct {
+	repo       SubscriptionsRepository
+	deliveries DeliveriesRepository
+	policy     *urlpolicy.Policy
+}
+
+func NewService(repo SubscriptionsRepository, deliveries DeliveriesRepository, opts ...Option) *Service {
+	s := &Service{repo: repo, deliveries: deliveries}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Service) checkURL(op, raw string) error {
+	if s.policy == nil {
+		return nil
+	}
+	if err := s.policy.CheckURL(raw); err != nil {
+		return errs.E(errs.KindInvalid, "URL_NOT_ALLOWED", op, "url not allowed", map[string]string{"url": err.Error()}, err)
+	}
+	return nil
+}
+
+func (s *Service) Create(ctx context.Context, cmd webhooks.CreateSubscription) (webhooks.Subscription, error) {
+	const op = "webhooks.service.create"
+
+	if err := cmd.Validate(); err != nil {
+		return webhooks.Subscription{}, errs.Wrap(op, err)
+	}
+	if err := s.checkURL(op, cmd.URL); err != nil {
+		return webhooks.Subscription{}, err
+	}
+
+	sub, err := s.repo.Create(ctx, cmd)
+	if err != nil {
+		return webhooks.Subscription{}, errs.Wrap(op, err)
+	}
+	return sub, nil
+}
+
+func (s *Service) GetByID(ctx context.Context, id int64) (webhooks.Subscription, error) {
+	const op = "webhooks.service.get_by_id"
+
+	if id <= 0 {
+		return webhooks.Subscription{}, errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, nil)
+	}
+
+	sub, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return webhooks.Subscription{}, errs.Wrap(op, err)
+	}
+	return sub, nil
+}
+
+func (s *Service) List(ctx context.Context, f webhooks.ListFilter) ([]webhooks.Subscription, error) {
+	const op = "webhooks.service.list"
+
+	if f.Limit < 0 {
+		f.Limit = 0
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+
+	items, err := s.repo.List(ctx, f)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+	return items, nil
+}
+
+func (s *Service) Update(ctx context.Context, id int64, cmd webhooks.UpdateSubscription) (webhooks.Subscription, error) {
+	const op = "webhooks.service.update"
+
+	if id <= 0 {
+		return webhooks.Subscription{}, errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, nil)
+	}
+	if err := cmd.Validate(); err != nil {
+		return webhooks.Subscription{}, errs.Wrap(op, err)
+	}
+	if cmd.URL != nil {
+		if err := s.checkURL(op, *cmd.URL); err != nil {
+			return webhooks.Subscription{}, err
+		}
+	}
+
+	if cmd.Batching != nil || cmd.Template != nil {
+		cur, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return webhooks.Subscription{}, errs.Wrap(op, err)
+		}
+		batching, tpl := cur.Batching, cur.Template
+		if cmd.Batching != nil {
+			batching = *cmd.Batching
+		}
+		if cmd.Template != nil {
+			tpl = *cmd.Template
+		}
+		if err := webhooks.ValidateBatching(batching, tpl); err != nil {
+			return webhooks.Subscription{}, errs.Wrap(op, err)
+		}
+	}
+
+	sub, err := s.repo.Update(ctx, id, cmd)
+	if err != nil {
+		return webhooks.Subscription{}, errs.Wrap(op, err)
+	}
+	return sub, nil
+}
+
+func (s *Service) Delete(ctx context.Context, id int64) error {
+	const op = "webhooks.service.delete"
+
+	if id <= 0 {
+		return errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, nil)
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return errs.Wrap(op, err)
+	}
+	return nil
+}
+
+// Verify schedules an immediate verification challenge for subscription id.
+// The subscription receives no events until the receiver answers it.
+func (s *Service) Verify(ctx context.Context, id int64) (webhooks.Subscription, error) {
+	const op = "webhooks.service.verify"
+
+	if id <= 0 {
+		return webhooks.Subscription{}, errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, nil)
+	}
+
+	sub, err := s.repo.RequestVerification(ctx, id)
+	if err != nil {
+		return webhooks.Subscription{}, errs.Wrap(op, err)
+	}
+	return sub, nil
+}
+
+// Deliveries lists the latest delivery state of events sent to subscription
+// id, most recently updated first.
+func (s *Service) Deliveries(ctx context.Context, id int64, f webhooks.DeliveryFilter) ([]webhooks.Delivery, error) {
+	const op = "webhooks.service.deliveries"
+
+	if id <= 0 {
+		return nil, errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, nil)
+	}
+	if f.Status != "" && !f.Status.Valid() {
+		return nil, errs.E(errs.KindInvalid, "INVALID_STATUS", op, "invalid status", map[string]string{"status": "must be one of delivered, failed"}, nil)
+	}
+	if f.Limit <= 0 || f.Limit > 500 {
+		f.Limit = 50
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	items, err := s.deliveries.List(ctx, id, f)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+	return items, nil
+}
+
+type Preview struct {
+	Body    []byte
+	Headers http.Header
+}
+
+// Preview renders ev, or the sample event of eventType if ev is nil, the way
+// the worker would send it to subscription id.
+func (s *Service) Preview(ctx context.Context, id int64, eventType string, ev *events.Envelope, mode events.Mode) (Preview, error) {
+	const op = "webhooks.service.preview"
+
+	sub, err := s.GetByID(ctx, id)
+	if err != nil {
+		return Preview{}, errs.Wrap(op, err)
+	}
+
+	var event events.Envelope
+	switch {
+	case ev != nil:
+		if err := ev.Validate(); err != nil {
+			return Preview{}, errs.E(errs.KindInvalid, "INVALID_EVENT", op, "invalid event", map[string]string{"event": err.Error()}, err)
+		}
+		event = *ev
+	default:
+		if eventType == "" {
+			eventType = incidents.EventTypeCheckCompleted
+		}
+		sample, ok := webhooks.SampleEvent(eventType)
+		if !ok {
+			return Preview{}, errs.E(errs.KindInvalid, "UNKNOWN_EVENT_TYPE", op, "unknown event type", map[string]string{"event_type": "unknown"}, nil)
+		}
+		event = sample
+	}
+
+	body, headers, err := sub.Encode(event, mode)
+	if err != nil {
+		return Preview{}, errs.E(errs.KindInvalid, "TEMPLATE_RENDER_FAILED", op, "template render failed", map[string]string{"template": err.Error()}, err)
+	}
+
+	return Preview{Body: body, Headers: headers}, nil
+}
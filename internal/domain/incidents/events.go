@@ -2,10 +2,130 @@ package incidents
 
 import "time"
 
+const (
+	EventTypeCheckCompleted = "location.check.completed"
+
+	// CheckCompletedVersion is bumped on every breaking change to CheckCompleted.
+	CheckCompletedVersion = 1
+)
+
 type CheckCompleted struct {
-	CheckID     int64
-	UserID      string
-	Point       Point
-	IncidentIDs []int64
-	OccurredAt  time.Time
+	CheckID     int64     `json:"check_id"`
+	UserID      string    `json:"user_id"`
+	Point       Point     `json:"point"`
+	IncidentIDs []int64   `json:"incident_ids"`
+	OccurredAt  time.Time `json:"occurred_at"`
+
+	// Incidents is a snapshot of the matched incidents taken when the check
+	// was recorded. It is omitted from slim payloads.
+	Incidents []IncidentSnapshot `json:"incidents,omitempty"`
+}
+
+type IncidentSnapshot struct {
+	ID             int64    `json:"id"`
+	Title          string   `json:"title"`
+	Description    string   `json:"description,omitempty"`
+	Severity       Severity `json:"severity"`
+	Center         Point    `json:"center"`
+	Radius         int      `json:"radius"`
+	DistanceMeters float64  `json:"distance_meters"`
+}
+
+func NewIncidentSnapshot(n NearbyIncident) IncidentSnapshot {
+	return IncidentSnapshot{
+		ID:             n.IncidentID,
+		Title:          n.Title,
+		Description:    n.Description,
+		Severity:       n.Severity,
+		Center:         n.Center,
+		Radius:         n.Radius,
+		DistanceMeters: n.DistanceMeters,
+	}
+}
+
+// Slim returns a copy of e without incident snapshots.
+func (e CheckCompleted) Slim() CheckCompleted {
+	e.Incidents = nil
+	return e
+}
+
+const (
+	EventTypeIncidentCreated     = "incident.created"
+	EventTypeIncidentUpdated     = "incident.updated"
+	EventTypeIncidentDeactivated = "incident.deactivated"
+
+	// IncidentEventsVersion is bumped on every breaking change to the
+	// incident lifecycle event payloads.
+	IncidentEventsVersion = 1
+)
+
+// IncidentData is the representation of an incident in lifecycle events.
+type IncidentData struct {
+	ID          int64     `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Severity    Severity  `json:"severity"`
+	Center      Point     `json:"center"`
+	Radius      int       `json:"radius"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func NewIncidentData(in Incident) IncidentData {
+	return IncidentData{
+		ID:          in.ID,
+		Title:       in.Title,
+		Description: in.Description,
+		Severity:    in.Severity,
+		Center:      in.Center,
+		Radius:      in.Radius,
+		Active:      in.Active,
+		CreatedAt:   in.CreatedAt,
+		UpdatedAt:   in.UpdatedAt,
+	}
+}
+
+type IncidentCreated struct {
+	Incident IncidentData `json:"incident"`
+}
+
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+type IncidentUpdated struct {
+	Incident IncidentData `json:"incident"`
+	// Changes is keyed by the JSON field name of IncidentData.
+	Changes map[string]FieldChange `json:"changes"`
+}
+
+type IncidentDeactivated struct {
+	Incident IncidentData `json:"incident"`
+}
+
+// Diff returns the fields that differ between before and after.
+func Diff(before, after Incident) map[string]FieldChange {
+	changes := map[string]FieldChange{}
+
+	if before.Title != after.Title {
+		changes["title"] = FieldChange{Old: before.Title, New: after.Title}
+	}
+	if before.Description != after.Description {
+		changes["description"] = FieldChange{Old: before.Description, New: after.Description}
+	}
+	if before.Severity != after.Severity {
+		changes["severity"] = FieldChange{Old: before.Severity, New: after.Severity}
+	}
+	if before.Center != after.Center {
+		changes["center"] = FieldChange{Old: before.Center, New: after.Center}
+	}
+	if before.Radius != after.Radius {
+		changes["radius"] = FieldChange{Old: before.Radius, New: after.Radius}
+	}
+	if before.Active != after.Active {
+		changes["active"] = FieldChange{Old: before.Active, New: after.Active}
+	}
+	return changes
 }
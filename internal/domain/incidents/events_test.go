@@ -0,0 +1,68 @@
+package incidents
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	base := Incident{
+		ID:          1,
+		Title:       "Flooding",
+		Description: "Major flood warning",
+		Center:      Point{Lat: 55.7558, Lon: 37.6173},
+		Radius:      500,
+		Severity:    SeverityHigh,
+		Active:      true,
+		UpdatedAt:   time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name   string
+		change func(*Incident)
+		want   map[string]FieldChange
+	}{
+		{name: "nothing", change: func(*Incident) {}, want: map[string]FieldChange{}},
+		{
+			name:   "timestamps only",
+			change: func(i *Incident) { i.UpdatedAt = i.UpdatedAt.Add(time.Hour) },
+			want:   map[string]FieldChange{},
+		},
+		{
+			name:   "title",
+			change: func(i *Incident) { i.Title = "Flood" },
+			want:   map[string]FieldChange{"title": {Old: "Flooding", New: "Flood"}},
+		},
+		{
+			name:   "center",
+			change: func(i *Incident) { i.Center.Lon = 37.7 },
+			want:   map[string]FieldChange{"center": {Old: base.Center, New: Point{Lat: 55.7558, Lon: 37.7}}},
+		},
+		{
+			name: "several fields",
+			change: func(i *Incident) {
+				i.Description = ""
+				i.Radius = 1000
+				i.Severity = SeverityCritical
+				i.Active = false
+			},
+			want: map[string]FieldChange{
+				"description": {Old: "Major flood warning", New: ""},
+				"radius":      {Old: 500, New: 1000},
+				"severity":    {Old: SeverityHigh, New: SeverityCritical},
+				"active":      {Old: true, New: false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := base
+			tt.change(&after)
+			got := Diff(base, after)
+			if !maps.Equal(got, tt.want) {
+				t.Fatalf("Diff = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package incidents
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// WGS84 ellipsoid, the one PostGIS measures geography distances on.
+const (
+	wgs84A = 6378137.0
+	wgs84F = 1 / 298.257223563
+	wgs84B = wgs84A * (1 - wgs84F)
+
+	meanEarthRadius = 6371008.8
+)
+
+// Distance returns the geodesic distance in meters between a and b on the
+// WGS84 ellipsoid (Vincenty's inverse formula), matching PostGIS geography
+// distances to well under a millimeter.
+func Distance(a, b Point) float64 {
+	const (
+		maxIter   = 200
+		tolerance = 1e-12
+	)
+
+	L := toRad(b.Lon - a.Lon)
+	U1 := math.Atan((1 - wgs84F) * math.Tan(toRad(a.Lat)))
+	U2 := math.Atan((1 - wgs84F) * math.Tan(toRad(b.Lat)))
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2, cosU2 := math.Sincos(U2)
+
+	lambda := L
+	var sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM float64
+	for range maxIter {
+		sinLambda, cosLambda := math.Sincos(lambda)
+		t1 := cosU2 * sinLambda
+		t2 := cosU1*sinU2 - sinU1*cosU2*cosLambda
+		sinSigma = math.Sqrt(t1*t1 + t2*t2)
+		if sinSigma == 0 {
+			return 0 // coincident points
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cosSqAlpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		}
+		C := wgs84F / 16 * cosSqAlpha * (4 + wgs84F*(4-3*cosSqAlpha))
+		prev := lambda
+		lambda = L + (1-C)*wgs84F*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prev) < tolerance {
+			uSq := cosSqAlpha * (wgs84A*wgs84A - wgs84B*wgs84B) / (wgs84B * wgs84B)
+			A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+			B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+			deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+				B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+			return wgs84B * A * (sigma - deltaSigma)
+		}
+	}
+
+	// Nearly antipodal points, where the iteration does not converge. No
+	// incident is that large, so the spherical distance is close enough.
+	return haversine(a, b)
+}
+
+func haversine(a, b Point) float64 {
+	dLat := toRad(b.Lat - a.Lat)
+	dLon := toRad(b.Lon - a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Lat))*math.Cos(toRad(b.Lat))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * meanEarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func toRad(deg float64) float64 { return deg * math.Pi / 180 }
+
+// Box is a latitude/longitude rectangle in degrees. MinLon may be below -180
+// and MaxLon above 180 when the box crosses the antimeridian; Split returns
+// it as boxes within the usual ranges.
+type Box struct {
+	MinLat, MinLon float64
+	MaxLat, MaxLon float64
+}
+
+// Split returns b as one or two boxes with longitudes within [-180, 180].
+func (b Box) Split() []Box {
+	switch {
+	case b.MaxLon-b.MinLon >= 360:
+		return []Box{{MinLat: b.MinLat, MinLon: -180, MaxLat: b.MaxLat, MaxLon: 180}}
+	case b.MinLon < -180:
+		return []Box{
+			{MinLat: b.MinLat, MinLon: b.MinLon + 360, MaxLat: b.MaxLat, MaxLon: 180},
+			{MinLat: b.MinLat, MinLon: -180, MaxLat: b.MaxLat, MaxLon: b.MaxLon},
+		}
+	case b.MaxLon > 180:
+		return []Box{
+			{MinLat: b.MinLat, MinLon: b.MinLon, MaxLat: b.MaxLat, MaxLon: 180},
+			{MinLat: b.MinLat, MinLon: -180, MaxLat: b.MaxLat, MaxLon: b.MaxLon - 360},
+		}
+	}
+	return []Box{b}
+}
+
+// Contains reports whether p lies in b, edges included. b must be split.
+func (b Box) Contains(p Point) bool {
+	return p.Lat >= b.MinLat && p.Lat <= b.MaxLat && p.Lon >= b.MinLon && p.Lon <= b.MaxLon
+}
+
+// Bounds returns a box containing the incident's circle. It errs on the
+// large side: a box is used to find candidates, never to decide a match.
+func (i Incident) Bounds() Box {
+	const (
+		// Shortest degree of latitude (at the equator) and longitude at the
+		// equator on the sphere, both lower bounds on WGS84.
+		metersPerDegreeLat = 110574.0
+		metersPerDegreeLon = 111319.0
+		slack              = 1.01
+	)
+
+	r := float64(i.Radius) * slack
+	dLat := r / metersPerDegreeLat
+	b := Box{
+		MinLat: i.Center.Lat - dLat,
+		MinLon: -180,
+		MaxLat: i.Center.Lat + dLat,
+		MaxLon: 180,
+	}
+	if b.MinLat <= -90 || b.MaxLat >= 90 {
+		// The circle covers a pole and so every longitude.
+		b.MinLat, b.MaxLat = max(b.MinLat, -90), min(b.MaxLat, 90)
+		return b
+	}
+
+	farthest := max(math.Abs(b.MinLat), math.Abs(b.MaxLat))
+	dLon := r / (metersPerDegreeLon * math.Cos(toRad(farthest)))
+	if dLon < 180 {
+		b.MinLon, b.MaxLon = i.Center.Lon-dLon, i.Center.Lon+dLon
+	}
+	return b
+}
+
+// Covers reports whether p lies within the incident's radius and how far
+// from its center.
+func (i Incident) Covers(p Point) (float64, bool) {
+	d := Distance(i.Center, p)
+	return d, d <= float64(i.Radius)
+}
+
+// Nearby returns the active incidents among items whose radius covers p,
+// nearest first, at most limit of them. It answers in Go what
+// FindNearby answers in PostGIS.
+func Nearby(items []Incident, p Point, limit int) []NearbyIncident {
+	out := make([]NearbyIncident, 0)
+	for _, it := range items {
+		if !it.Active {
+			continue
+		}
+		d, ok := it.Covers(p)
+		if !ok {
+			continue
+		}
+		out = append(out, NearbyIncident{
+			IncidentID:     it.ID,
+			DistanceMeters: d,
+			Title:          it.Title,
+			Description:    it.Description,
+			Center:         it.Center,
+			Radius:         it.Radius,
+			Severity:       it.Severity,
+			CreatedAt:      it.CreatedAt,
+			UpdatedAt:      it.UpdatedAt,
+		})
+	}
+
+	slices.SortFunc(out, func(a, b NearbyIncident) int {
+		return cmp.Or(cmp.Compare(a.DistanceMeters, b.DistanceMeters), cmp.Compare(a.IncidentID, b.IncidentID))
+	})
+	if len(out) > limit {
+		out = out[:max(limit, 0)]
+	}
+	return out
+}
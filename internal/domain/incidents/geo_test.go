@@ -0,0 +1,79 @@
+package incidents
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Point
+		want float64
+	}{
+		// Vincenty's own test line, Flinders Peak to Buninyong.
+		{"geodesic", Point{Lat: -37.95103342, Lon: 144.42486789}, Point{Lat: -37.65282114, Lon: 143.92649554}, 54972.271},
+		{"one degree of longitude on the equator", Point{}, Point{Lon: 1}, 111319.491},
+		{"same point", Point{Lat: 55.7558, Lon: 37.6173}, Point{Lat: 55.7558, Lon: 37.6173}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Distance(tt.a, tt.b); math.Abs(got-tt.want) > 0.001 {
+				t.Fatalf("Distance = %.4f, want %.3f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncident_BoundsContainCircle(t *testing.T) {
+	centers := []Point{{Lat: 55.7558, Lon: 37.6173}, {Lat: -70, Lon: 179.99}, {Lat: 0, Lon: 0}}
+	for _, c := range centers {
+		inc := Incident{Center: c, Radius: 20000}
+		boxes := inc.Bounds().Split()
+
+		// Points on the circle, just inside the radius, in every direction.
+		for bearing := 0.0; bearing < 360; bearing += 15 {
+			p := destination(c, bearing, float64(inc.Radius)*0.999)
+			inside := false
+			for _, b := range boxes {
+				if b.Contains(p) {
+					inside = true
+				}
+			}
+			if !inside {
+				t.Fatalf("center %v: %v at bearing %.0f is outside %v", c, p, bearing, boxes)
+			}
+		}
+	}
+}
+
+func TestNearby(t *testing.T) {
+	center := Point{Lat: 55.7558, Lon: 37.6173}
+	items := []Incident{
+		{ID: 1, Center: center, Radius: 1000, Active: true},
+		{ID: 2, Center: Point{Lat: 55.7600, Lon: 37.6173}, Radius: 1000, Active: true},
+		{ID: 3, Center: center, Radius: 1000},
+		{ID: 4, Center: Point{Lat: 55.8, Lon: 37.6173}, Radius: 1000, Active: true},
+	}
+
+	got := Nearby(items, center, 10)
+	if len(got) != 2 || got[0].IncidentID != 1 || got[1].IncidentID != 2 {
+		t.Fatalf("expected active incidents 1 and 2 nearest first, got %+v", got)
+	}
+	if got := Nearby(items, center, 1); len(got) != 1 {
+		t.Fatalf("limit not applied: %+v", got)
+	}
+}
+
+// destination moves d meters from p along bearing on the sphere.
+func destination(p Point, bearing, d float64) Point {
+	lat, lon, brg := toRad(p.Lat), toRad(p.Lon), toRad(bearing)
+	delta := d / meanEarthRadius
+	lat2 := math.Asin(math.Sin(lat)*math.Cos(delta) + math.Cos(lat)*math.Sin(delta)*math.Cos(brg))
+	lon2 := lon + math.Atan2(math.Sin(brg)*math.Sin(delta)*math.Cos(lat), math.Cos(delta)-math.Sin(lat)*math.Sin(lat2))
+	out := Point{Lat: lat2 * 180 / math.Pi, Lon: lon2 * 180 / math.Pi}
+	if out.Lon > 180 {
+		out.Lon -= 360
+	}
+	return out
+}
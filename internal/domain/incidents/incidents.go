@@ -12,8 +12,9 @@ type Incident struct {
 	Title       string
 	Description string
 
-	Center Point
-	Radius int // meters
+	Center   Point
+	Radius   int // meters
+	Severity Severity
 
 	Active bool
 
@@ -26,6 +27,7 @@ type CreateIncident struct {
 	Description string
 	Center      Point
 	Radius      int
+	Severity    Severity // defaults to SeverityMedium
 }
 
 func (c CreateIncident) Validate() error {
@@ -39,6 +41,9 @@ func (c CreateIncident) Validate() error {
 	if c.Radius <= 0 {
 		fields["radius"] = "must be > 0"
 	}
+	if c.Severity != "" && !c.Severity.Valid() {
+		fields["severity"] = "must be one of low, medium, high, critical"
+	}
 	if err := c.Center.Validate(op); err != nil {
 		return err
 	}
@@ -54,6 +59,7 @@ type UpdateIncident struct {
 	Description *string
 	Center      *Point
 	Radius      *int
+	Severity    *Severity
 }
 
 func (u UpdateIncident) Validate() error {
@@ -67,6 +73,9 @@ func (u UpdateIncident) Validate() error {
 	if u.Radius != nil && *u.Radius <= 0 {
 		fields["radius"] = "must be > 0"
 	}
+	if u.Severity != nil && !u.Severity.Valid() {
+		fields["severity"] = "must be one of low, medium, high, critical"
+	}
 	if u.Center != nil {
 		if err := u.Center.Validate(op); err != nil {
 			return err
@@ -85,8 +94,9 @@ type NearbyIncident struct {
 	Title          string
 	Description    string
 
-	Center Point
-	Radius int // meters
+	Center   Point
+	Radius   int // meters
+	Severity Severity
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
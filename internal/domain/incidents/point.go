@@ -3,8 +3,8 @@ package incidents
 import "github.com/m1ll3r1337/geo-notifications-service/internal/errs"
 
 type Point struct {
-	Lat float64
-	Lon float64
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
 }
 
 func (p Point) Validate(op string) error {
@@ -0,0 +1,111 @@
+// Package outbox models the transactional outbox from an operator's point of
+// view: inspecting, requeueing and purging events.
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+)
+
+type Status string
+
+const (
+	StatusPending    Status = "pending"
+	StatusDispatched Status = "dispatched"
+	StatusDead       Status = "dead"
+)
+
+func (s Status) Valid() bool {
+	return s == StatusPending || s == StatusDispatched || s == StatusDead
+}
+
+type Event struct {
+	ID              int64
+	Type            string
+	Payload         json.RawMessage
+	Status          Status
+	Attempts        int
+	NextAttemptAt   time.Time
+	ProcessingUntil *time.Time
+	LastError       string
+	OrderingKey     string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// MaxListLimit bounds the events returned by one List call.
+const MaxListLimit = 500
+
+// ListFilter selects events; zero fields match everything. From and To
+// bound created_at. A zero Limit selects the default page size.
+type ListFilter struct {
+	Status Status
+	Type   string
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+func (f ListFilter) Validate() error {
+	const op = "outbox.model.validate_list"
+
+	fields := map[string]string{}
+
+	if f.Status != "" && !f.Status.Valid() {
+		fields["status"] = "must be one of pending, dispatched, dead"
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && f.To.Before(f.From) {
+		fields["to"] = "must not be before from"
+	}
+	if f.Limit < 0 || f.Limit > MaxListLimit {
+		fields["limit"] = fmt.Sprintf("must be between 0 and %d", MaxListLimit)
+	}
+	if f.Offset < 0 {
+		fields["offset"] = "must be >= 0"
+	}
+
+	if len(fields) > 0 {
+		return errs.E(errs.KindInvalid, "OUTBOX_FILTER_INVALID", op, "invalid filter", fields, nil)
+	}
+	return nil
+}
+
+// PurgeFilter selects finished events to delete. Pending events are never
+// purged.
+type PurgeFilter struct {
+	Status Status
+	Type   string
+	Before time.Time // created_at upper bound, required
+}
+
+func (f PurgeFilter) Validate() error {
+	const op = "outbox.model.validate_purge"
+
+	fields := map[string]string{}
+
+	if f.Status != StatusDead && f.Status != StatusDispatched {
+		fields["status"] = "must be one of dispatched, dead"
+	}
+	if f.Before.IsZero() {
+		fields["before"] = "is required"
+	}
+
+	if len(fields) > 0 {
+		return errs.E(errs.KindInvalid, "OUTBOX_PURGE_INVALID", op, "invalid purge filter", fields, nil)
+	}
+	return nil
+}
+
+// Summary counts events per status for dashboards.
+type Summary struct {
+	Counts map[Status]int64
+	// Stuck counts pending events whose processing lease expired: a relay
+	// claimed them and stopped before recording the outcome.
+	Stuck           int64
+	OldestPendingAt *time.Time
+}
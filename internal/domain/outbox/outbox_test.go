@@ -0,0 +1,71 @@
+package outbox
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+)
+
+func TestListFilter_Validate(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		name  string
+		in    ListFilter
+		field string // empty when valid
+	}{
+		{name: "empty", in: ListFilter{}},
+		{name: "full", in: ListFilter{Status: StatusDead, Type: "incident.created", From: now.Add(-time.Hour), To: now, Limit: MaxListLimit, Offset: 10}},
+		{name: "unknown status", in: ListFilter{Status: "lost"}, field: "status"},
+		{name: "to before from", in: ListFilter{From: now, To: now.Add(-time.Hour)}, field: "to"},
+		{name: "negative limit", in: ListFilter{Limit: -1}, field: "limit"},
+		{name: "limit too large", in: ListFilter{Limit: MaxListLimit + 1}, field: "limit"},
+		{name: "negative offset", in: ListFilter{Offset: -1}, field: "offset"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assertInvalidField(t, tc.in.Validate(), tc.field)
+		})
+	}
+}
+
+func TestPurgeFilter_Validate(t *testing.T) {
+	before := time.Now()
+
+	cases := []struct {
+		name  string
+		in    PurgeFilter
+		field string
+	}{
+		{name: "dead", in: PurgeFilter{Status: StatusDead, Before: before}},
+		{name: "dispatched with type", in: PurgeFilter{Status: StatusDispatched, Type: "incident.created", Before: before}},
+		{name: "pending", in: PurgeFilter{Status: StatusPending, Before: before}, field: "status"},
+		{name: "missing status", in: PurgeFilter{Before: before}, field: "status"},
+		{name: "missing before", in: PurgeFilter{Status: StatusDead}, field: "before"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assertInvalidField(t, tc.in.Validate(), tc.field)
+		})
+	}
+}
+
+func assertInvalidField(t *testing.T, err error, field string) {
+	t.Helper()
+	if field == "" {
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return
+	}
+	e, ok := errs.As(err)
+	if !ok || e.Kind != errs.KindInvalid {
+		t.Fatalf("expected invalid error, got %T: %v", err, err)
+	}
+	if _, ok := e.Fields[field]; !ok {
+		t.Fatalf("expected field %q, got %v", field, e.Fields)
+	}
+}
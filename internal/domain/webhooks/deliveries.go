@@ -0,0 +1,44 @@
+package webhooks
+
+import "time"
+
+type DeliveryStatus string
+
+const (
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+func (s DeliveryStatus) Valid() bool {
+	return s == DeliveryDelivered || s == DeliveryFailed
+}
+
+// Delivery is the latest state of an event's delivery to one subscription.
+// Subscription 0 is the target configured on the worker.
+type Delivery struct {
+	OutboxID       int64
+	SubscriptionID int64
+	EventID        string
+	EventType      string
+	Status         DeliveryStatus
+	Attempts       int
+	LastError      string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// DeliveryAttempt is the outcome of a single attempt; Err is empty on success.
+type DeliveryAttempt struct {
+	OutboxID       int64
+	SubscriptionID int64
+	EventID        string
+	EventType      string
+	Err            string
+}
+
+type DeliveryFilter struct {
+	Status DeliveryStatus // empty for any
+	Limit  int
+	Offset int
+}
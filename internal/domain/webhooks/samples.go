@@ -0,0 +1,97 @@
+package webhooks
+
+import (
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+)
+
+// SampleEvents returns one representative event per event type. They are
+// used to validate and preview templates.
+func SampleEvents() []events.Envelope {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	inc := incidents.Incident{
+		ID:          1,
+		Title:       "Flooding in downtown",
+		Description: "Major flood warning",
+		Center:      incidents.Point{Lat: 55.7558, Lon: 37.6173},
+		Radius:      500,
+		Severity:    incidents.SeverityHigh,
+		Active:      true,
+		CreatedAt:   at,
+		UpdatedAt:   at,
+	}
+	data := incidents.NewIncidentData(inc)
+
+	samples := []struct {
+		typ     string
+		version int
+		subject string
+		data    any
+	}{
+		{
+			typ:     incidents.EventTypeCheckCompleted,
+			version: incidents.CheckCompletedVersion,
+			subject: "17",
+			data: incidents.CheckCompleted{
+				CheckID:     17,
+				UserID:      "user1",
+				Point:       incidents.Point{Lat: 55.7560, Lon: 37.6175},
+				IncidentIDs: []int64{inc.ID},
+				OccurredAt:  at,
+				Incidents: []incidents.IncidentSnapshot{{
+					ID:             inc.ID,
+					Title:          inc.Title,
+					Description:    inc.Description,
+					Severity:       inc.Severity,
+					Center:         inc.Center,
+					Radius:         inc.Radius,
+					DistanceMeters: 25.4,
+				}},
+			},
+		},
+		{
+			typ:     incidents.EventTypeIncidentCreated,
+			version: incidents.IncidentEventsVersion,
+			subject: "1",
+			data:    incidents.IncidentCreated{Incident: data},
+		},
+		{
+			typ:     incidents.EventTypeIncidentUpdated,
+			version: incidents.IncidentEventsVersion,
+			subject: "1",
+			data: incidents.IncidentUpdated{
+				Incident: data,
+				Changes:  map[string]incidents.FieldChange{"radius": {Old: 300, New: 500}},
+			},
+		},
+		{
+			typ:     incidents.EventTypeIncidentDeactivated,
+			version: incidents.IncidentEventsVersion,
+			subject: "1",
+			data:    incidents.IncidentDeactivated{Incident: data},
+		},
+	}
+
+	out := make([]events.Envelope, 0, len(samples))
+	for _, s := range samples {
+		ev, err := events.New(s.typ, events.Schema(s.typ, s.version), s.subject, at, s.data)
+		if err != nil {
+			panic(err)
+		}
+		ev.ID = "sample-" + s.typ
+		out = append(out, ev)
+	}
+	return out
+}
+
+// SampleEvent returns the sample for eventType, or false if there is none.
+func SampleEvent(eventType string) (events.Envelope, bool) {
+	for _, ev := range SampleEvents() {
+		if ev.Type == eventType {
+			return ev, true
+		}
+	}
+	return events.Envelope{}, false
+}
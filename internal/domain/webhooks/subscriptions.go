@@ -0,0 +1,261 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+)
+
+// PayloadMode controls how much of an event a subscription receives.
+type PayloadMode string
+
+const (
+	// PayloadFull delivers events as emitted, including incident snapshots.
+	PayloadFull PayloadMode = "full"
+	// PayloadSlim strips snapshots and leaves only identifiers.
+	PayloadSlim PayloadMode = "slim"
+)
+
+func (m PayloadMode) Valid() bool {
+	return m == PayloadFull || m == PayloadSlim
+}
+
+// Batching groups a subscription's events into one request of up to
+// MaxItems events, sent at the latest MaxWait after the first event was
+// added. The zero value delivers every event on its own.
+type Batching struct {
+	MaxItems int
+	MaxWait  time.Duration
+}
+
+const (
+	MaxBatchItems = 1000
+	MaxBatchWait  = 10 * time.Second
+)
+
+func (b Batching) Enabled() bool {
+	return b.MaxItems > 1
+}
+
+func (b Batching) validate(fields map[string]string) {
+	if b.MaxItems < 0 || b.MaxItems > MaxBatchItems {
+		fields["batch_max_items"] = fmt.Sprintf("must be between 0 and %d", MaxBatchItems)
+	}
+	if b.MaxWait < 0 || b.MaxWait > MaxBatchWait {
+		fields["batch_max_wait_ms"] = fmt.Sprintf("must be between 0 and %d", MaxBatchWait.Milliseconds())
+	}
+	if b.MaxWait > 0 && !b.Enabled() {
+		fields["batch_max_wait_ms"] = "requires batch_max_items > 1"
+	}
+}
+
+// ValidateBatching checks that batching and the template can be used
+// together: batches are always sent in the CloudEvents batch format, so a
+// body template cannot apply to them.
+func ValidateBatching(b Batching, t Template) error {
+	const op = "webhooks.model.validate_batching"
+
+	if b.Enabled() && t.Body != "" {
+		return errs.E(errs.KindInvalid, "SUBSCRIPTION_INVALID", op, "invalid subscription",
+			map[string]string{"batch_max_items": "batching cannot be combined with a body template"}, nil)
+	}
+	return nil
+}
+
+type Subscription struct {
+	ID          int64
+	URL         string
+	PayloadMode PayloadMode
+	Template    Template
+	Batching    Batching
+	TLS         TLS
+	Active      bool
+	// Verification is reset whenever the URL changes; only verified
+	// subscriptions receive events.
+	Verification Verification
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type CreateSubscription struct {
+	URL         string
+	PayloadMode PayloadMode // defaults to PayloadFull
+	Template    Template
+	Batching    Batching
+	TLS         TLS
+}
+
+func (c CreateSubscription) Validate() error {
+	const op = "webhooks.model.validate_create"
+
+	fields := map[string]string{}
+
+	if msg := validateURL(c.URL); msg != "" {
+		fields["url"] = msg
+	}
+	if c.PayloadMode != "" && !c.PayloadMode.Valid() {
+		fields["payload_mode"] = "must be one of full, slim"
+	}
+	c.Batching.validate(fields)
+
+	if len(fields) > 0 {
+		return errs.E(errs.KindInvalid, "SUBSCRIPTION_INVALID", op, "invalid subscription", fields, nil)
+	}
+	if err := ValidateBatching(c.Batching, c.Template); err != nil {
+		return err
+	}
+	if err := c.TLS.Validate(); err != nil {
+		return err
+	}
+	return c.Template.Validate()
+}
+
+type UpdateSubscription struct {
+	URL         *string
+	PayloadMode *PayloadMode
+	Template    *Template // replaces the whole template
+	Batching    *Batching
+	TLS         *TLS // replaces all certificates
+	Active      *bool
+}
+
+func (u UpdateSubscription) Validate() error {
+	const op = "webhooks.model.validate_update"
+
+	fields := map[string]string{}
+
+	if u.URL != nil {
+		if msg := validateURL(*u.URL); msg != "" {
+			fields["url"] = msg
+		}
+	}
+	if u.PayloadMode != nil && !u.PayloadMode.Valid() {
+		fields["payload_mode"] = "must be one of full, slim"
+	}
+	if u.Batching != nil {
+		u.Batching.validate(fields)
+	}
+
+	if len(fields) > 0 {
+		return errs.E(errs.KindInvalid, "SUBSCRIPTION_INVALID", op, "invalid subscription", fields, nil)
+	}
+	if u.TLS != nil {
+		if err := u.TLS.Validate(); err != nil {
+			return err
+		}
+	}
+	if u.Template != nil {
+		return u.Template.Validate()
+	}
+	return nil
+}
+
+// Shape adapts ev to the subscription's payload mode.
+func (s Subscription) Shape(ev events.Envelope) (events.Envelope, error) {
+	if s.PayloadMode != PayloadSlim || ev.Type != incidents.EventTypeCheckCompleted {
+		return ev, nil
+	}
+
+	var data incidents.CheckCompleted
+	if err := json.Unmarshal(ev.Data, &data); err != nil {
+		return events.Envelope{}, err
+	}
+	b, err := json.Marshal(data.Slim())
+	if err != nil {
+		return events.Envelope{}, err
+	}
+	ev.Data = b
+	return ev, nil
+}
+
+type ListFilter struct {
+	Limit      int
+	Offset     int
+	ActiveOnly bool
+}
+
+func validateURL(raw string) string {
+	if strings.TrimSpace(raw) == "" {
+		return "is required"
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "must be an absolute url"
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "scheme must be http or https"
+	}
+	return ""
+}
+
+// Encode builds the request body and headers of ev for the subscription: the
+// payload is shaped to its payload mode and then rendered with its template.
+// Without a body template the event is encoded in the given CloudEvents
+// content mode.
+func (s Subscription) Encode(ev events.Envelope, mode events.Mode) ([]byte, http.Header, error) {
+	shaped, err := s.Shape(ev)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s.Template.IsZero() {
+		return shaped.HTTPBody(mode)
+	}
+
+	rendered, err := s.Template.Render(shaped)
+	if err != nil {
+		return nil, nil, fmt.Errorf("render template: %w", err)
+	}
+
+	if rendered.Body != nil {
+		rendered.Headers.Set("Content-Type", rendered.ContentType)
+		return rendered.Body, rendered.Headers, nil
+	}
+
+	body, headers, err := shaped.HTTPBody(mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, vs := range rendered.Headers {
+		headers[k] = vs
+	}
+	return body, headers, nil
+}
+
+// EncodeBatch builds the body and headers of a batched request: the events,
+// each shaped to the payload mode, in the CloudEvents JSON batch format.
+// Header templates are rendered against the first event.
+func (s Subscription) EncodeBatch(evs []events.Envelope) ([]byte, http.Header, error) {
+	shaped := make([]events.Envelope, 0, len(evs))
+	for _, ev := range evs {
+		sh, err := s.Shape(ev)
+		if err != nil {
+			return nil, nil, err
+		}
+		shaped = append(shaped, sh)
+	}
+
+	body, headers, err := events.BatchBody(shaped)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(s.Template.Headers) > 0 && len(shaped) > 0 {
+		rendered, err := Template{Headers: s.Template.Headers}.Render(shaped[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("render template: %w", err)
+		}
+		for k, vs := range rendered.Headers {
+			headers[k] = vs
+		}
+	}
+	return body, headers, nil
+}
@@ -0,0 +1,122 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+)
+
+func TestCreateSubscription_Validate(t *testing.T) {
+	cases := []struct {
+		name  string
+		in    CreateSubscription
+		field string // empty when valid
+	}{
+		{name: "valid", in: CreateSubscription{URL: "https://example.com/hook"}},
+		{name: "slim", in: CreateSubscription{URL: "https://example.com/hook", PayloadMode: PayloadSlim}},
+		{name: "missing url", in: CreateSubscription{}, field: "url"},
+		{name: "relative url", in: CreateSubscription{URL: "/hook"}, field: "url"},
+		{name: "unsupported scheme", in: CreateSubscription{URL: "ftp://example.com/hook"}, field: "url"},
+		{name: "unknown payload mode", in: CreateSubscription{URL: "https://example.com/hook", PayloadMode: "tiny"}, field: "payload_mode"},
+		{name: "batch too large", in: CreateSubscription{URL: "https://example.com/hook", Batching: Batching{MaxItems: MaxBatchItems + 1}}, field: "batch_max_items"},
+		{name: "wait without batch", in: CreateSubscription{URL: "https://example.com/hook", Batching: Batching{MaxWait: time.Second}}, field: "batch_max_wait_ms"},
+		{name: "wait too long", in: CreateSubscription{URL: "https://example.com/hook", Batching: Batching{MaxItems: 10, MaxWait: time.Minute}}, field: "batch_max_wait_ms"},
+		{
+			name:  "batch with body template",
+			in:    CreateSubscription{URL: "https://example.com/hook", Batching: Batching{MaxItems: 10}, Template: Template{Body: `{{ .Type }}`}},
+			field: "batch_max_items",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assertInvalidField(t, tc.in.Validate(), tc.field)
+		})
+	}
+}
+
+func TestUpdateSubscription_Validate(t *testing.T) {
+	empty, relative := "", "/hook"
+	slim, unknown := PayloadSlim, PayloadMode("tiny")
+
+	cases := []struct {
+		name  string
+		in    UpdateSubscription
+		field string
+	}{
+		{name: "nothing to change", in: UpdateSubscription{}},
+		{name: "payload mode", in: UpdateSubscription{PayloadMode: &slim}},
+		{name: "empty url", in: UpdateSubscription{URL: &empty}, field: "url"},
+		{name: "relative url", in: UpdateSubscription{URL: &relative}, field: "url"},
+		{name: "unknown payload mode", in: UpdateSubscription{PayloadMode: &unknown}, field: "payload_mode"},
+		{name: "negative batch", in: UpdateSubscription{Batching: &Batching{MaxItems: -1}}, field: "batch_max_items"},
+		{name: "broken template", in: UpdateSubscription{Template: &Template{Body: "{{ .Type "}}, field: "template"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assertInvalidField(t, tc.in.Validate(), tc.field)
+		})
+	}
+}
+
+func assertInvalidField(t *testing.T, err error, field string) {
+	t.Helper()
+	if field == "" {
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return
+	}
+	e, ok := errs.As(err)
+	if !ok || e.Kind != errs.KindInvalid {
+		t.Fatalf("expected invalid error, got %T: %v", err, err)
+	}
+	if _, ok := e.Fields[field]; !ok {
+		t.Fatalf("expected field %q, got %v", field, e.Fields)
+	}
+}
+
+func TestSubscription_Shape(t *testing.T) {
+	check, _ := SampleEvent(incidents.EventTypeCheckCompleted)
+	created, _ := SampleEvent(incidents.EventTypeIncidentCreated)
+
+	cases := []struct {
+		name          string
+		mode          PayloadMode
+		wantSnapshots bool
+	}{
+		{name: "full check", mode: PayloadFull, wantSnapshots: true},
+		{name: "slim check", mode: PayloadSlim, wantSnapshots: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			shaped, err := Subscription{PayloadMode: tc.mode}.Shape(check)
+			if err != nil {
+				t.Fatalf("Shape: %v", err)
+			}
+			var data map[string]any
+			if err := json.Unmarshal(shaped.Data, &data); err != nil {
+				t.Fatalf("unmarshal data: %v", err)
+			}
+			if _, ok := data["incidents"]; ok != tc.wantSnapshots {
+				t.Fatalf("snapshots present = %v, want %v: %s", ok, tc.wantSnapshots, shaped.Data)
+			}
+			if data["check_id"] != float64(17) || data["user_id"] != "user1" {
+				t.Fatalf("identifiers missing from %s", shaped.Data)
+			}
+			if shaped.ID != check.ID || shaped.Type != check.Type {
+				t.Fatalf("envelope attributes changed: %+v", shaped)
+			}
+		})
+	}
+
+	// Only check results have snapshots to strip; other events pass as is.
+	shaped, err := Subscription{PayloadMode: PayloadSlim}.Shape(created)
+	if err != nil || string(shaped.Data) != string(created.Data) {
+		t.Fatalf("slim incident event changed: %s, %v", shaped.Data, err)
+	}
+}
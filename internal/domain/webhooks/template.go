@@ -0,0 +1,385 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+)
+
+const (
+	maxTemplateSize = 16 << 10
+	maxRenderedSize = 256 << 10
+	// maxRenderWork is the budget of one render (see budget), in bytes of
+	// template text run and function results built.
+	maxRenderWork = 1 << 20
+	// minStepCost is charged for short range bodies, so that even empty
+	// ones do not loop for free.
+	minStepCost = 64
+)
+
+var (
+	errRenderTooLarge = errors.New("rendered output exceeds size limit")
+	errRenderTooLong  = errors.New("rendering exceeded its work limit")
+)
+
+// reservedHeaders cannot be set by templates: they are either controlled by
+// the HTTP client or required for delivery semantics.
+var reservedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Idempotency-Key":   true,
+}
+
+// Template customizes the request sent to a subscription. Body and header
+// values are Go text/template sources rendered with TemplateData.
+type Template struct {
+	Body        string
+	Headers     map[string]string
+	ContentType string // defaults to application/json when Body is set
+}
+
+func (t Template) IsZero() bool {
+	return t.Body == "" && len(t.Headers) == 0
+}
+
+// TemplateData is the dot value templates are rendered with.
+type TemplateData struct {
+	ID      string
+	Type    string
+	Source  string
+	Subject string
+	Time    time.Time
+	// Data is the event data decoded into maps, slices and scalars.
+	Data any
+}
+
+type Rendered struct {
+	Body        []byte // nil when the template has no body
+	ContentType string
+	Headers     http.Header
+}
+
+// budget bounds the work of one render. text/template cannot be
+// interrupted, so instead every range iteration and template call is
+// charged the length of the template text it runs, and every function the
+// length of its result; the render fails once the budget is spent.
+type budget struct {
+	left int
+}
+
+func (b *budget) spend(n int) error {
+	if b.left -= n; b.left < 0 {
+		return errRenderTooLong
+	}
+	return nil
+}
+
+// result charges s and fails when it exceeds the output limit, so that
+// nesting calls cannot build huge strings.
+func (b *budget) result(s string) (string, error) {
+	if len(s) > maxRenderedSize {
+		return "", errRenderTooLarge
+	}
+	if err := b.spend(len(s)); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// funcs returns the whole function set available to templates: the helpers
+// below, and the text/template builtins that build strings replaced by
+// charged versions. None of them can reach the filesystem, the environment
+// or the network.
+func (b *budget) funcs() template.FuncMap {
+	return template.FuncMap{
+		stepFunc: func(cost int) (string, error) { return "", b.spend(max(cost, minStepCost)) },
+
+		"json": func(v any) (string, error) {
+			out, err := json.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			return b.result(string(out))
+		},
+		"upper": func(s string) (string, error) { return b.result(strings.ToUpper(s)) },
+		"lower": func(s string) (string, error) { return b.result(strings.ToLower(s)) },
+		"trim":  func(s string) (string, error) { return b.result(strings.TrimSpace(s)) },
+		"contains": func(s, substr string) (bool, error) {
+			return strings.Contains(s, substr), b.spend(len(s))
+		},
+		"replace": func(s, old, new string) (string, error) {
+			if grow := len(new) - len(old); grow > 0 && len(s)+strings.Count(s, old)*grow > maxRenderedSize {
+				return "", errRenderTooLarge
+			}
+			return b.result(strings.ReplaceAll(s, old, new))
+		},
+		"join": func(sep string, items []any) (string, error) {
+			if len(items) > 1 && len(sep)*(len(items)-1) > maxRenderedSize {
+				return "", errRenderTooLarge
+			}
+			parts := make([]string, 0, len(items))
+			for _, it := range items {
+				parts = append(parts, fmt.Sprint(it))
+			}
+			return b.result(strings.Join(parts, sep))
+		},
+		"truncate": func(n int, s string) (string, error) {
+			r := []rune(s)
+			if n < 0 || len(r) <= n {
+				return b.result(s)
+			}
+			return b.result(string(r[:n]))
+		},
+		"default": func(def, v any) any {
+			if v == nil || v == "" {
+				return def
+			}
+			return v
+		},
+		"formatTime": func(layout string, v any) (string, error) {
+			switch t := v.(type) {
+			case time.Time:
+				return b.result(t.Format(layout))
+			case string:
+				parsed, err := time.Parse(time.RFC3339Nano, t)
+				if err != nil {
+					return "", err
+				}
+				return b.result(parsed.Format(layout))
+			default:
+				return "", fmt.Errorf("formatTime: unsupported value %T", v)
+			}
+		},
+		"xml": func(s string) (string, error) {
+			var out bytes.Buffer
+			_ = xml.EscapeText(&out, []byte(s))
+			return b.result(out.String())
+		},
+
+		"print":   func(args ...any) (string, error) { return b.result(fmt.Sprint(args...)) },
+		"println": func(args ...any) (string, error) { return b.result(fmt.Sprintln(args...)) },
+		"printf": func(format string, args ...any) (string, error) {
+			if err := checkFormat(format); err != nil {
+				return "", err
+			}
+			return b.result(fmt.Sprintf(format, args...))
+		},
+		"html":     func(args ...any) (string, error) { return b.result(template.HTMLEscaper(args...)) },
+		"js":       func(args ...any) (string, error) { return b.result(template.JSEscaper(args...)) },
+		"urlquery": func(args ...any) (string, error) { return b.result(template.URLQueryEscaper(args...)) },
+	}
+}
+
+// checkFormat rejects printf formats whose widths and precisions add up to
+// more than the output limit, since fmt pads before anything can stop it.
+func checkFormat(format string) error {
+	total := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		for i++; i < len(format) && !isVerb(format[i]); i++ {
+			switch c := format[i]; {
+			case c == '*':
+				return errors.New("printf: * width and precision are not supported")
+			case c >= '0' && c <= '9':
+				n := 0
+				for ; i < len(format) && format[i] >= '0' && format[i] <= '9'; i++ {
+					n = min(n*10+int(format[i]-'0'), maxRenderedSize+1)
+				}
+				i--
+				total += n
+			}
+		}
+		if total > maxRenderedSize {
+			return errRenderTooLarge
+		}
+	}
+	return nil
+}
+
+// isVerb reports whether c ends a printf directive.
+func isVerb(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '%'
+}
+
+// Validate parses the template and renders it against a sample of every
+// event type, so that errors surface when the subscription is saved rather
+// than on delivery.
+func (t Template) Validate() error {
+	const op = "webhooks.model.validate_template"
+
+	fields := map[string]string{}
+
+	if len(t.Body) > maxTemplateSize {
+		fields["body_template"] = fmt.Sprintf("must be at most %d bytes", maxTemplateSize)
+	}
+	for name, v := range t.Headers {
+		canonical := http.CanonicalHeaderKey(name)
+		switch {
+		case !validHeaderName(name):
+			fields["header_templates."+name] = "invalid header name"
+		case reservedHeaders[canonical]:
+			fields["header_templates."+name] = "header is reserved"
+		case len(v) > maxTemplateSize:
+			fields["header_templates."+name] = fmt.Sprintf("must be at most %d bytes", maxTemplateSize)
+		}
+	}
+	if len(fields) > 0 {
+		return errs.E(errs.KindInvalid, "TEMPLATE_INVALID", op, "invalid template", fields, nil)
+	}
+
+	for _, ev := range SampleEvents() {
+		if _, err := t.Render(ev); err != nil {
+			return errs.E(errs.KindInvalid, "TEMPLATE_INVALID", op, "invalid template",
+				map[string]string{"template": fmt.Sprintf("%s: %v", ev.Type, err)}, err)
+		}
+	}
+	return nil
+}
+
+// Render executes the template for ev. Each part is bounded in size and in
+// the number of loop iterations.
+func (t Template) Render(ev events.Envelope) (Rendered, error) {
+	var data any
+	if len(ev.Data) > 0 {
+		if err := json.Unmarshal(ev.Data, &data); err != nil {
+			return Rendered{}, fmt.Errorf("decode event data: %w", err)
+		}
+	}
+	dot := TemplateData{
+		ID:      ev.ID,
+		Type:    ev.Type,
+		Source:  ev.Source,
+		Subject: ev.Subject,
+		Time:    ev.Time,
+		Data:    data,
+	}
+
+	out := Rendered{Headers: http.Header{}}
+
+	if t.Body != "" {
+		body, err := render("body", t.Body, dot)
+		if err != nil {
+			return Rendered{}, fmt.Errorf("body: %w", err)
+		}
+		out.Body = body
+		out.ContentType = t.ContentType
+		if out.ContentType == "" {
+			out.ContentType = "application/json"
+		}
+	}
+
+	for name, src := range t.Headers {
+		v, err := render(name, src, dot)
+		if err != nil {
+			return Rendered{}, fmt.Errorf("header %s: %w", name, err)
+		}
+		if bytes.ContainsAny(v, "\r\n") {
+			return Rendered{}, fmt.Errorf("header %s: value must not contain newlines", name)
+		}
+		out.Headers.Set(name, string(v))
+	}
+
+	return out, nil
+}
+
+// stepFunc charges the budget of a render; a call to it starts every range
+// body and template.
+const stepFunc = "_step"
+
+// stepCall returns an action charging cost to the budget. It holds only the
+// function name and the cost, so it can be added to any parsed template.
+func stepCall(cost int) parse.Node {
+	t := template.Must(template.New("step").
+		Funcs(template.FuncMap{stepFunc: func(int) string { return "" }}).
+		Parse(fmt.Sprintf("{{%s %d}}", stepFunc, cost)))
+	return t.Root.Nodes[0]
+}
+
+func render(name, src string, dot TemplateData) ([]byte, error) {
+	b := &budget{left: maxRenderWork}
+	tpl, err := template.New(name).Funcs(b.funcs()).Option("missingkey=zero").Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	// Recursion through {{ template }} is charged as well as loops.
+	for _, t := range tpl.Templates() {
+		if t.Tree == nil || t.Root == nil {
+			continue
+		}
+		addSteps(t.Root)
+		t.Root.Nodes = append([]parse.Node{stepCall(len(t.Root.String()))}, t.Root.Nodes...)
+	}
+
+	w := &limitedBuffer{max: maxRenderedSize}
+	if err := tpl.Execute(w, dot); err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+// addSteps starts every range body under n with a step charging the body's
+// length.
+func addSteps(n parse.Node) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			addSteps(c)
+		}
+	case *parse.IfNode:
+		addSteps(n.List)
+		addSteps(n.ElseList)
+	case *parse.WithNode:
+		addSteps(n.List)
+		addSteps(n.ElseList)
+	case *parse.RangeNode:
+		addSteps(n.List)
+		addSteps(n.ElseList)
+		if n.List != nil {
+			n.List.Nodes = append([]parse.Node{stepCall(len(n.List.String()))}, n.List.Nodes...)
+		}
+	}
+}
+
+type limitedBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	if l.buf.Len()+len(p) > l.max {
+		return 0, errRenderTooLarge
+	}
+	return l.buf.Write(p)
+}
+
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			continue
+		}
+		if !strings.ContainsRune("!#$%&'*+-.^_`|~", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package webhooks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+)
+
+func TestTemplate_Render_Slack(t *testing.T) {
+	tpl := Template{
+		Body: `{"text": {{ printf "%s: %v incident(s) near user %s" .Type (len .Data.incident_ids) .Data.user_id | json }}}`,
+		Headers: map[string]string{
+			"X-Check-Id": "{{ .Subject }}",
+		},
+	}
+
+	ev, ok := SampleEvent(incidents.EventTypeCheckCompleted)
+	if !ok {
+		t.Fatalf("missing sample event")
+	}
+
+	out, err := tpl.Render(ev)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if got, want := string(out.Body), `{"text": "location.check.completed: 1 incident(s) near user user1"}`; got != want {
+		t.Fatalf("body: got %s want %s", got, want)
+	}
+	if out.ContentType != "application/json" {
+		t.Fatalf("unexpected content type %q", out.ContentType)
+	}
+	if out.Headers.Get("X-Check-Id") != "17" {
+		t.Fatalf("unexpected headers %v", out.Headers)
+	}
+}
+
+func TestTemplate_Validate(t *testing.T) {
+	cases := []struct {
+		name  string
+		tpl   Template
+		field string
+	}{
+		{name: "parse error", tpl: Template{Body: "{{ .Type "}, field: "template"},
+		{name: "unknown func", tpl: Template{Body: `{{ env "HOME" }}`}, field: "template"},
+		{name: "reserved header", tpl: Template{Headers: map[string]string{"Host": "x"}}, field: "header_templates.Host"},
+		{name: "invalid header name", tpl: Template{Headers: map[string]string{"Bad Header": "x"}}, field: "header_templates.Bad Header"},
+		{name: "header newline", tpl: Template{Headers: map[string]string{"X-A": "a\nb"}}, field: "template"},
+		{name: "endless loop", tpl: Template{Body: "{{ range 1000000000000 }}{{ end }}"}, field: "template"},
+		{name: "output too large", tpl: Template{Body: `{{ range 1000000 }}xxxxxxxxxx{{ end }}`}, field: "template"},
+		{name: "nested loops", tpl: Template{Body: `{{ range .Data.incident_ids }}{{ range 5000 }}{{ range 5000 }}{{ end }}{{ end }}{{ end }}`}, field: "template"},
+		{name: "recursion", tpl: Template{Body: `{{ define "a" }}{{ template "a" . }}{{ template "a" . }}{{ end }}{{ template "a" . }}`}, field: "template"},
+		{name: "growing printf", tpl: Template{Body: `{{ printf "%s%s" (printf "%s%s" (printf "%200000d" 1) (printf "%200000d" 1)) "" }}`}, field: "template"},
+		{name: "padded printf", tpl: Template{Body: `{{ printf "%200000d%200000d" 1 2 | len }}`}, field: "template"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.tpl.Validate()
+			e, ok := errs.As(err)
+			if !ok || e.Kind != errs.KindInvalid {
+				t.Fatalf("expected invalid error, got %T: %v", err, err)
+			}
+			if _, ok := e.Fields[tc.field]; !ok {
+				t.Fatalf("expected field %q, got %v", tc.field, e.Fields)
+			}
+		})
+	}
+}
+
+func TestTemplate_Render_BoundedLoopsStillRender(t *testing.T) {
+	tpl := Template{Body: `{{ range $i, $id := .Data.incident_ids }}{{ if $i }},{{ end }}{{ printf "#%v" $id }}{{ end }}|{{ range 3 }}x{{ end }}`}
+
+	ev, _ := SampleEvent(incidents.EventTypeCheckCompleted)
+	out, err := tpl.Render(ev)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if got := string(out.Body); got != "#1|xxx" {
+		t.Fatalf("body: got %q", got)
+	}
+}
+
+func TestSubscription_Encode_SlimWithTemplate(t *testing.T) {
+	sub := Subscription{
+		PayloadMode: PayloadSlim,
+		Template:    Template{Body: `{{ json .Data }}`},
+	}
+	ev, _ := SampleEvent(incidents.EventTypeCheckCompleted)
+
+	body, headers, err := sub.Encode(ev, "")
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if strings.Contains(string(body), `"incidents"`) {
+		t.Fatalf("slim payload must not contain snapshots: %s", body)
+	}
+	if headers.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected headers %v", headers)
+	}
+}
@@ -0,0 +1,45 @@
+package webhooks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+)
+
+// TLS holds PEM-encoded certificates for endpoints that use a private CA or
+// require client certificates. Empty fields fall back to the worker's
+// configured files, and then to the system defaults.
+type TLS struct {
+	CACert     string // bundle of trusted root certificates
+	ClientCert string
+	ClientKey  string
+}
+
+func (t TLS) IsZero() bool {
+	return t.CACert == "" && t.ClientCert == "" && t.ClientKey == ""
+}
+
+func (t TLS) Validate() error {
+	const op = "webhooks.model.validate_tls"
+
+	fields := map[string]string{}
+
+	if t.CACert != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(t.CACert)) {
+		fields["tls.ca_cert"] = "must contain at least one PEM certificate"
+	}
+
+	switch {
+	case (t.ClientCert == "") != (t.ClientKey == ""):
+		fields["tls.client_cert"] = "client_cert and client_key must be set together"
+	case t.ClientCert != "":
+		if _, err := tls.X509KeyPair([]byte(t.ClientCert), []byte(t.ClientKey)); err != nil {
+			fields["tls.client_cert"] = "invalid certificate or key: " + err.Error()
+		}
+	}
+
+	if len(fields) > 0 {
+		return errs.E(errs.KindInvalid, "SUBSCRIPTION_INVALID", op, "invalid subscription", fields, nil)
+	}
+	return nil
+}
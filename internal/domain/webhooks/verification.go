@@ -0,0 +1,48 @@
+package webhooks
+
+import "time"
+
+// EventTypeVerification is the challenge sent to prove that the receiver of
+// a subscription controls its URL.
+const (
+	EventTypeVerification    = "webhook.verification"
+	VerificationEventVersion = 1
+)
+
+type VerificationStatus string
+
+const (
+	// VerificationPending subscriptions receive no events until the receiver
+	// answers the challenge.
+	VerificationPending VerificationStatus = "pending_verification"
+	VerificationOK      VerificationStatus = "verified"
+	// VerificationFailed subscriptions gave up after too many failed
+	// challenges; they are retried only when verification is requested again.
+	VerificationFailed VerificationStatus = "verification_failed"
+)
+
+type Verification struct {
+	Status     VerificationStatus
+	Attempts   int // failed challenges since the last success
+	LastError  string
+	VerifiedAt *time.Time
+}
+
+// VerificationData is the data of a webhook.verification event. The receiver
+// must answer with a 2xx response whose body is {"token": "<Token>"}.
+type VerificationData struct {
+	SubscriptionID int64  `json:"subscription_id"`
+	Token          string `json:"token"`
+}
+
+// VerificationResult is the outcome of one challenge for the subscription
+// with the given URL.
+type VerificationResult struct {
+	URL     string
+	Err     string // empty on success
+	RetryAt time.Time
+	GiveUp  bool
+	// Deferred means the challenge was not sent because the destination was
+	// throttled; it is retried at RetryAt and does not count as an attempt.
+	Deferred bool
+}
@@ -0,0 +1,153 @@
+// Package events defines the CloudEvents 1.0 envelope used for every event
+// the service emits.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SpecVersion = "1.0"
+
+	// Source identifies this service as the producer of an event.
+	Source = "urn:geo-notifications-service"
+
+	ContentTypeJSON       = "application/json"
+	ContentTypeStructured = "application/cloudevents+json; charset=utf-8"
+	ContentTypeBatch      = "application/cloudevents-batch+json; charset=utf-8"
+)
+
+// Mode selects how an event is mapped onto an HTTP request.
+type Mode string
+
+const (
+	// ModeStructured sends the whole envelope as the request body.
+	ModeStructured Mode = "structured"
+	// ModeBinary sends data as the body and the attributes as ce-* headers.
+	ModeBinary Mode = "binary"
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeStructured:
+		return ModeStructured, nil
+	case ModeBinary:
+		return ModeBinary, nil
+	default:
+		return "", fmt.Errorf("unknown content mode %q", s)
+	}
+}
+
+type Envelope struct {
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// Schema returns the dataschema URI for version v of eventType. Consumers
+// should branch on it rather than on the shape of data: a breaking change to
+// data always comes with a new version.
+func Schema(eventType string, v int) string {
+	return fmt.Sprintf("urn:geo-notifications:events:%s:v%d", eventType, v)
+}
+
+// New wraps data in an envelope with a fresh id.
+func New(eventType, schema, subject string, at time.Time, data any) (Envelope, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("marshal event data: %w", err)
+	}
+
+	return Envelope{
+		ID:              uuid.NewString(),
+		Source:          Source,
+		SpecVersion:     SpecVersion,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            at.UTC(),
+		DataContentType: ContentTypeJSON,
+		DataSchema:      schema,
+		Data:            b,
+	}, nil
+}
+
+func (e Envelope) Validate() error {
+	switch {
+	case e.ID == "":
+		return errors.New("event id is required")
+	case e.Source == "":
+		return errors.New("event source is required")
+	case e.SpecVersion != SpecVersion:
+		return fmt.Errorf("unsupported specversion %q", e.SpecVersion)
+	case e.Type == "":
+		return errors.New("event type is required")
+	}
+	return nil
+}
+
+// Decode parses a structured-mode envelope.
+func Decode(b []byte) (Envelope, error) {
+	var e Envelope
+	if err := json.Unmarshal(b, &e); err != nil {
+		return Envelope{}, err
+	}
+	if err := e.Validate(); err != nil {
+		return Envelope{}, err
+	}
+	return e, nil
+}
+
+// HTTPBody returns the request body and headers for e in the given mode.
+func (e Envelope) HTTPBody(mode Mode) ([]byte, http.Header, error) {
+	h := http.Header{}
+
+	if mode == ModeBinary {
+		h.Set("Content-Type", e.DataContentType)
+		h.Set("ce-id", e.ID)
+		h.Set("ce-source", e.Source)
+		h.Set("ce-specversion", e.SpecVersion)
+		h.Set("ce-type", e.Type)
+		h.Set("ce-time", e.Time.Format(time.RFC3339Nano))
+		if e.Subject != "" {
+			h.Set("ce-subject", e.Subject)
+		}
+		if e.DataSchema != "" {
+			h.Set("ce-dataschema", e.DataSchema)
+		}
+		return e.Data, h, nil
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, nil, err
+	}
+	h.Set("Content-Type", ContentTypeStructured)
+	return b, h, nil
+}
+
+// BatchBody returns the request body and headers for evs in the CloudEvents
+// JSON batch format.
+func BatchBody(evs []Envelope) ([]byte, http.Header, error) {
+	if evs == nil {
+		evs = []Envelope{}
+	}
+	b, err := json.Marshal(evs)
+	if err != nil {
+		return nil, nil, err
+	}
+	h := http.Header{}
+	h.Set("Content-Type", ContentTypeBatch)
+	return b, h, nil
+}
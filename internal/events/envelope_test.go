@@ -0,0 +1,59 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEnvelope_HTTPBody_Structured(t *testing.T) {
+	ev, err := New("test.created", Schema("test.created", 1), "42", time.Now(), map[string]int{"id": 42})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	body, h, err := ev.HTTPBody(ModeStructured)
+	if err != nil {
+		t.Fatalf("HTTPBody: %v", err)
+	}
+	if got := h.Get("Content-Type"); got != ContentTypeStructured {
+		t.Fatalf("unexpected content type %q", got)
+	}
+
+	decoded, err := Decode(body)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if decoded.ID != ev.ID || decoded.Type != ev.Type || decoded.DataSchema != "urn:geo-notifications:events:test.created:v1" {
+		t.Fatalf("mismatch: got=%+v want=%+v", decoded, ev)
+	}
+}
+
+func TestEnvelope_HTTPBody_Binary(t *testing.T) {
+	ev, err := New("test.created", "", "", time.Now(), map[string]int{"id": 42})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	body, h, err := ev.HTTPBody(ModeBinary)
+	if err != nil {
+		t.Fatalf("HTTPBody: %v", err)
+	}
+	if h.Get("ce-id") != ev.ID || h.Get("ce-type") != "test.created" || h.Get("ce-specversion") != SpecVersion {
+		t.Fatalf("unexpected headers: %v", h)
+	}
+	if h.Get("ce-subject") != "" || h.Get("ce-dataschema") != "" {
+		t.Fatalf("optional attributes must be omitted: %v", h)
+	}
+
+	var data map[string]int
+	if err := json.Unmarshal(body, &data); err != nil || data["id"] != 42 {
+		t.Fatalf("unexpected body %s: %v", body, err)
+	}
+}
+
+func TestDecode_RejectsInvalid(t *testing.T) {
+	if _, err := Decode([]byte(`{"id":"1","source":"s","specversion":"0.3","type":"t"}`)); err == nil {
+		t.Fatalf("expected error for unsupported specversion")
+	}
+}
@@ -32,6 +32,7 @@ type incidentResponse struct {
 	Description string    `json:"description,omitempty"`
 	Center      point     `json:"center"`
 	Radius      int       `json:"radius"`
+	Severity    string    `json:"severity"`
 	Active      bool      `json:"active"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
@@ -44,6 +45,7 @@ func toIncidentResponse(in incidentsdom.Incident) incidentResponse {
 		Description: in.Description,
 		Center:      point{Lat: in.Center.Lat, Lon: in.Center.Lon},
 		Radius:      in.Radius,
+		Severity:    string(in.Severity),
 		Active:      in.Active,
 		CreatedAt:   in.CreatedAt,
 		UpdatedAt:   in.UpdatedAt,
@@ -55,6 +57,7 @@ type createIncidentRequest struct {
 	Description string `json:"description"`
 	Center      point  `json:"center" binding:"required"`
 	Radius      int    `json:"radius" binding:"required"`
+	Severity    string `json:"severity"`
 }
 
 func (h *Incidents) Create(ctx *gin.Context) {
@@ -73,7 +76,8 @@ func (h *Incidents) Create(ctx *gin.Context) {
 			Lat: req.Center.Lat,
 			Lon: req.Center.Lon,
 		},
-		Radius: req.Radius,
+		Radius:   req.Radius,
+		Severity: incidentsdom.Severity(req.Severity),
 	})
 	if err != nil {
 		ctx.Error(err)
@@ -130,6 +134,7 @@ type updateIncidentRequest struct {
 	Description *string `json:"description"`
 	Center      *point  `json:"center"`
 	Radius      *int    `json:"radius"`
+	Severity    *string `json:"severity"`
 }
 
 func (h *Incidents) Update(ctx *gin.Context) {
@@ -152,11 +157,18 @@ func (h *Incidents) Update(ctx *gin.Context) {
 		center = &incidentsdom.Point{Lat: req.Center.Lat, Lon: req.Center.Lon}
 	}
 
+	var severity *incidentsdom.Severity
+	if req.Severity != nil {
+		s := incidentsdom.Severity(*req.Severity)
+		severity = &s
+	}
+
 	inc, err := h.svc.Update(ctx.Request.Context(), id, incidentsdom.UpdateIncident{
 		Title:       req.Title,
 		Description: req.Description,
 		Center:      center,
 		Radius:      req.Radius,
+		Severity:    severity,
 	})
 	if err != nil {
 		ctx.Error(err)
@@ -195,8 +207,9 @@ type nearbyIncident struct {
 	Title          string  `json:"title"`
 	Description    string  `json:"description"`
 
-	Center point `json:"center"`
-	Radius int   `json:"radius"` // meters
+	Center   point  `json:"center"`
+	Radius   int    `json:"radius"` // meters
+	Severity string `json:"severity"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -237,6 +250,7 @@ func (h *Incidents) Check(ctx *gin.Context) {
 			Description:    it.Description,
 			Center:         point{Lat: it.Center.Lat, Lon: it.Center.Lon},
 			Radius:         it.Radius,
+			Severity:       string(it.Severity),
 			CreatedAt:      it.CreatedAt,
 			UpdatedAt:      it.UpdatedAt,
 		})
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	outboxapp "github.com/m1ll3r1337/geo-notifications-service/internal/app/outbox"
+	outboxdom "github.com/m1ll3r1337/geo-notifications-service/internal/domain/outbox"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+)
+
+type Outbox struct {
+	svc *outboxapp.Service
+}
+
+func NewOutbox(svc *outboxapp.Service) *Outbox {
+	return &Outbox{svc: svc}
+}
+
+type outboxEventResponse struct {
+	ID              int64           `json:"id"`
+	EventType       string          `json:"event_type"`
+	Status          string          `json:"status"`
+	Attempts        int             `json:"attempts"`
+	NextAttemptAt   time.Time       `json:"next_attempt_at"`
+	ProcessingUntil *time.Time      `json:"processing_until,omitempty"`
+	LastError       string          `json:"last_error,omitempty"`
+	OrderingKey     string          `json:"ordering_key,omitempty"`
+	Payload         json.RawMessage `json:"payload,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+func toOutboxEventResponse(ev outboxdom.Event) outboxEventResponse {
+	return outboxEventResponse{
+		ID:              ev.ID,
+		EventType:       ev.Type,
+		Status:          string(ev.Status),
+		Attempts:        ev.Attempts,
+		NextAttemptAt:   ev.NextAttemptAt,
+		ProcessingUntil: ev.ProcessingUntil,
+		LastError:       ev.LastError,
+		OrderingKey:     ev.OrderingKey,
+		Payload:         ev.Payload,
+		CreatedAt:       ev.CreatedAt,
+		UpdatedAt:       ev.UpdatedAt,
+	}
+}
+
+func parseTimeQuery(ctx *gin.Context, op, name string) (time.Time, bool) {
+	raw := ctx.Query(name)
+	if raw == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_TIME", op, "invalid time", map[string]string{name: "must be RFC3339"}, err))
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func parseIntQuery(ctx *gin.Context, op, name, def string) (int, bool) {
+	n, err := strconv.Atoi(ctx.DefaultQuery(name, def))
+	if err != nil {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_QUERY", op, "invalid query", map[string]string{name: "must be an integer"}, err))
+		return 0, false
+	}
+	return n, true
+}
+
+// List lists outbox events without payloads, newest first.
+func (h *Outbox) List(ctx *gin.Context) {
+	const op = "outbox.http.list"
+
+	from, ok := parseTimeQuery(ctx, op, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseTimeQuery(ctx, op, "to")
+	if !ok {
+		return
+	}
+	limit, ok := parseIntQuery(ctx, op, "limit", "50")
+	if !ok {
+		return
+	}
+	offset, ok := parseIntQuery(ctx, op, "offset", "0")
+	if !ok {
+		return
+	}
+
+	items, err := h.svc.List(ctx.Request.Context(), outboxdom.ListFilter{
+		Status: outboxdom.Status(ctx.Query("status")),
+		Type:   ctx.Query("type"),
+		From:   from,
+		To:     to,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	out := make([]outboxEventResponse, 0, len(items))
+	for _, it := range items {
+		out = append(out, toOutboxEventResponse(it))
+	}
+	ctx.JSON(http.StatusOK, out)
+}
+
+func (h *Outbox) GetByID(ctx *gin.Context) {
+	const op = "outbox.http.get_by_id"
+
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, err))
+		return
+	}
+
+	ev, err := h.svc.GetByID(ctx.Request.Context(), id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, toOutboxEventResponse(ev))
+}
+
+type requeueRequest struct {
+	IDs []int64 `json:"ids" binding:"required"`
+}
+
+type requeueResponse struct {
+	Requeued []int64 `json:"requeued"`
+}
+
+// Requeue resets dead events to pending; other ids are ignored.
+func (h *Outbox) Requeue(ctx *gin.Context) {
+	const op = "outbox.http.requeue"
+
+	var req requeueRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_JSON", op, "invalid json", nil, err))
+		return
+	}
+
+	ids, err := h.svc.Requeue(ctx.Request.Context(), req.IDs)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	if ids == nil {
+		ids = []int64{}
+	}
+
+	ctx.JSON(http.StatusOK, requeueResponse{Requeued: ids})
+}
+
+type purgeRequest struct {
+	Status string    `json:"status" binding:"required"`
+	Type   string    `json:"type"`
+	Before time.Time `json:"before" binding:"required"`
+}
+
+type purgeResponse struct {
+	Deleted int64 `json:"deleted"`
+}
+
+func (h *Outbox) Purge(ctx *gin.Context) {
+	const op = "outbox.http.purge"
+
+	var req purgeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_JSON", op, "invalid json", nil, err))
+		return
+	}
+
+	n, err := h.svc.Purge(ctx.Request.Context(), outboxdom.PurgeFilter{
+		Status: outboxdom.Status(req.Status),
+		Type:   req.Type,
+		Before: req.Before,
+	})
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, purgeResponse{Deleted: n})
+}
+
+type outboxSummaryResponse struct {
+	Counts          map[string]int64 `json:"counts"`
+	Stuck           int64            `json:"stuck"`
+	OldestPendingAt *time.Time       `json:"oldest_pending_at,omitempty"`
+}
+
+func (h *Outbox) Summary(ctx *gin.Context) {
+	sum, err := h.svc.Summary(ctx.Request.Context())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	counts := make(map[string]int64, len(sum.Counts))
+	for status, n := range sum.Counts {
+		counts[string(status)] = n
+	}
+	ctx.JSON(http.StatusOK, outboxSummaryResponse{
+		Counts:          counts,
+		Stuck:           sum.Stuck,
+		OldestPendingAt: sum.OldestPendingAt,
+	})
+}
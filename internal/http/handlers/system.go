@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,23 +18,94 @@ type Logger interface {
 	Error(ctx context.Context, msg string, args ...any)
 }
 
+// Reporter adds details to a dependency's health, such as queue lag. An
+// error marks the dependency degraded.
+type Reporter interface {
+	Report(ctx context.Context) (any, error)
+}
+
 type Dependency struct {
-	Name   string
-	Pinger Pinger
+	Name string
+	// Pinger and Reporter are optional; a failed ping marks the dependency
+	// down and skips the report.
+	Pinger   Pinger
+	Reporter Reporter
+	// Critical dependencies make the process unready while they are down.
+	// Without the others it keeps serving, degraded.
+	Critical bool
+	// Live marks checks of the process itself, such as worker heartbeats.
+	// They are the only checks of the liveness probe.
+	Live bool
+}
+
+type SystemOption func(*System)
+
+// WithCheckTimeout bounds each dependency check.
+func WithCheckTimeout(d time.Duration) SystemOption {
+	return func(s *System) {
+		if d > 0 {
+			s.timeout = d
+		}
+	}
+}
+
+// WithCacheTTL reuses a probe's result for d, so that frequent probes from
+// several sources do not each hit every dependency.
+func WithCacheTTL(d time.Duration) SystemOption {
+	return func(s *System) { s.cacheTTL = d }
 }
 
 type System struct {
 	deps []Dependency
 	log  Logger
+
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	// probes holds one state per probe, so a readiness check waiting on a
+	// hung dependency never holds up the liveness probe.
+	probes map[probe]*probeState
+}
+
+type probe int
+
+const (
+	probeReady probe = iota
+	probeLive
+)
+
+// probeState serializes runs of one probe, so concurrent requests wait for
+// one run and share its result.
+type probeState struct {
+	mu     sync.Mutex
+	cached *cachedResult
 }
 
-func NewSystem(log Logger, deps ...Dependency) *System {
-	return &System{deps: deps, log: log}
+type cachedResult struct {
+	resp healthResponse
+	code int
+	at   time.Time
+}
+
+func NewSystem(log Logger, deps []Dependency, opts ...SystemOption) *System {
+	s := &System{
+		deps:     deps,
+		log:      log,
+		timeout:  2 * time.Second,
+		cacheTTL: 2 * time.Second,
+		probes:   map[probe]*probeState{probeReady: {}, probeLive: {}},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 type dependencyStatus struct {
-	Status string `json:"status"`
-	Error  string `json:"error,omitempty"`
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+	Details  any    `json:"details,omitempty"`
 }
 
 type healthResponse struct {
@@ -42,31 +114,103 @@ type healthResponse struct {
 	Dependencies map[string]dependencyStatus `json:"dependencies"`
 }
 
+// Health reports every dependency. It answers 503 only when a critical one
+// is down, like Ready.
 func (h *System) Health(ctx *gin.Context) {
+	h.serve(ctx, probeReady)
+}
+
+// Ready is the readiness probe: 503 while a critical dependency is down.
+func (h *System) Ready(ctx *gin.Context) {
+	h.serve(ctx, probeReady)
+}
+
+// Live is the liveness probe. It checks only the process itself, so an
+// outage of a dependency does not get the process restarted.
+func (h *System) Live(ctx *gin.Context) {
+	h.serve(ctx, probeLive)
+}
+
+func (h *System) serve(ctx *gin.Context, p probe) {
+	resp, code := h.check(ctx, p)
+	ctx.JSON(code, resp)
+}
+
+func (h *System) check(ctx context.Context, p probe) (healthResponse, int) {
+	ps := h.probes[p]
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if c := ps.cached; c != nil && time.Since(c.at) < h.cacheTTL {
+		return c.resp, c.code
+	}
+
+	var deps []Dependency
+	for _, d := range h.deps {
+		if p == probeReady || d.Live {
+			deps = append(deps, d)
+		}
+	}
+
+	// Checks outlive the request: the result is cached for other probes.
+	checkCtx := context.WithoutCancel(ctx)
+	statuses := make([]dependencyStatus, len(deps))
+	var wg sync.WaitGroup
+	for i, d := range deps {
+		wg.Go(func() { statuses[i] = h.checkOne(checkCtx, d) })
+	}
+	wg.Wait()
+
 	resp := healthResponse{
 		Status:       "ok",
 		Timestamp:    time.Now().UTC(),
-		Dependencies: map[string]dependencyStatus{},
+		Dependencies: make(map[string]dependencyStatus, len(deps)),
 	}
 	code := http.StatusOK
+	for i, d := range deps {
+		st := statuses[i]
+		resp.Dependencies[d.Name] = st
+		if st.Status == "ok" {
+			continue
+		}
+		if st.Status == "down" && (d.Critical || p == probeLive) {
+			resp.Status = "down"
+			code = http.StatusServiceUnavailable
+		} else if resp.Status == "ok" {
+			resp.Status = "degraded"
+		}
+	}
 
-	for _, d := range h.deps {
-		err := d.Pinger.Ping(ctx)
+	ps.cached = &cachedResult{resp: resp, code: code, at: time.Now()}
+	return resp, code
+}
 
-		if err != nil {
-			resp.Status = "degraded"
-			resp.Dependencies[d.Name] = dependencyStatus{Status: "down", Error: err.Error()}
-			code = http.StatusServiceUnavailable
+func (h *System) checkOne(ctx context.Context, d Dependency) dependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	st := dependencyStatus{Status: "ok", Critical: d.Critical}
+	if d.Pinger != nil {
+		if err := d.Pinger.Ping(ctx); err != nil {
+			st.Status = "down"
+			st.Error = err.Error()
 			if h.log != nil {
-				h.log.Error(ctx, "health check failed", "component", d.Name, "error", err)
+				h.log.Error(ctx, "health check failed", "component", d.Name, "critical", d.Critical, "error", err)
 			}
-		} else {
-			resp.Dependencies[d.Name] = dependencyStatus{Status: "ok"}
+			return st
+		}
+	}
+
+	if d.Reporter != nil {
+		details, err := d.Reporter.Report(ctx)
+		st.Details = details
+		if err != nil {
+			st.Status = "degraded"
+			st.Error = err.Error()
 			if h.log != nil {
-				h.log.Info(ctx, "health check ok", "component", d.Name)
+				h.log.Error(ctx, "health check degraded", "component", d.Name, "error", err)
 			}
 		}
 	}
-
-	ctx.JSON(code, resp)
+	return st
 }
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingPinger struct {
+	err   error
+	calls atomic.Int32
+}
+
+func (p *countingPinger) Ping(context.Context) error {
+	p.calls.Add(1)
+	return p.err
+}
+
+type slowPinger struct{}
+
+func (slowPinger) Ping(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestSystem_Criticality(t *testing.T) {
+	db := &countingPinger{}
+	cache := &countingPinger{err: errors.New("connection refused")}
+	worker := &countingPinger{}
+
+	s := NewSystem(nil, []Dependency{
+		{Name: "postgres", Pinger: db, Critical: true},
+		{Name: "redis_cache", Pinger: cache},
+		{Name: "webhook_worker", Pinger: worker, Live: true},
+	}, WithCacheTTL(time.Minute))
+	ctx := context.Background()
+
+	resp, code := s.check(ctx, probeReady)
+	if code != http.StatusOK || resp.Status != "degraded" {
+		t.Fatalf("optional dependency down: got %d %q, want 200 degraded", code, resp.Status)
+	}
+
+	resp, code = s.check(ctx, probeLive)
+	if code != http.StatusOK || len(resp.Dependencies) != 1 {
+		t.Fatalf("liveness must check only live dependencies: got %d %v", code, resp.Dependencies)
+	}
+
+	s.check(ctx, probeReady)
+	if n := db.calls.Load(); n != 1 {
+		t.Fatalf("expected the cached readiness result to be reused, postgres pinged %d times", n)
+	}
+
+	db.err = errors.New("connection refused")
+	s = NewSystem(nil, []Dependency{{Name: "postgres", Pinger: db, Critical: true}})
+	if _, code := s.check(ctx, probeReady); code != http.StatusServiceUnavailable {
+		t.Fatalf("critical dependency down: got %d, want 503", code)
+	}
+}
+
+func TestSystem_CheckTimeout(t *testing.T) {
+	s := NewSystem(nil, []Dependency{
+		{Name: "a", Pinger: slowPinger{}, Critical: true},
+		{Name: "b", Pinger: slowPinger{}, Critical: true},
+	}, WithCheckTimeout(50*time.Millisecond))
+
+	start := time.Now()
+	_, code := s.check(context.Background(), probeReady)
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("timed out checks must fail, got %d", code)
+	}
+	if d := time.Since(start); d > 90*time.Millisecond {
+		t.Fatalf("checks did not run concurrently with their timeout: took %s", d)
+	}
+}
+
+func TestSystem_LivenessDoesNotWaitForReadiness(t *testing.T) {
+	s := NewSystem(nil, []Dependency{
+		{Name: "postgres", Pinger: slowPinger{}, Critical: true},
+		{Name: "webhook_worker", Pinger: &countingPinger{}, Live: true},
+	}, WithCheckTimeout(time.Second))
+
+	ready := make(chan struct{})
+	go func() {
+		defer close(ready)
+		s.check(context.Background(), probeReady)
+	}()
+	// Let the readiness check start pinging the hung dependency.
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	if _, code := s.check(context.Background(), probeLive); code != http.StatusOK {
+		t.Fatalf("liveness: got %d, want 200", code)
+	}
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Fatalf("liveness waited %s for the readiness check", d)
+	}
+	<-ready
+}
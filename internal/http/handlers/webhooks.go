@@ -0,0 +1,395 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	webhooksapp "github.com/m1ll3r1337/geo-notifications-service/internal/app/webhooks"
+	webhooksdom "github.com/m1ll3r1337/geo-notifications-service/internal/domain/webhooks"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+)
+
+type Webhooks struct {
+	svc *webhooksapp.Service
+}
+
+func NewWebhooks(svc *webhooksapp.Service) *Webhooks {
+	return &Webhooks{svc: svc}
+}
+
+type payloadTemplate struct {
+	Body        string            `json:"body,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+}
+
+func (t *payloadTemplate) toDomain() webhooksdom.Template {
+	if t == nil {
+		return webhooksdom.Template{}
+	}
+	return webhooksdom.Template{Body: t.Body, Headers: t.Headers, ContentType: t.ContentType}
+}
+
+type batching struct {
+	MaxItems  int   `json:"max_items"`
+	MaxWaitMS int64 `json:"max_wait_ms"`
+}
+
+func (b *batching) toDomain() webhooksdom.Batching {
+	if b == nil {
+		return webhooksdom.Batching{}
+	}
+	return webhooksdom.Batching{MaxItems: b.MaxItems, MaxWait: time.Duration(b.MaxWaitMS) * time.Millisecond}
+}
+
+type tlsRequest struct {
+	CACert     string `json:"ca_cert"`
+	ClientCert string `json:"client_cert"`
+	ClientKey  string `json:"client_key"`
+}
+
+func (t *tlsRequest) toDomain() webhooksdom.TLS {
+	if t == nil {
+		return webhooksdom.TLS{}
+	}
+	return webhooksdom.TLS{CACert: t.CACert, ClientCert: t.ClientCert, ClientKey: t.ClientKey}
+}
+
+// tlsResponse never echoes the client key.
+type tlsResponse struct {
+	CACert       string `json:"ca_cert,omitempty"`
+	ClientCert   string `json:"client_cert,omitempty"`
+	HasClientKey bool   `json:"has_client_key"`
+}
+
+type verificationResponse struct {
+	Status     string     `json:"status"`
+	Attempts   int        `json:"attempts,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+}
+
+type subscriptionResponse struct {
+	ID           int64                `json:"id"`
+	URL          string               `json:"url"`
+	PayloadMode  string               `json:"payload_mode"`
+	Template     *payloadTemplate     `json:"template,omitempty"`
+	Batching     *batching            `json:"batching,omitempty"`
+	TLS          *tlsResponse         `json:"tls,omitempty"`
+	Active       bool                 `json:"active"`
+	Verification verificationResponse `json:"verification"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+}
+
+func toSubscriptionResponse(s webhooksdom.Subscription) subscriptionResponse {
+	out := subscriptionResponse{
+		ID:          s.ID,
+		URL:         s.URL,
+		PayloadMode: string(s.PayloadMode),
+		Active:      s.Active,
+		Verification: verificationResponse{
+			Status:     string(s.Verification.Status),
+			Attempts:   s.Verification.Attempts,
+			LastError:  s.Verification.LastError,
+			VerifiedAt: s.Verification.VerifiedAt,
+		},
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+	}
+	if !s.Template.IsZero() {
+		out.Template = &payloadTemplate{
+			Body:        s.Template.Body,
+			Headers:     s.Template.Headers,
+			ContentType: s.Template.ContentType,
+		}
+	}
+	if s.Batching.Enabled() {
+		out.Batching = &batching{
+			MaxItems:  s.Batching.MaxItems,
+			MaxWaitMS: s.Batching.MaxWait.Milliseconds(),
+		}
+	}
+	if !s.TLS.IsZero() {
+		out.TLS = &tlsResponse{
+			CACert:       s.TLS.CACert,
+			ClientCert:   s.TLS.ClientCert,
+			HasClientKey: s.TLS.ClientKey != "",
+		}
+	}
+	return out
+}
+
+type createSubscriptionRequest struct {
+	URL         string           `json:"url" binding:"required"`
+	PayloadMode string           `json:"payload_mode"`
+	Template    *payloadTemplate `json:"template"`
+	Batching    *batching        `json:"batching"`
+	TLS         *tlsRequest      `json:"tls"`
+}
+
+func (h *Webhooks) Create(ctx *gin.Context) {
+	const op = "webhooks.http.create"
+
+	var req createSubscriptionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_JSON", op, "invalid json", nil, err))
+		return
+	}
+
+	sub, err := h.svc.Create(ctx.Request.Context(), webhooksdom.CreateSubscription{
+		URL:         req.URL,
+		PayloadMode: webhooksdom.PayloadMode(req.PayloadMode),
+		Template:    req.Template.toDomain(),
+		Batching:    req.Batching.toDomain(),
+		TLS:         req.TLS.toDomain(),
+	})
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, toSubscriptionResponse(sub))
+}
+
+func (h *Webhooks) GetByID(ctx *gin.Context) {
+	const op = "webhooks.http.get_by_id"
+
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, err))
+		return
+	}
+
+	sub, err := h.svc.GetByID(ctx.Request.Context(), id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, toSubscriptionResponse(sub))
+}
+
+func (h *Webhooks) List(ctx *gin.Context) {
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	activeOnly, _ := strconv.ParseBool(ctx.DefaultQuery("active_only", "false"))
+
+	items, err := h.svc.List(ctx.Request.Context(), webhooksdom.ListFilter{
+		Limit:      limit,
+		Offset:     offset,
+		ActiveOnly: activeOnly,
+	})
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	out := make([]subscriptionResponse, 0, len(items))
+	for _, it := range items {
+		out = append(out, toSubscriptionResponse(it))
+	}
+	ctx.JSON(http.StatusOK, out)
+}
+
+type updateSubscriptionRequest struct {
+	URL         *string          `json:"url"`
+	PayloadMode *string          `json:"payload_mode"`
+	Template    *payloadTemplate `json:"template"`
+	Batching    *batching        `json:"batching"`
+	TLS         *tlsRequest      `json:"tls"`
+	Active      *bool            `json:"active"`
+}
+
+func (h *Webhooks) Update(ctx *gin.Context) {
+	const op = "webhooks.http.update"
+
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, err))
+		return
+	}
+
+	var req updateSubscriptionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_JSON", op, "invalid json", nil, err))
+		return
+	}
+
+	var mode *webhooksdom.PayloadMode
+	if req.PayloadMode != nil {
+		m := webhooksdom.PayloadMode(*req.PayloadMode)
+		mode = &m
+	}
+
+	var tpl *webhooksdom.Template
+	if req.Template != nil {
+		t := req.Template.toDomain()
+		tpl = &t
+	}
+
+	var batch *webhooksdom.Batching
+	if req.Batching != nil {
+		b := req.Batching.toDomain()
+		batch = &b
+	}
+
+	var tlsCfg *webhooksdom.TLS
+	if req.TLS != nil {
+		t := req.TLS.toDomain()
+		tlsCfg = &t
+	}
+
+	sub, err := h.svc.Update(ctx.Request.Context(), id, webhooksdom.UpdateSubscription{
+		URL:         req.URL,
+		PayloadMode: mode,
+		Template:    tpl,
+		Batching:    batch,
+		TLS:         tlsCfg,
+		Active:      req.Active,
+	})
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, toSubscriptionResponse(sub))
+}
+
+func (h *Webhooks) Delete(ctx *gin.Context) {
+	const op = "webhooks.http.delete"
+
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, err))
+		return
+	}
+
+	if err := h.svc.Delete(ctx.Request.Context(), id); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// Verify restarts the verification handshake of a subscription.
+func (h *Webhooks) Verify(ctx *gin.Context) {
+	const op = "webhooks.http.verify"
+
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, err))
+		return
+	}
+
+	sub, err := h.svc.Verify(ctx.Request.Context(), id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, toSubscriptionResponse(sub))
+}
+
+type deliveryResponse struct {
+	OutboxID  int64     `json:"outbox_id"`
+	EventID   string    `json:"event_id"`
+	EventType string    `json:"event_type"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (h *Webhooks) Deliveries(ctx *gin.Context) {
+	const op = "webhooks.http.deliveries"
+
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, err))
+		return
+	}
+
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+
+	items, err := h.svc.Deliveries(ctx.Request.Context(), id, webhooksdom.DeliveryFilter{
+		Status: webhooksdom.DeliveryStatus(ctx.Query("status")),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	out := make([]deliveryResponse, 0, len(items))
+	for _, it := range items {
+		out = append(out, deliveryResponse{
+			OutboxID:  it.OutboxID,
+			EventID:   it.EventID,
+			EventType: it.EventType,
+			Status:    string(it.Status),
+			Attempts:  it.Attempts,
+			LastError: it.LastError,
+			CreatedAt: it.CreatedAt,
+			UpdatedAt: it.UpdatedAt,
+		})
+	}
+	ctx.JSON(http.StatusOK, out)
+}
+
+type previewRequest struct {
+	EventType   string           `json:"event_type"`
+	Event       *events.Envelope `json:"event"`
+	ContentMode string           `json:"content_mode"`
+}
+
+type previewResponse struct {
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// Preview renders a sample event, or the event from the request body, the
+// way it would be delivered to the subscription.
+func (h *Webhooks) Preview(ctx *gin.Context) {
+	const op = "webhooks.http.preview"
+
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_ID", op, "invalid id", map[string]string{"id": "must be > 0"}, err))
+		return
+	}
+
+	var req previewRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.Error(errs.E(errs.KindInvalid, "INVALID_JSON", op, "invalid json", nil, err))
+			return
+		}
+	}
+
+	mode, err := events.ParseMode(req.ContentMode)
+	if err != nil {
+		ctx.Error(errs.E(errs.KindInvalid, "INVALID_CONTENT_MODE", op, "invalid content mode", map[string]string{"content_mode": "must be structured or binary"}, err))
+		return
+	}
+
+	p, err := h.svc.Preview(ctx.Request.Context(), id, req.EventType, req.Event, mode)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	headers := make(map[string]string, len(p.Headers))
+	for k := range p.Headers {
+		headers[k] = p.Headers.Get(k)
+	}
+	ctx.JSON(http.StatusOK, previewResponse{Headers: headers, Body: string(p.Body)})
+}
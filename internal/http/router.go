@@ -1,6 +1,8 @@
 package http
 
 import (
+	nethttp "net/http"
+
 	"github.com/gin-gonic/gin"
 
 	"github.com/m1ll3r1337/geo-notifications-service/internal/http/handlers"
@@ -8,7 +10,38 @@ import (
 	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/middleware"
 )
 
-func NewRouter(log *logger.Logger, level logger.Level, incidents *handlers.Incidents, system *handlers.System, apiKey string) *gin.Engine {
+// Metrics records request metrics and serves everything collected on
+// /metrics.
+type Metrics interface {
+	middleware.HTTPMetrics
+	Handler() nethttp.Handler
+}
+
+// NewRouter serves the API. /metrics sits behind the API key like the rest
+// of the API, since this listener is public.
+func NewRouter(log *logger.Logger, level logger.Level, metrics Metrics, incidents *handlers.Incidents, webhooks *handlers.Webhooks, outbox *handlers.Outbox, system *handlers.System, apiKey string) *gin.Engine {
+	r := newEngine(log, level, metrics)
+	r.GET("/metrics", middleware.APIKey(apiKey), gin.WrapH(metrics.Handler()))
+	setupRoutes(r, incidents, webhooks, outbox, system, apiKey)
+	return r
+}
+
+// NewHealthRouter serves only the probes and metrics, for processes that
+// run workers without the API and are not exposed publicly.
+func NewHealthRouter(log *logger.Logger, level logger.Level, metrics Metrics, system *handlers.System) *gin.Engine {
+	r := newEngine(log, level, metrics)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	setupProbes(r, system)
+	return r
+}
+
+func setupProbes(r *gin.Engine, system *handlers.System) {
+	r.GET("/livez", system.Live)
+	r.GET("/readyz", system.Ready)
+	r.GET("/api/v1/health", system.Health)
+}
+
+func newEngine(log *logger.Logger, level logger.Level, metrics Metrics) *gin.Engine {
 	if level == logger.LevelDebug {
 		gin.SetMode(gin.DebugMode)
 	} else {
@@ -19,18 +52,19 @@ func NewRouter(log *logger.Logger, level logger.Level, incidents *handlers.Incid
 
 	// Order matters
 	r.Use(middleware.RequestID())
+	r.Use(middleware.Tracing())
+	r.Use(middleware.Metrics(metrics))
 	r.Use(middleware.GinStructuredLogger(log, level))
 	r.Use(middleware.Error(log))
 	r.Use(middleware.Recovery(log))
 
-	setupRoutes(r, incidents, system, apiKey)
 	return r
 }
 
-func setupRoutes(r *gin.Engine, incidents *handlers.Incidents, system *handlers.System, apiKey string) {
-	v1 := r.Group("/api/v1")
+func setupRoutes(r *gin.Engine, incidents *handlers.Incidents, webhooks *handlers.Webhooks, outbox *handlers.Outbox, system *handlers.System, apiKey string) {
+	setupProbes(r, system)
 
-	v1.GET("/health", system.Health)
+	v1 := r.Group("/api/v1")
 
 	protected := v1.Group("", middleware.APIKey(apiKey))
 	inc := protected.Group("/incidents")
@@ -44,6 +78,27 @@ func setupRoutes(r *gin.Engine, incidents *handlers.Incidents, system *handlers.
 		inc.GET("/stats", incidents.Stats)
 	}
 
+	subs := protected.Group("/webhooks/subscriptions")
+	{
+		subs.POST("", webhooks.Create)
+		subs.GET("", webhooks.List)
+		subs.GET("/:id", webhooks.GetByID)
+		subs.PATCH("/:id", webhooks.Update)
+		subs.DELETE("/:id", webhooks.Delete)
+		subs.POST("/:id/preview", webhooks.Preview)
+		subs.GET("/:id/deliveries", webhooks.Deliveries)
+		subs.POST("/:id/verify", webhooks.Verify)
+	}
+
+	ob := protected.Group("/admin/outbox")
+	{
+		ob.GET("/events", outbox.List)
+		ob.GET("/events/:id", outbox.GetByID)
+		ob.POST("/events/requeue", outbox.Requeue)
+		ob.POST("/events/purge", outbox.Purge)
+		ob.GET("/summary", outbox.Summary)
+	}
+
 	v1.POST("/location/check", incidents.Check)
 
 }
@@ -0,0 +1,66 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker_OpensAfterThreshold(t *testing.T) {
+	b := New(Config{FailureThreshold: 2, OpenTimeout: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Allow #%d: %v", i, err)
+		}
+		b.Failure()
+	}
+
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("expected ErrOpen, got %v", err)
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("expected state=open, got %s", b.State())
+	}
+}
+
+func TestBreaker_HalfOpenProbe(t *testing.T) {
+	now := time.Now()
+	b := New(Config{FailureThreshold: 1, OpenTimeout: time.Second, HalfOpenMaxCalls: 1})
+	b.now = func() time.Time { return now }
+
+	_ = b.Allow()
+	b.Failure()
+
+	now = now.Add(2 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected probe to be allowed, got %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("expected second probe to be rejected, got %v", err)
+	}
+
+	b.Success()
+	if b.State() != StateClosed {
+		t.Fatalf("expected state=closed, got %s", b.State())
+	}
+}
+
+func TestBreaker_HalfOpenFailureReopens(t *testing.T) {
+	now := time.Now()
+	b := New(Config{FailureThreshold: 1, OpenTimeout: time.Second})
+	b.now = func() time.Time { return now }
+
+	_ = b.Allow()
+	b.Failure()
+
+	now = now.Add(2 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected probe to be allowed, got %v", err)
+	}
+	b.Failure()
+
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("expected ErrOpen after failed probe, got %v", err)
+	}
+}
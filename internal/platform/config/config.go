@@ -8,6 +8,9 @@ import (
 )
 
 type Config struct {
+	// Env is the deployment environment; "production" enables stricter
+	// defaults such as https-only webhooks.
+	Env  string `default:"development"`
 	HTTP struct {
 		Addr string `default:":8080"`
 	}
@@ -15,7 +18,92 @@ type Config struct {
 		Level string `default:"info"`
 	}
 	Cache struct {
-		ActiveIncidentsTTLSeconds int `default:"60"`
+		// Enabled caches active incidents in Redis.
+		Enabled                   bool `default:"true"`
+		ActiveIncidentsTTLSeconds int  `default:"60"`
+		// Timeout bounds every cache call. After BreakerFailureThreshold
+		// consecutive failures the cache is bypassed for
+		// BreakerOpenTimeout, then probed again.
+		Timeout                 time.Duration `default:"100ms"`
+		BreakerFailureThreshold int           `default:"5"`
+		BreakerOpenTimeout      time.Duration `default:"10s"`
+
+		// Location checks are cached per geohash cell of CellPrecision
+		// characters: 5 is about 4.9×4.9 km, 6 about 1.2×0.6 km; 0 turns
+		// the cell cache off. A write to an incident reaching into more than
+		// CellInvalidateLimit cells drops every cell. CellTTL bounds how long
+		// other replicas may serve a cell whose invalidation failed, so it
+		// matches the list TTL.
+		CellPrecision       int           `default:"5"`
+		CellTTL             time.Duration `default:"1m"`
+		CellInvalidateLimit int           `default:"256"`
+	}
+	Index struct {
+		// Enabled keeps the active incidents in memory in every API
+		// instance and matches location checks there instead of in
+		// PostGIS. The index reloads on every change to an incident;
+		// PollInterval applies while LISTEN is unavailable, SafetyInterval
+		// in case a notification was missed. Once the last successful
+		// load is older than MaxAge, checks go to PostGIS again.
+		Enabled        bool          `default:"false"`
+		PollInterval   time.Duration `default:"5s"`
+		SafetyInterval time.Duration `default:"1m"`
+		MaxAge         time.Duration `default:"3m"`
+		// VerifyRate is the share of checks (0 to 1) repeated in PostGIS in
+		// the background to confirm the index agrees.
+		VerifyRate float64 `default:"0"`
+	}
+	Queue struct {
+		// Backend carries events from the outbox relay to the webhook
+		// worker: redis (Redis Streams), postgres (a table claimed with
+		// SKIP LOCKED) or memory (in-process, single node only).
+		Backend string `default:"redis"`
+		// Partitions is how many streams the redis backend spreads events
+		// over by ordering key. Each partition is read by one consumer at
+		// a time, holding a lease renewed every PartitionLease/3. With one
+		// partition the stream keeps its unpartitioned name.
+		Partitions     int           `default:"1"`
+		PartitionLease time.Duration `default:"15s"`
+		// TrimInterval is how often acknowledged entries are trimmed from
+		// the redis streams. MaxBacklog is how many unacknowledged entries
+		// they may hold before the health check reports degraded; entries
+		// are never dropped to stay under it.
+		TrimInterval time.Duration `default:"30s"`
+		MaxBacklog   int64         `default:"100000"`
+	}
+	Leader struct {
+		// Enabled runs the relay's singleton jobs (outbox relay, stream
+		// trimming, retention cleanup) on one instance at a time, elected
+		// through a lease in Postgres that expires TTL after its holder
+		// stops renewing it.
+		Enabled bool          `default:"true"`
+		TTL     time.Duration `default:"15s"`
+		Holder  string        // defaults to <hostname>-<pid>
+	}
+	Health struct {
+		// CheckTimeout bounds each dependency check; results are reused
+		// for CacheTTL.
+		CheckTimeout time.Duration `default:"2s"`
+		CacheTTL     time.Duration `default:"2s"`
+		// HeartbeatTimeout is how long the relay and webhook worker loops
+		// may go without progress before /livez fails. It must exceed the
+		// relay's safety interval.
+		HeartbeatTimeout time.Duration `default:"60s"`
+		// OutboxMaxPending and OutboxMaxAge mark the relay degraded when
+		// the outbox holds more pending events, or an older one, than
+		// this. Zero disables the check.
+		OutboxMaxPending int64         `default:"10000"`
+		OutboxMaxAge     time.Duration `default:"5m"`
+	}
+	Tracing struct {
+		// Enabled exports OpenTelemetry spans over OTLP/HTTP to Endpoint
+		// (host:port; the OTEL_EXPORTER_OTLP_* variables apply when empty).
+		// Trace context is propagated either way.
+		Enabled     bool    `default:"false"`
+		Endpoint    string  `default:""`
+		Insecure    bool    `default:"true"`
+		ServiceName string  `default:"geo-notifications"`
+		SampleRatio float64 `default:"1"`
 	}
 	DB struct {
 		URL             string        `required:"true"`
@@ -34,6 +122,10 @@ type Config struct {
 	}
 	Security struct {
 		ApiKey string `default:"secret"`
+		// SecretKey is a base64-encoded 32-byte key encrypting the TLS
+		// client keys of webhook subscriptions at rest. Without it they
+		// are stored as given.
+		SecretKey string
 	}
 	Stats struct {
 		TimeWindowMinutes int `default:"60"`
@@ -42,15 +134,85 @@ type Config struct {
 		Webhook struct {
 			Stream   string `default:"webhook_events"`
 			Group    string `default:"webhook_group"`
-			Consumer string `default:"webhook_consumer"`
-			URL      string `default:"http://localhost:9090/webhook"`
+			Consumer string // defaults to <hostname>-<pid>
+			// URL, when set, receives every event in addition to the
+			// subscriptions managed through the API.
+			URL string
+
+			SubscriptionsRefresh time.Duration `default:"5s"`
+
+			// VerifyInterval is how often pending subscriptions are
+			// challenged. Verified subscriptions whose deliveries have all
+			// failed for ReverifyAfter are challenged again.
+			VerifyInterval time.Duration `default:"30s"`
+			ReverifyAfter  time.Duration `default:"1h"`
+
+			// ContentMode is the CloudEvents HTTP binding: structured or binary.
+			ContentMode string `default:"structured"`
+
+			Timeout       time.Duration `default:"5s"`
+			Concurrency   int           `default:"8"`
+			DrainTimeout  time.Duration `default:"8s"`
+			ReclaimIdle   time.Duration `default:"30s"`
+			MaxDeliveries int           `default:"10"`
+
+			DestinationConcurrency    int           `default:"4"`
+			DestinationRPS            float64       `default:"0"`
+			DestinationBurst          int           `default:"1"`
+			DestinationAcquireTimeout time.Duration `default:"1s"`
+
+			BreakerFailureThreshold int           `default:"5"`
+			BreakerOpenTimeout      time.Duration `default:"30s"`
+			BreakerHalfOpenMaxCalls int           `default:"1"`
+
+			// TLS* are PEM files for destinations behind an internal CA or
+			// requiring client certificates. Subscriptions may override them
+			// with their own certificates; changed files are picked up
+			// without a restart.
+			TLSCAFile   string
+			TLSCertFile string
+			TLSKeyFile  string
+
+			// URLAllowCIDRs, when set, is the only address space webhook
+			// subscriptions may deliver to; it is also how private ranges
+			// are opened. URLDenyCIDRs is blocked in addition to loopback,
+			// link-local, private (RFC 1918, 100.64.0.0/10, 0.0.0.0/8,
+			// fc00::/7) and metadata addresses.
+			URLAllowCIDRs []string
+			URLDenyCIDRs  []string
 		}
 		OutboxRelay struct {
 			Stream string `default:"webhook_events"`
+
+			// PollInterval applies while LISTEN is unavailable; with a
+			// connected listener the outbox is only polled every
+			// SafetyInterval in case a notification was missed.
+			PollInterval   time.Duration `default:"500ms"`
+			SafetyInterval time.Duration `default:"15s"`
+		}
+		Cleanup struct {
+			Interval   time.Duration `default:"1h"`
+			BatchSize  int           `default:"5000"`
+			BatchPause time.Duration `default:"100ms"`
+
+			// Retention per table; zero keeps rows forever. Location checks
+			// must outlive Stats.TimeWindowMinutes.
+			OutboxDispatchedRetention time.Duration `default:"168h"`
+			OutboxDeadRetention       time.Duration `default:"720h"`
+			DeliveriesRetention       time.Duration `default:"720h"`
+			LocationChecksRetention   time.Duration `default:"2160h"`
+
+			// PartitionsAhead is how many future daily partitions are
+			// created for tables switched to partitioning.
+			PartitionsAhead int `default:"3"`
 		}
 	}
 }
 
+func (c Config) Production() bool {
+	return c.Env == "production"
+}
+
 func Load() (Config, error) {
 	var cfg Config
 	if err := envconfig.Process("GEO", &cfg); err != nil {
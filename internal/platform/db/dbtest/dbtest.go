@@ -0,0 +1,143 @@
+//go:build integration
+
+// Package dbtest starts a migrated Postgres for integration tests: the one in
+// TEST_DB_URL when set, otherwise a throwaway container.
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jmoiron/sqlx"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/db"
+)
+
+// Main opens the database into *dbx, runs the tests and tears it down. Call it
+// from TestMain.
+func Main(m *testing.M, dbx **sqlx.DB) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	d, terminate, err := setup(ctx)
+	cancel()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "integration setup failed:", err)
+		os.Exit(1)
+	}
+	*dbx = d
+
+	code := m.Run()
+
+	_ = d.Close()
+	if terminate != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		_ = terminate(ctx)
+		cancel()
+	}
+	os.Exit(code)
+}
+
+// Truncate empties tables before the test and again after it.
+func Truncate(t *testing.T, dbx *sqlx.DB, tables ...string) {
+	t.Helper()
+
+	truncate := func() {
+		for _, table := range tables {
+			if _, err := dbx.Exec("TRUNCATE " + table + " RESTART IDENTITY CASCADE"); err != nil {
+				t.Fatalf("truncate %s: %v", table, err)
+			}
+		}
+	}
+	truncate()
+	t.Cleanup(truncate)
+}
+
+func setup(ctx context.Context) (*sqlx.DB, func(context.Context) error, error) {
+	if dsn := os.Getenv("TEST_DB_URL"); dsn != "" {
+		dbx, err := open(ctx, dsn)
+		return dbx, nil, err
+	}
+
+	req := testcontainers.ContainerRequest{
+		Image:        "postgis/postgis:16-3.4",
+		ExposedPorts: []string{"5432/tcp"},
+		Env: map[string]string{
+			"POSTGRES_PASSWORD": "postgres",
+			"POSTGRES_USER":     "postgres",
+			"POSTGRES_DB":       "geo_test",
+		},
+		WaitingFor: wait.ForListeningPort("5432/tcp").WithStartupTimeout(90 * time.Second),
+	}
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("start container: %w", err)
+	}
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		_ = c.Terminate(context.Background())
+		return nil, nil, fmt.Errorf("container host: %w", err)
+	}
+	port, err := c.MappedPort(ctx, "5432/tcp")
+	if err != nil {
+		_ = c.Terminate(context.Background())
+		return nil, nil, fmt.Errorf("container port: %w", err)
+	}
+
+	dsn := fmt.Sprintf("postgres://postgres:postgres@%s:%s/geo_test?sslmode=disable", host, port.Port())
+	dbx, err := open(ctx, dsn)
+	if err != nil {
+		_ = c.Terminate(context.Background())
+		return nil, nil, err
+	}
+	terminate := func(ctx context.Context) error { return c.Terminate(ctx) }
+	return dbx, terminate, nil
+}
+
+func open(ctx context.Context, dsn string) (*sqlx.DB, error) {
+	dbx, err := db.Open(ctx, db.Config{URL: dsn, PingTimeout: 15 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.StatusCheck(ctx, dbx); err != nil {
+		_ = dbx.Close()
+		return nil, err
+	}
+	if err := applyMigrations(dsn); err != nil {
+		_ = dbx.Close()
+		return nil, err
+	}
+	return dbx, nil
+}
+
+func applyMigrations(dsn string) error {
+	_, thisFile, _, ok := runtime.Caller(0)
+	if !ok {
+		return fmt.Errorf("runtime.Caller failed")
+	}
+	dir := filepath.Clean(filepath.Join(filepath.Dir(thisFile), "..", "..", "..", "..", "migrations"))
+
+	m, err := migrate.New("file://"+filepath.ToSlash(dir), dsn)
+	if err != nil {
+		return fmt.Errorf("migrate init: %w", err)
+	}
+	defer func() { _, _ = m.Close() }()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migrate up: %w", err)
+	}
+	return nil
+}
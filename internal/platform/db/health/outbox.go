@@ -0,0 +1,63 @@
+package healthdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/outbox"
+	outboxdb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/outbox"
+)
+
+// ErrOutboxBacklog is reported by OutboxBacklog when events wait in the
+// outbox longer or in larger numbers than allowed, i.e. the relay is not
+// keeping up or not running.
+var ErrOutboxBacklog = errors.New("outbox backlog over limit")
+
+type OutboxBacklog struct {
+	repo       *outboxdb.Repository
+	maxPending int64
+	maxAge     time.Duration
+}
+
+// NewOutboxBacklog checks the pending outbox events against maxPending and
+// the age of the oldest against maxAge; a zero limit is not checked.
+func NewOutboxBacklog(db *sqlx.DB, maxPending int64, maxAge time.Duration) OutboxBacklog {
+	return OutboxBacklog{repo: outboxdb.New(db), maxPending: maxPending, maxAge: maxAge}
+}
+
+type outboxBacklogStatus struct {
+	Pending              int64   `json:"pending"`
+	Dead                 int64   `json:"dead"`
+	Stuck                int64   `json:"stuck"`
+	OldestPendingSeconds float64 `json:"oldest_pending_seconds"`
+}
+
+func (b OutboxBacklog) Report(ctx context.Context) (any, error) {
+	sum, err := b.repo.Summary(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	st := outboxBacklogStatus{
+		Pending: sum.Counts[outbox.StatusPending],
+		Dead:    sum.Counts[outbox.StatusDead],
+		Stuck:   sum.Stuck,
+	}
+	var age time.Duration
+	if sum.OldestPendingAt != nil {
+		age = time.Since(*sum.OldestPendingAt)
+		st.OldestPendingSeconds = age.Seconds()
+	}
+
+	if b.maxPending > 0 && st.Pending > b.maxPending {
+		return st, fmt.Errorf("%w: %d pending events", ErrOutboxBacklog, st.Pending)
+	}
+	if b.maxAge > 0 && age > b.maxAge {
+		return st, fmt.Errorf("%w: oldest pending event waits %s", ErrOutboxBacklog, age.Round(time.Second))
+	}
+	return st, nil
+}
@@ -13,6 +13,10 @@ import (
 	dberrs "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/errs"
 )
 
+// NotifyChannel is notified with the id of every created, updated or
+// deactivated incident once the writing transaction commits.
+const NotifyChannel = "incidents"
+
 type Repository struct {
 	exec sqlx.ExtContext
 }
@@ -26,6 +30,7 @@ type dbIncident struct {
 	CenterLat   float64        `db:"center_lat"`
 	CenterLon   float64        `db:"center_lon"`
 	Radius      int            `db:"radius"`
+	Severity    string         `db:"severity"`
 	Active      bool           `db:"active"`
 	CreatedAt   time.Time      `db:"created_at"`
 	UpdatedAt   time.Time      `db:"updated_at"`
@@ -40,6 +45,7 @@ func (d dbIncident) toDomain() incidents.Incident {
 			Lon: d.CenterLon,
 		},
 		Radius:    d.Radius,
+		Severity:  incidents.Severity(d.Severity),
 		Active:    d.Active,
 		CreatedAt: d.CreatedAt,
 		UpdatedAt: d.UpdatedAt,
@@ -57,6 +63,7 @@ const selectIncidentCols = `
     ST_Y(center::geometry) AS center_lat,
     ST_X(center::geometry) AS center_lon,
     radius,
+    severity,
     active,
     created_at,
     updated_at
@@ -66,9 +73,13 @@ func (r *Repository) Create(ctx context.Context, in incidents.CreateIncident) (i
 	const op = "incidents.repo.create"
 
 	const q = `
-        INSERT INTO incidents (title, description, center, radius)
-        VALUES ($1, $2, ST_MakePoint($3, $4)::geography, $5)
-        RETURNING ` + selectIncidentCols + `;
+        WITH ins AS (
+            INSERT INTO incidents (title, description, center, radius, severity)
+            VALUES ($1, $2, ST_MakePoint($3, $4)::geography, $5, COALESCE(NULLIF($6, ''), 'medium'))
+            RETURNING ` + selectIncidentCols + `
+        )
+        SELECT i.*
+        FROM ins i, LATERAL (SELECT pg_notify($7, i.id::text)) n;
     `
 
 	var row dbIncident
@@ -78,6 +89,8 @@ func (r *Repository) Create(ctx context.Context, in incidents.CreateIncident) (i
 		in.Center.Lon,
 		in.Center.Lat,
 		in.Radius,
+		string(in.Severity),
+		NotifyChannel,
 	); err != nil {
 		return incidents.Incident{}, dberrs.Map(err, op)
 	}
@@ -102,6 +115,26 @@ func (r *Repository) GetByID(ctx context.Context, id int64) (incidents.Incident,
 	return row.toDomain(), nil
 }
 
+// GetByIDForUpdate reads the incident and locks its row until the end of the
+// surrounding transaction.
+func (r *Repository) GetByIDForUpdate(ctx context.Context, id int64) (incidents.Incident, error) {
+	const op = "incidents.repo.get_by_id_for_update"
+
+	const q = `
+        SELECT ` + selectIncidentCols + `
+        FROM incidents
+        WHERE id = $1
+        FOR UPDATE;
+    `
+
+	var row dbIncident
+	if err := sqlx.GetContext(ctx, r.exec, &row, q, id); err != nil {
+		return incidents.Incident{}, dberrs.Map(err, op)
+	}
+
+	return row.toDomain(), nil
+}
+
 func (r *Repository) List(ctx context.Context, f incidents.ListFilter) ([]incidents.Incident, error) {
 	const op = "incidents.repo.list"
 	var (
@@ -143,14 +176,18 @@ func (r *Repository) Deactivate(ctx context.Context, id int64) error {
 	const op = "incidents.repo.deactivate"
 
 	const q = `
-        UPDATE incidents
-        SET active = FALSE, updated_at = NOW()
-        WHERE id = $1 AND active = TRUE
-        RETURNING id;
+        WITH upd AS (
+            UPDATE incidents
+            SET active = FALSE, updated_at = NOW()
+            WHERE id = $1 AND active = TRUE
+            RETURNING id
+        )
+        SELECT u.id
+        FROM upd u, LATERAL (SELECT pg_notify($2, u.id::text)) n;
     `
 
 	var tmp int64
-	if err := sqlx.GetContext(ctx, r.exec, &tmp, q, id); err != nil {
+	if err := sqlx.GetContext(ctx, r.exec, &tmp, q, id, NotifyChannel); err != nil {
 		return dberrs.Map(err, op)
 	}
 	return nil
@@ -183,6 +220,9 @@ func (r *Repository) Update(ctx context.Context, id int64, in incidents.UpdateIn
 	if in.Radius != nil {
 		add("radius = $%d", *in.Radius)
 	}
+	if in.Severity != nil {
+		add("severity = $%d", string(*in.Severity))
+	}
 
 	if len(setParts) == 0 {
 		return r.GetByID(ctx, id)
@@ -192,13 +232,19 @@ func (r *Repository) Update(ctx context.Context, id int64, in incidents.UpdateIn
 
 	args = append(args, id)
 	idPos := len(args)
+	args = append(args, NotifyChannel)
+	channelPos := len(args)
 
 	q := fmt.Sprintf(`
-        UPDATE incidents
-        SET %s
-        WHERE id = $%d
-        RETURNING %s;
-    `, strings.Join(setParts, ", "), idPos, selectIncidentCols)
+        WITH upd AS (
+            UPDATE incidents
+            SET %s
+            WHERE id = $%d
+            RETURNING %s
+        )
+        SELECT u.*
+        FROM upd u, LATERAL (SELECT pg_notify($%d, u.id::text)) n;
+    `, strings.Join(setParts, ", "), idPos, selectIncidentCols, channelPos)
 
 	var row dbIncident
 	if err := sqlx.GetContext(ctx, r.exec, &row, q, args...); err != nil {
@@ -220,6 +266,7 @@ type dbNearbyIncident struct {
 	Title       string    `db:"title"`
 	Description string    `db:"description"`
 	Radius      int       `db:"radius"`
+	Severity    string    `db:"severity"`
 	CenterLon   float64   `db:"center_lon"`
 	CenterLat   float64   `db:"center_lat"`
 	CreatedAt   time.Time `db:"created_at"`
@@ -235,6 +282,7 @@ func (d dbNearbyIncident) toDomain() incidents.NearbyIncident {
 		Description:    d.Description,
 		Center:         incidents.Point{Lat: d.CenterLat, Lon: d.CenterLon},
 		Radius:         d.Radius,
+		Severity:       incidents.Severity(d.Severity),
 		CreatedAt:      d.CreatedAt,
 		UpdatedAt:      d.UpdatedAt,
 	}
@@ -246,8 +294,9 @@ func (r *Repository) FindNearby(ctx context.Context, p incidents.Point, limit in
         SELECT
             i.id AS incident_id,
             i.title AS title,
-            i.description AS description,
+            COALESCE(i.description, '') AS description,
             i.radius AS radius,
+            i.severity AS severity,
             ST_X(i.center::geometry) AS center_lon,
             ST_Y(i.center::geometry) AS center_lat,
             i.created_at AS created_at,
@@ -272,6 +321,51 @@ func (r *Repository) FindNearby(ctx context.Context, p incidents.Point, limit in
 	return out, nil
 }
 
+// ListActive returns every active incident.
+func (r *Repository) ListActive(ctx context.Context) ([]incidents.Incident, error) {
+	const op = "incidents.repo.list_active"
+	const q = `SELECT ` + selectIncidentCols + ` FROM incidents WHERE active = TRUE ORDER BY id;`
+
+	var rows []dbIncident
+	if err := sqlx.SelectContext(ctx, r.exec, &rows, q); err != nil {
+		return nil, dberrs.Map(err, op)
+	}
+
+	out := make([]incidents.Incident, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, row.toDomain())
+	}
+	return out, nil
+}
+
+// boxMarginMeters widens ListActiveNear: PostGIS draws the box's edges as
+// geodesics, which bow away from the parallels a few meters at most.
+const boxMarginMeters = 50
+
+// ListActiveNear returns the active incidents whose radius reaches into b,
+// and possibly a few just outside it. b must not cross the antimeridian.
+func (r *Repository) ListActiveNear(ctx context.Context, b incidents.Box) ([]incidents.Incident, error) {
+	const op = "incidents.repo.list_active_near"
+	const q = `
+        SELECT ` + selectIncidentCols + `
+        FROM incidents
+        WHERE active = TRUE
+          AND ST_DWithin(center, ST_MakeEnvelope($1, $2, $3, $4, 4326)::geography, radius + $5)
+        ORDER BY id;
+    `
+
+	var rows []dbIncident
+	if err := sqlx.SelectContext(ctx, r.exec, &rows, q, b.MinLon, b.MinLat, b.MaxLon, b.MaxLat, boxMarginMeters); err != nil {
+		return nil, dberrs.Map(err, op)
+	}
+
+	out := make([]incidents.Incident, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, row.toDomain())
+	}
+	return out, nil
+}
+
 func (r *Repository) RecordCheck(ctx context.Context, userID string, p incidents.Point, incidentIDs []int64) (int64, error) {
 	const op = "incidents.repo.record_check"
 
@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -350,3 +351,42 @@ func TestRepository_Deactivate_NotFound_WhenAlreadyInactive(t *testing.T) {
 		t.Fatalf("expected not_found, got %T: %v", err, err)
 	}
 }
+
+func TestRepository_ListActiveNear_MatchesFindNearby(t *testing.T) {
+	ctx, tx := withTx(t)
+	repo := New(tx)
+
+	for _, in := range []incidents.CreateIncident{
+		{Title: "inside", Center: incidents.Point{Lat: 55.7558, Lon: 37.6173}, Radius: 500},
+		{Title: "reaches in", Center: incidents.Point{Lat: 55.80, Lon: 37.6173}, Radius: 6000},
+		{Title: "far", Center: incidents.Point{Lat: 56.5, Lon: 37.6173}, Radius: 500},
+	} {
+		if _, err := repo.Create(ctx, in); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	box := incidents.Box{MinLat: 55.70, MinLon: 37.55, MaxLat: 55.77, MaxLon: 37.70}
+	got, err := repo.ListActiveNear(ctx, box)
+	if err != nil {
+		t.Fatalf("ListActiveNear: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 incidents near the box, got %+v", got)
+	}
+
+	p := incidents.Point{Lat: 55.7558, Lon: 37.6173}
+	want, err := repo.FindNearby(ctx, p, 10)
+	if err != nil {
+		t.Fatalf("FindNearby: %v", err)
+	}
+	inGo := incidents.Nearby(got, p, 10)
+	if len(inGo) != len(want) {
+		t.Fatalf("Nearby in Go = %+v, PostGIS = %+v", inGo, want)
+	}
+	for i := range want {
+		if inGo[i].IncidentID != want[i].IncidentID || math.Abs(inGo[i].DistanceMeters-want[i].DistanceMeters) > 0.01 {
+			t.Fatalf("Nearby in Go = %+v, PostGIS = %+v", inGo, want)
+		}
+	}
+}
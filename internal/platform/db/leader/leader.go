@@ -0,0 +1,148 @@
+// Package leaderdb stores leader leases with fencing tokens in Postgres.
+package leaderdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+	dberrs "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/errs"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/leader"
+)
+
+type Repository struct {
+	exec sqlx.ExtContext
+}
+
+var _ leader.Store = (*Repository)(nil)
+
+func New(exec sqlx.ExtContext) *Repository { return &Repository{exec: exec} }
+
+// Acquire takes the lease name for holder, or extends it if holder already
+// has it. It fails with ok false while another holder's lease is
+// unexpired. The token is kept on renewal and incremented whenever the
+// holder changes.
+func (r *Repository) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (int64, bool, error) {
+	const op = "leader.repo.acquire"
+
+	const q = `
+        INSERT INTO leader_leases (name, holder, token, acquired_at, expires_at)
+        VALUES ($1, $2, 1, NOW(), NOW() + make_interval(secs => $3))
+        ON CONFLICT (name) DO UPDATE
+        SET holder      = EXCLUDED.holder,
+            token       = CASE WHEN leader_leases.holder = EXCLUDED.holder
+                               THEN leader_leases.token
+                               ELSE leader_leases.token + 1 END,
+            acquired_at = CASE WHEN leader_leases.holder = EXCLUDED.holder
+                               THEN leader_leases.acquired_at
+                               ELSE NOW() END,
+            expires_at  = EXCLUDED.expires_at
+        WHERE leader_leases.holder = EXCLUDED.holder
+           OR leader_leases.expires_at <= NOW()
+        RETURNING token;
+    `
+	var token int64
+	err := sqlx.GetContext(ctx, r.exec, &token, q, name, holder, ttl.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, dberrs.Map(err, op)
+	}
+	return token, true, nil
+}
+
+// Release ends holder's lease on name so another instance can take over
+// without waiting for it to expire.
+func (r *Repository) Release(ctx context.Context, name, holder string) error {
+	const op = "leader.repo.release"
+
+	const q = `
+        UPDATE leader_leases
+        SET expires_at = NOW()
+        WHERE name = $1 AND holder = $2;
+    `
+	if _, err := r.exec.ExecContext(ctx, q, name, holder); err != nil {
+		return dberrs.Map(err, op)
+	}
+	return nil
+}
+
+type dbLease struct {
+	Holder     string    `db:"holder"`
+	Token      int64     `db:"token"`
+	AcquiredAt time.Time `db:"acquired_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
+}
+
+// Current returns the lease on name. It fails with a not found error when
+// name was never acquired.
+func (r *Repository) Current(ctx context.Context, name string) (leader.Lease, error) {
+	const op = "leader.repo.current"
+
+	const q = `
+        SELECT holder, token, acquired_at, expires_at
+        FROM leader_leases
+        WHERE name = $1;
+    `
+	var l dbLease
+	if err := sqlx.GetContext(ctx, r.exec, &l, q, name); err != nil {
+		return leader.Lease{}, dberrs.Map(err, op)
+	}
+	return leader.Lease{
+		Name:       name,
+		Holder:     l.Holder,
+		Token:      l.Token,
+		AcquiredAt: l.AcquiredAt,
+		ExpiresAt:  l.ExpiresAt,
+	}, nil
+}
+
+// Fence checks, inside the caller's transaction, that a lease is still held
+// with the given token. The row is locked FOR SHARE, so the lease cannot
+// change hands before that transaction ends.
+//
+// Only what happens inside that transaction is fenced. The relay checks it
+// when claiming a batch; a deposed leader still publishes and marks the
+// batch it claimed before losing the lease, so those events may be
+// published twice.
+type Fence struct {
+	name   string
+	holder string
+	token  func() (int64, bool)
+}
+
+// NewFence returns a fence for name held by holder; token reports the
+// holder's current token and whether it leads.
+func NewFence(name, holder string, token func() (int64, bool)) Fence {
+	return Fence{name: name, holder: holder, token: token}
+}
+
+func (f Fence) Check(ctx context.Context, exec sqlx.ExtContext) error {
+	const op = "leader.repo.fence"
+
+	token, ok := f.token()
+	if !ok {
+		return errs.E(errs.KindConflict, "NOT_LEADER", op, "not the leader", nil, nil)
+	}
+
+	const q = `
+        SELECT 1
+        FROM leader_leases
+        WHERE name = $1 AND holder = $2 AND token = $3 AND expires_at > NOW()
+        FOR SHARE;
+    `
+	var one int
+	err := sqlx.GetContext(ctx, exec, &one, q, f.name, f.holder, token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errs.E(errs.KindConflict, "NOT_LEADER", op, "lease lost", nil, nil)
+	}
+	if err != nil {
+		return dberrs.Map(err, op)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+//go:build integration
+
+package leaderdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/dbtest"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/leader"
+)
+
+var testDB *sqlx.DB
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m, &testDB)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Info(context.Context, string, ...any)  {}
+func (nopLogger) Error(context.Context, string, ...any) {}
+
+func acquire(t *testing.T, repo *Repository, holder string, ttl time.Duration) (int64, bool) {
+	t.Helper()
+	token, ok, err := repo.Acquire(context.Background(), "relay", holder, ttl)
+	if err != nil {
+		t.Fatalf("acquire by %s: %v", holder, err)
+	}
+	return token, ok
+}
+
+func checkFence(t *testing.T, holder string, token int64) error {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := testDB.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	return NewFence("relay", holder, func() (int64, bool) { return token, true }).Check(ctx, tx)
+}
+
+func TestRepository_TakeoverAfterExpiry(t *testing.T) {
+	dbtest.Truncate(t, testDB, "leader_leases")
+	repo := New(testDB)
+	const ttl = 500 * time.Millisecond
+
+	if token, ok := acquire(t, repo, "a", ttl); !ok || token != 1 {
+		t.Fatalf("a: expected token 1, got %d, %v", token, ok)
+	}
+	if _, ok := acquire(t, repo, "b", ttl); ok {
+		t.Fatalf("b acquired a lease held by a")
+	}
+	// Renewal keeps the token.
+	if token, ok := acquire(t, repo, "a", ttl); !ok || token != 1 {
+		t.Fatalf("a renewal: expected token 1, got %d, %v", token, ok)
+	}
+	if err := checkFence(t, "a", 1); err != nil {
+		t.Fatalf("fence of the leader: %v", err)
+	}
+
+	time.Sleep(ttl + 100*time.Millisecond)
+
+	if token, ok := acquire(t, repo, "b", ttl); !ok || token != 2 {
+		t.Fatalf("b after expiry: expected token 2, got %d, %v", token, ok)
+	}
+	if _, ok := acquire(t, repo, "a", ttl); ok {
+		t.Fatalf("a took the lease back from b")
+	}
+
+	// The deposed leader is fenced off, the new one is not.
+	err := checkFence(t, "a", 1)
+	if e, ok := errs.As(err); !ok || e.Kind != errs.KindConflict || e.Code != "NOT_LEADER" {
+		t.Fatalf("expected the deposed leader to be fenced off, got %v", err)
+	}
+	if err := checkFence(t, "b", 2); err != nil {
+		t.Fatalf("fence of the new leader: %v", err)
+	}
+
+	// A released lease is free at once.
+	if err := repo.Release(context.Background(), "relay", "b"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if token, ok := acquire(t, repo, "a", ttl); !ok || token != 3 {
+		t.Fatalf("a after release: expected token 3, got %d, %v", token, ok)
+	}
+}
+
+func TestElector_Failover(t *testing.T) {
+	dbtest.Truncate(t, testDB, "leader_leases")
+	repo := New(testDB)
+
+	var (
+		mu      sync.Mutex
+		leaders []string
+	)
+	job := func(holder string) leader.Job {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			leaders = append(leaders, holder)
+			mu.Unlock()
+			<-ctx.Done()
+			return nil
+		}
+	}
+
+	newElector := func(holder string) (*leader.Elector, context.CancelFunc, <-chan struct{}) {
+		e := leader.New(repo, "relay", nopLogger{}, leader.WithHolder(holder), leader.WithTTL(300*time.Millisecond))
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = e.Run(ctx, job(holder))
+		}()
+		return e, cancel, done
+	}
+
+	a, stopA, doneA := newElector("a")
+	defer func() { stopA(); <-doneA }()
+	waitLeading(t, a)
+
+	b, stopB, doneB := newElector("b")
+	defer func() { stopB(); <-doneB }()
+	time.Sleep(400 * time.Millisecond)
+	if _, ok := b.Token(); ok {
+		t.Fatalf("b leads while a holds the lease")
+	}
+
+	stopA()
+	<-doneA
+	waitLeading(t, b)
+	if token, _ := b.Token(); token != 2 {
+		t.Fatalf("expected b to lead with token 2, got %d", token)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(leaders) != 2 || leaders[0] != "a" || leaders[1] != "b" {
+		t.Fatalf("expected jobs of a then b, got %v", leaders)
+	}
+}
+
+func waitLeading(t *testing.T, e *leader.Elector) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := e.Token(); ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s never became the leader", e.Holder())
+}
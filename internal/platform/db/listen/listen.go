@@ -0,0 +1,127 @@
+// Package listendb turns Postgres NOTIFY messages on a channel into wakeup
+// signals, using a dedicated connection outside the sqlx pool.
+package listendb
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type Logger interface {
+	Info(ctx context.Context, msg string, args ...any)
+	Error(ctx context.Context, msg string, args ...any)
+}
+
+const (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = 30 * time.Second
+)
+
+// conn is the part of *pgx.Conn the listener uses.
+type conn interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Close(ctx context.Context) error
+}
+
+func connect(ctx context.Context, url string) (conn, error) {
+	c, err := pgx.Connect(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Listener LISTENs on a channel and signals C for every notification.
+// Signals are coalesced: a consumer that was busy sees one pending signal no
+// matter how many notifications arrived meanwhile.
+type Listener struct {
+	url     string
+	channel string
+	log     Logger
+
+	connect    func(ctx context.Context, url string) (conn, error)
+	minBackoff time.Duration
+
+	c         chan struct{}
+	connected atomic.Bool
+}
+
+func New(url, channel string, log Logger) *Listener {
+	return &Listener{
+		url:        url,
+		channel:    channel,
+		log:        log,
+		connect:    connect,
+		minBackoff: minReconnectBackoff,
+		c:          make(chan struct{}, 1),
+	}
+}
+
+func (l *Listener) C() <-chan struct{} { return l.c }
+
+// Connected reports whether notifications are currently being received.
+// While it is false, consumers should fall back to polling.
+func (l *Listener) Connected() bool { return l.connected.Load() }
+
+// Run listens until ctx is canceled, reconnecting with backoff when the
+// connection is lost.
+func (l *Listener) Run(ctx context.Context) error {
+	backoff := l.minBackoff
+	for {
+		err := l.listen(ctx)
+		if l.connected.Swap(false) {
+			backoff = l.minBackoff
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		l.log.Error(ctx, "pg listener disconnected", "channel", l.channel, "error", err, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxReconnectBackoff)
+	}
+}
+
+func (l *Listener) listen(ctx context.Context) error {
+	conn, err := l.connect(ctx, l.url)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+		defer cancel()
+		_ = conn.Close(closeCtx)
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+		return err
+	}
+	l.connected.Store(true)
+	l.log.Info(ctx, "pg listener connected", "channel", l.channel)
+
+	// Anything written while we were not listening was not announced.
+	l.signal()
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		l.signal()
+	}
+}
+
+func (l *Listener) signal() {
+	select {
+	case l.c <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,130 @@
+package listendb
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	retryIn []time.Duration
+}
+
+func (l *recordingLogger) Info(context.Context, string, ...any) {}
+
+func (l *recordingLogger) Error(_ context.Context, _ string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := 0; i+1 < len(args); i += 2 {
+		if args[i] == "retry_in" {
+			l.retryIn = append(l.retryIn, args[i+1].(time.Duration))
+		}
+	}
+}
+
+// fakeConn delivers one notification per value on notes and fails with
+// the first non-nil error.
+type fakeConn struct {
+	notes chan error
+}
+
+func (c *fakeConn) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, nil
+}
+
+func (c *fakeConn) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case err := <-c.notes:
+		if err != nil {
+			return nil, err
+		}
+		return &pgconn.Notification{}, nil
+	}
+}
+
+func (c *fakeConn) Close(context.Context) error { return nil }
+
+func TestListener_ReconnectsWithBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errDown := errors.New("connection refused")
+	fc := &fakeConn{notes: make(chan error)}
+	log := &recordingLogger{}
+	l := New("postgres://test", "outbox", log)
+	l.minBackoff = time.Millisecond
+
+	var attempts int
+	l.connect = func(context.Context, string) (conn, error) {
+		attempts++
+		switch attempts {
+		case 1, 2, 3, 5:
+			return nil, errDown
+		case 4:
+			return fc, nil
+		default:
+			cancel()
+			return nil, ctx.Err()
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- l.Run(ctx) }()
+
+	select {
+	case <-l.C():
+	case <-time.After(time.Second):
+		t.Fatal("no signal on connect")
+	}
+	if !l.Connected() {
+		t.Fatal("Connected() = false after connect")
+	}
+
+	fc.notes <- nil
+	select {
+	case <-l.C():
+	case <-time.After(time.Second):
+		t.Fatal("no signal on notification")
+	}
+	fc.notes <- errDown
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Run() = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	if l.Connected() {
+		t.Fatal("Connected() = true after disconnect")
+	}
+
+	// Backoff doubles while connecting fails and starts over once a
+	// connection has been established.
+	want := []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, time.Millisecond, 2 * time.Millisecond}
+	if !slices.Equal(log.retryIn, want) {
+		t.Fatalf("retry_in = %v, want %v", log.retryIn, want)
+	}
+}
+
+func TestListener_SignalsAreCoalesced(t *testing.T) {
+	l := New("postgres://test", "outbox", &recordingLogger{})
+	for range 3 {
+		l.signal()
+	}
+	<-l.C()
+	select {
+	case <-l.C():
+		t.Fatal("got a second signal, want one coalesced signal")
+	default:
+	}
+}
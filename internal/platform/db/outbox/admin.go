@@ -0,0 +1,215 @@
+package outboxdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/outbox"
+	dberrs "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/errs"
+)
+
+type dbAdminEvent struct {
+	ID              int64          `db:"id"`
+	EventType       string         `db:"event_type"`
+	Payload         []byte         `db:"payload"`
+	Status          string         `db:"status"`
+	Attempts        int            `db:"attempts"`
+	NextAttemptAt   time.Time      `db:"next_attempt_at"`
+	ProcessingUntil sql.NullTime   `db:"processing_until"`
+	LastError       sql.NullString `db:"last_error"`
+	OrderingKey     sql.NullString `db:"ordering_key"`
+	CreatedAt       time.Time      `db:"created_at"`
+	UpdatedAt       time.Time      `db:"updated_at"`
+}
+
+func (d dbAdminEvent) toDomain() outbox.Event {
+	out := outbox.Event{
+		ID:            d.ID,
+		Type:          d.EventType,
+		Payload:       d.Payload,
+		Status:        outbox.Status(d.Status),
+		Attempts:      d.Attempts,
+		NextAttemptAt: d.NextAttemptAt,
+		LastError:     d.LastError.String,
+		OrderingKey:   d.OrderingKey.String,
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+	}
+	if d.ProcessingUntil.Valid {
+		t := d.ProcessingUntil.Time
+		out.ProcessingUntil = &t
+	}
+	return out
+}
+
+const selectAdminEventCols = `
+    id,
+    event_type,
+    payload,
+    status,
+    attempts,
+    next_attempt_at,
+    processing_until,
+    last_error,
+    ordering_key,
+    created_at,
+    updated_at
+`
+
+// List returns events matching f, newest first. Payloads are omitted.
+func (r *Repository) List(ctx context.Context, f outbox.ListFilter) ([]outbox.Event, error) {
+	const op = "outbox.repo.list"
+
+	const q = `
+        SELECT id, event_type, status, attempts, next_attempt_at,
+               processing_until, last_error, ordering_key, created_at, updated_at
+        FROM webhook_outbox
+        WHERE ($1::text = '' OR status = $1::text)
+          AND ($2::text = '' OR event_type = $2::text)
+          AND ($3::timestamptz IS NULL OR created_at >= $3)
+          AND ($4::timestamptz IS NULL OR created_at < $4)
+        ORDER BY id DESC
+        LIMIT $5 OFFSET $6;
+    `
+
+	var rows []dbAdminEvent
+	if err := sqlx.SelectContext(ctx, r.exec, &rows, q,
+		string(f.Status), f.Type, nullTime(f.From), nullTime(f.To), f.Limit, f.Offset,
+	); err != nil {
+		return nil, dberrs.Map(err, op)
+	}
+
+	out := make([]outbox.Event, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, row.toDomain())
+	}
+	return out, nil
+}
+
+func (r *Repository) GetByID(ctx context.Context, id int64) (outbox.Event, error) {
+	const op = "outbox.repo.get_by_id"
+
+	const q = `
+        SELECT ` + selectAdminEventCols + `
+        FROM webhook_outbox
+        WHERE id = $1;
+    `
+
+	var row dbAdminEvent
+	if err := sqlx.GetContext(ctx, r.exec, &row, q, id); err != nil {
+		return outbox.Event{}, dberrs.Map(err, op)
+	}
+	return row.toDomain(), nil
+}
+
+// Requeue resets the dead events among ids to pending with no attempts and
+// wakes the relay. It returns the ids that were requeued.
+func (r *Repository) Requeue(ctx context.Context, ids []int64) ([]int64, error) {
+	const op = "outbox.repo.requeue"
+
+	const q = `
+        WITH requeued AS (
+            UPDATE webhook_outbox
+            SET status = 'pending',
+                attempts = 0,
+                processing_until = NULL,
+                next_attempt_at = NOW(),
+                updated_at = NOW()
+            WHERE id = ANY($1) AND status = 'dead'
+            RETURNING id
+        )
+        SELECT r.id
+        FROM requeued r, LATERAL (SELECT pg_notify($2, r.id::text)) n
+        ORDER BY r.id;
+    `
+
+	var out []int64
+	if err := sqlx.SelectContext(ctx, r.exec, &out, q, ids, NotifyChannel); err != nil {
+		return nil, dberrs.Map(err, op)
+	}
+	return out, nil
+}
+
+// Purge deletes up to limit events matching f and returns how many were
+// deleted.
+func (r *Repository) Purge(ctx context.Context, f outbox.PurgeFilter, limit int) (int64, error) {
+	const op = "outbox.repo.purge"
+
+	const q = `
+        DELETE FROM webhook_outbox
+        WHERE id IN (
+            SELECT id
+            FROM webhook_outbox
+            WHERE status = $1
+              AND ($2::text = '' OR event_type = $2::text)
+              AND created_at < $3
+            LIMIT $4
+        );
+    `
+
+	res, err := r.exec.ExecContext(ctx, q, string(f.Status), f.Type, f.Before, limit)
+	if err != nil {
+		return 0, dberrs.Map(err, op)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, dberrs.Map(err, op)
+	}
+	return n, nil
+}
+
+func (r *Repository) Summary(ctx context.Context) (outbox.Summary, error) {
+	const op = "outbox.repo.summary"
+
+	const countsQ = `
+        SELECT status, COUNT(*) AS count
+        FROM webhook_outbox
+        GROUP BY status;
+    `
+
+	var counts []struct {
+		Status string `db:"status"`
+		Count  int64  `db:"count"`
+	}
+	if err := sqlx.SelectContext(ctx, r.exec, &counts, countsQ); err != nil {
+		return outbox.Summary{}, dberrs.Map(err, op)
+	}
+
+	out := outbox.Summary{Counts: map[outbox.Status]int64{
+		outbox.StatusPending:    0,
+		outbox.StatusDispatched: 0,
+		outbox.StatusDead:       0,
+	}}
+	for _, c := range counts {
+		out.Counts[outbox.Status(c.Status)] = c.Count
+	}
+
+	const pendingQ = `
+        SELECT
+            COUNT(*) FILTER (WHERE processing_until < NOW()) AS stuck,
+            MIN(created_at) AS oldest
+        FROM webhook_outbox
+        WHERE status = 'pending';
+    `
+
+	var pending struct {
+		Stuck  int64        `db:"stuck"`
+		Oldest sql.NullTime `db:"oldest"`
+	}
+	if err := sqlx.GetContext(ctx, r.exec, &pending, pendingQ); err != nil {
+		return outbox.Summary{}, dberrs.Map(err, op)
+	}
+	out.Stuck = pending.Stuck
+	if pending.Oldest.Valid {
+		t := pending.Oldest.Time
+		out.OldestPendingAt = &t
+	}
+	return out, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
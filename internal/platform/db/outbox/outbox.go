@@ -1,28 +1,43 @@
 package outboxdb
 
 import (
+	"cmp"
 	"context"
+	"database/sql"
+	"slices"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 
 	dberrs "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/errs"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/tracing"
 )
 
+// NotifyChannel is notified with the id of every enqueued event once the
+// enqueuing transaction commits.
+const NotifyChannel = "webhook_outbox"
+
 type Repository struct {
 	exec sqlx.ExtContext
 }
 
 func New(exec sqlx.ExtContext) *Repository { return &Repository{exec: exec} }
 
-func (r *Repository) Enqueue(ctx context.Context, eventType string, payloadJSON string) error {
+// Enqueue appends an event to the outbox. Events with the same non-empty
+// orderingKey are dispatched in the order they were enqueued. The trace
+// context of ctx is stored with the event and travels with it to delivery.
+func (r *Repository) Enqueue(ctx context.Context, eventType, payloadJSON, orderingKey string) error {
 	const op = "outbox.repo.enqueue"
 
 	const q = `
-        INSERT INTO webhook_outbox (event_type, payload, status, attempts, next_attempt_at, created_at, updated_at)
-        VALUES ($1, $2::jsonb, 'pending', 0, NOW(), NOW(), NOW());
+        WITH ins AS (
+            INSERT INTO webhook_outbox (event_type, payload, ordering_key, trace_context, status, attempts, next_attempt_at, created_at, updated_at)
+            VALUES ($1, $2::jsonb, NULLIF($3, ''), NULLIF($4, ''), 'pending', 0, NOW(), NOW(), NOW())
+            RETURNING id
+        )
+        SELECT pg_notify($5, id::text) FROM ins;
     `
-	if _, err := r.exec.ExecContext(ctx, q, eventType, payloadJSON); err != nil {
+	if _, err := r.exec.ExecContext(ctx, q, eventType, payloadJSON, orderingKey, tracing.Inject(ctx), NotifyChannel); err != nil {
 		return dberrs.Map(err, op)
 	}
 	return nil
@@ -32,36 +47,89 @@ type Event struct {
 	ID          int64  `db:"id"`
 	EventType   string `db:"event_type"`
 	PayloadJSON string `db:"payload"`
-	Attempts    int    `db:"attempts"`
+	OrderingKey string `db:"ordering_key"`
+	// TraceContext is the encoded trace context of the enqueuing request;
+	// see tracing.Inject.
+	TraceContext string `db:"trace_context"`
+	Attempts     int    `db:"attempts"`
 }
 
-func (r *Repository) ClaimBatch(ctx context.Context, limit int) ([]Event, error) {
+// claimLockID is the advisory lock serializing ClaimBatch across relays.
+const claimLockID = 0x6f7574626f78 // "outbox"
+
+// ClaimBatch leases up to limit due events for lease and increments their
+// attempts, returning them in id order. A leased event is not claimed again
+// until the lease expires, so events of a relay that crashed mid-batch are
+// picked up after lease.
+//
+// An event is not claimed while an earlier event with the same ordering key
+// is leased or waiting for a retry. Claims are serialized with an advisory
+// lock, so ClaimBatch must run in a transaction.
+func (r *Repository) ClaimBatch(ctx context.Context, limit int, lease time.Duration) ([]Event, error) {
 	const op = "outbox.repo.claim_batch"
 
+	if _, err := r.exec.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1);`, claimLockID); err != nil {
+		return nil, dberrs.Map(err, op)
+	}
+
 	const q = `
         WITH claimed AS (
             SELECT id
-            FROM webhook_outbox
-            WHERE status = 'pending' AND next_attempt_at <= NOW()
+            FROM webhook_outbox o
+            WHERE status = 'pending'
+              AND next_attempt_at <= NOW()
+              AND (processing_until IS NULL OR processing_until < NOW())
+              AND (ordering_key IS NULL OR NOT EXISTS (
+                  SELECT 1
+                  FROM webhook_outbox p
+                  WHERE p.ordering_key = o.ordering_key
+                    AND p.id < o.id
+                    AND p.status = 'pending'
+                    AND (p.next_attempt_at > NOW() OR p.processing_until >= NOW())
+              ))
             ORDER BY id
             FOR UPDATE SKIP LOCKED
             LIMIT $1
         )
         UPDATE webhook_outbox o
         SET attempts = attempts + 1,
+            processing_until = NOW() + make_interval(secs => $2),
             updated_at = NOW()
         FROM claimed
         WHERE o.id = claimed.id
-        RETURNING o.id, o.event_type, o.payload, o.attempts;
+        RETURNING o.id, o.event_type, o.payload, COALESCE(o.ordering_key, '') AS ordering_key,
+                  COALESCE(o.trace_context, '') AS trace_context, o.attempts;
     `
 
 	var rows []Event
-	if err := sqlx.SelectContext(ctx, r.exec, &rows, q, limit); err != nil {
+	if err := sqlx.SelectContext(ctx, r.exec, &rows, q, limit, lease.Seconds()); err != nil {
 		return nil, dberrs.Map(err, op)
 	}
+	slices.SortFunc(rows, func(a, b Event) int { return cmp.Compare(a.ID, b.ID) })
 	return rows, nil
 }
 
+// NextDue returns when the earliest pending event that is not claimable yet
+// becomes due: the time of its next retry or the end of its lease. Neither
+// is announced with a notification. ok is false when no such event exists.
+func (r *Repository) NextDue(ctx context.Context) (time.Time, bool, error) {
+	const op = "outbox.repo.next_due"
+
+	const q = `
+        SELECT LEAST(
+            (SELECT MIN(next_attempt_at) FROM webhook_outbox
+             WHERE status = 'pending' AND next_attempt_at > NOW()),
+            (SELECT MIN(processing_until) FROM webhook_outbox
+             WHERE status = 'pending' AND processing_until > NOW())
+        );
+    `
+	var next sql.NullTime
+	if err := sqlx.GetContext(ctx, r.exec, &next, q); err != nil {
+		return time.Time{}, false, dberrs.Map(err, op)
+	}
+	return next.Time, next.Valid, nil
+}
+
 func (r *Repository) MarkDispatchedBatch(ctx context.Context, ids []int64) error {
 	const op = "outbox.repo.mark_dispatched_batch"
 
@@ -82,39 +150,60 @@ func (r *Repository) MarkDispatchedBatch(ctx context.Context, ids []int64) error
 	return nil
 }
 
-func (r *Repository) MarkRetryBatch(ctx context.Context, ids []int64, nextAttemptAt time.Time, lastErr string) error {
+// Retry schedules the next attempt of one event.
+type Retry struct {
+	ID            int64
+	NextAttemptAt time.Time
+}
+
+// MarkRetryBatch releases the leases of the events and schedules each at its
+// own next attempt time.
+func (r *Repository) MarkRetryBatch(ctx context.Context, retries []Retry, lastErr string) error {
 	const op = "outbox.repo.mark_retry_batch"
 
-	if len(ids) == 0 {
+	if len(retries) == 0 {
 		return nil
 	}
 
+	ids := make([]int64, 0, len(retries))
+	next := make([]time.Time, 0, len(retries))
+	for _, rt := range retries {
+		ids = append(ids, rt.ID)
+		next = append(next, rt.NextAttemptAt)
+	}
+
 	const q = `
-        UPDATE webhook_outbox
+        UPDATE webhook_outbox o
         SET status = 'pending',
             processing_until = NULL,
-            next_attempt_at = $2,
+            next_attempt_at = r.next_attempt_at,
             last_error = $3,
             updated_at = NOW()
-        WHERE id = ANY($1);
+        FROM unnest($1::bigint[], $2::timestamptz[]) AS r(id, next_attempt_at)
+        WHERE o.id = r.id;
     `
-	if _, err := r.exec.ExecContext(ctx, q, ids, nextAttemptAt, lastErr); err != nil {
+	if _, err := r.exec.ExecContext(ctx, q, ids, next, lastErr); err != nil {
 		return dberrs.Map(err, op)
 	}
 	return nil
 }
 
-func (r *Repository) MarkDead(ctx context.Context, id int64, lastErr string) error {
-	const op = "outbox.repo.mark_dead"
+func (r *Repository) MarkDeadBatch(ctx context.Context, ids []int64, lastErr string) error {
+	const op = "outbox.repo.mark_dead_batch"
+
+	if len(ids) == 0 {
+		return nil
+	}
 
 	const q = `
         UPDATE webhook_outbox
         SET status = 'dead',
+            processing_until = NULL,
             last_error = $2,
             updated_at = NOW()
-        WHERE id = $1;
+        WHERE id = ANY($1);
     `
-	if _, err := r.exec.ExecContext(ctx, q, id, lastErr); err != nil {
+	if _, err := r.exec.ExecContext(ctx, q, ids, lastErr); err != nil {
 		return dberrs.Map(err, op)
 	}
 	return nil
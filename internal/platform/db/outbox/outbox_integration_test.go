@@ -0,0 +1,127 @@
+//go:build integration
+
+package outboxdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/dbtest"
+)
+
+var testDB *sqlx.DB
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m, &testDB)
+}
+
+// withRepo returns a repository in a transaction rolled back after the test.
+// NOW() is fixed within it, so leases never expire during the test.
+func withRepo(t *testing.T) (context.Context, *sqlx.Tx, *Repository) {
+	t.Helper()
+	dbtest.Truncate(t, testDB, "webhook_outbox")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	tx, err := testDB.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	t.Cleanup(func() { _ = tx.Rollback() })
+	return ctx, tx, New(tx)
+}
+
+func claimedIDs(t *testing.T, ctx context.Context, repo *Repository, limit int) []int64 {
+	t.Helper()
+	events, err := repo.ClaimBatch(ctx, limit, time.Minute)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	ids := make([]int64, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestRepository_ClaimBatchKeepsKeyOrder(t *testing.T) {
+	ctx, tx, repo := withRepo(t)
+
+	for _, key := range []string{"incident:1", "incident:1", "", "incident:2"} {
+		if err := repo.Enqueue(ctx, "incident.updated", `{}`, key); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	// Only the first incident:1 event fits; the second stays behind its
+	// leased predecessor while other keys are claimed.
+	if ids := claimedIDs(t, ctx, repo, 1); len(ids) != 1 || ids[0] != 1 {
+		t.Fatalf("expected [1], got %v", ids)
+	}
+	if ids := claimedIDs(t, ctx, repo, 10); len(ids) != 2 || ids[0] != 3 || ids[1] != 4 {
+		t.Fatalf("expected [3 4] while 1 is leased, got %v", ids)
+	}
+
+	// A predecessor waiting for a retry still holds the key back.
+	if err := repo.MarkRetryBatch(ctx, []Retry{{ID: 1, NextAttemptAt: time.Now().Add(time.Hour)}}, "boom"); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if ids := claimedIDs(t, ctx, repo, 10); len(ids) != 0 {
+		t.Fatalf("expected nothing while 1 waits for a retry, got %v", ids)
+	}
+
+	// Once it is finished the next event of the key is claimed.
+	if err := repo.MarkDeadBatch(ctx, []int64{1}, "boom"); err != nil {
+		t.Fatalf("dead: %v", err)
+	}
+	if ids := claimedIDs(t, ctx, repo, 10); len(ids) != 1 || ids[0] != 2 {
+		t.Fatalf("expected [2] after 1 is dead, got %v", ids)
+	}
+
+	var attempts int
+	if err := tx.GetContext(ctx, &attempts, `SELECT attempts FROM webhook_outbox WHERE id = 2`); err != nil {
+		t.Fatalf("attempts: %v", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected one attempt, got %d", attempts)
+	}
+}
+
+func TestRepository_NextDue(t *testing.T) {
+	ctx, _, repo := withRepo(t)
+
+	if _, ok, err := repo.NextDue(ctx); err != nil || ok {
+		t.Fatalf("empty outbox: ok=%v, err=%v", ok, err)
+	}
+
+	for range 2 {
+		if err := repo.Enqueue(ctx, "incident.updated", `{}`, ""); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	// Due events are claimed right away, so they have no next due time.
+	if _, ok, err := repo.NextDue(ctx); err != nil || ok {
+		t.Fatalf("due events: ok=%v, err=%v", ok, err)
+	}
+
+	if ids := claimedIDs(t, ctx, repo, 2); len(ids) != 2 {
+		t.Fatalf("expected 2 claimed, got %v", ids)
+	}
+	retryAt := time.Now().Add(10 * time.Second)
+	if err := repo.MarkRetryBatch(ctx, []Retry{{ID: 1, NextAttemptAt: retryAt}}, "boom"); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+
+	// The retry comes before the lease of event 2 runs out.
+	next, ok, err := repo.NextDue(ctx)
+	if err != nil || !ok {
+		t.Fatalf("next due: ok=%v, err=%v", ok, err)
+	}
+	if d := next.Sub(retryAt); d < -time.Millisecond || d > time.Millisecond {
+		t.Fatalf("expected the retry time %s, got %s", retryAt, next)
+	}
+}
@@ -0,0 +1,261 @@
+// Package queuedb is a queue in a Postgres table, claimed with FOR UPDATE
+// SKIP LOCKED, for running without Redis.
+package queuedb
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	dberrs "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/errs"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
+)
+
+// NotifyChannel is notified whenever items are published.
+const NotifyChannel = "event_queue"
+
+type Logger interface {
+	Info(ctx context.Context, msg string, args ...any)
+	Error(ctx context.Context, msg string, args ...any)
+}
+
+// Wakeup signals that items may have been published.
+type Wakeup interface {
+	C() <-chan struct{}
+	// Connected reports whether signals are being delivered; while it is
+	// false Receive polls at its regular interval.
+	Connected() bool
+}
+
+type Option func(*Queue)
+
+// WithWakeup makes Receive return as soon as w signals instead of polling.
+func WithWakeup(w Wakeup) Option {
+	return func(q *Queue) { q.wakeup = w }
+}
+
+// WithPollInterval sets how often Receive polls without a connected wakeup
+// source.
+func WithPollInterval(d time.Duration) Option {
+	return func(q *Queue) {
+		if d > 0 {
+			q.pollInterval = d
+		}
+	}
+}
+
+type Queue struct {
+	exec       sqlx.ExtContext
+	redelivery queue.Redelivery
+	wakeup     Wakeup
+	log        Logger
+
+	pollInterval time.Duration
+	wait         time.Duration
+}
+
+var (
+	_ queue.Publisher = (*Queue)(nil)
+	_ queue.Consumer  = (*Queue)(nil)
+)
+
+func New(exec sqlx.ExtContext, r queue.Redelivery, log Logger, opts ...Option) *Queue {
+	q := &Queue{
+		exec:         exec,
+		redelivery:   r.WithDefaults(),
+		log:          log,
+		pollInterval: 500 * time.Millisecond,
+		wait:         2 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+func (q *Queue) Publish(ctx context.Context, items []queue.Item) error {
+	const op = "queue.repo.publish"
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	types := make([]string, 0, len(items))
+	payloads := make([]string, 0, len(items))
+	outboxIDs := make([]int64, 0, len(items))
+	keys := make([]string, 0, len(items))
+	traces := make([]string, 0, len(items))
+	for _, it := range items {
+		types = append(types, it.EventType)
+		payloads = append(payloads, it.Payload)
+		outboxIDs = append(outboxIDs, it.OutboxID)
+		keys = append(keys, it.Key)
+		traces = append(traces, it.TraceContext)
+	}
+
+	const insQ = `
+        WITH ins AS (
+            INSERT INTO event_queue (event_type, payload, outbox_id, ordering_key, trace_context)
+            SELECT t, p, o, NULLIF(k, ''), NULLIF(tc, '')
+            FROM unnest($1::text[], $2::text[], $3::bigint[], $4::text[], $5::text[])
+                WITH ORDINALITY AS u(t, p, o, k, tc, ord)
+            ORDER BY ord
+            RETURNING id
+        )
+        SELECT pg_notify($6, COUNT(*)::text) FROM ins;
+    `
+	if _, err := q.exec.ExecContext(ctx, insQ, types, payloads, outboxIDs, keys, traces, NotifyChannel); err != nil {
+		return dberrs.Map(err, op)
+	}
+	return nil
+}
+
+func (q *Queue) Receive(ctx context.Context, max int) ([]queue.Message, error) {
+	deadline := time.Now().Add(q.wait)
+
+	var wake <-chan struct{}
+	if q.wakeup != nil {
+		wake = q.wakeup.C()
+	}
+
+	for {
+		msgs, err := q.claim(ctx, max)
+		if err != nil || len(msgs) > 0 {
+			return msgs, err
+		}
+
+		left := time.Until(deadline)
+		if left <= 0 {
+			return nil, nil
+		}
+		if q.wakeup == nil || !q.wakeup.Connected() {
+			left = min(left, q.pollInterval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-wake:
+		case <-time.After(left):
+		}
+	}
+}
+
+type dbMessage struct {
+	ID         int64  `db:"id"`
+	EventType  string `db:"event_type"`
+	Payload    string `db:"payload"`
+	OutboxID   int64  `db:"outbox_id"`
+	Key        string `db:"ordering_key"`
+	Trace      string `db:"trace_context"`
+	Deliveries int    `db:"deliveries"`
+}
+
+// claim drops visible messages that reached MaxDeliveries, then hides up to
+// max visible messages for the visibility timeout. A message with an
+// ordering key is claimable only while no earlier message with the same key
+// is left, so the next one becomes visible once its predecessor is acked or
+// dropped.
+func (q *Queue) claim(ctx context.Context, max int) ([]queue.Message, error) {
+	const op = "queue.repo.claim"
+
+	const dropQ = `
+        DELETE FROM event_queue
+        WHERE id IN (
+            SELECT id
+            FROM event_queue
+            WHERE visible_at <= NOW() AND deliveries >= $1
+            LIMIT 100
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING id, event_type, payload, outbox_id, COALESCE(ordering_key, '') AS ordering_key, deliveries;
+    `
+	var dropped []dbMessage
+	if err := sqlx.SelectContext(ctx, q.exec, &dropped, dropQ, q.redelivery.MaxDeliveries); err != nil {
+		return nil, dberrs.Map(err, op)
+	}
+	for _, m := range dropped {
+		q.log.Error(ctx, "queue message dropped after max deliveries", "message_id", m.ID, "outbox_id", m.OutboxID, "deliveries", m.Deliveries)
+	}
+
+	const claimQ = `
+        UPDATE event_queue q
+        SET deliveries = q.deliveries + 1,
+            visible_at = NOW() + make_interval(secs => $2)
+        FROM (
+            SELECT id
+            FROM event_queue
+            WHERE visible_at <= NOW() AND deliveries < $3
+              AND (
+                  ordering_key IS NULL
+                  OR NOT EXISTS (
+                      SELECT 1
+                      FROM event_queue e
+                      WHERE e.ordering_key = event_queue.ordering_key
+                        AND e.id < event_queue.id
+                  )
+              )
+            ORDER BY id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        ) c
+        WHERE q.id = c.id
+        RETURNING q.id, q.event_type, q.payload, q.outbox_id, COALESCE(q.ordering_key, '') AS ordering_key,
+                  COALESCE(q.trace_context, '') AS trace_context, q.deliveries;
+    `
+	var rows []dbMessage
+	if err := sqlx.SelectContext(ctx, q.exec, &rows, claimQ, max, q.redelivery.Visibility.Seconds(), q.redelivery.MaxDeliveries); err != nil {
+		return nil, dberrs.Map(err, op)
+	}
+	slices.SortFunc(rows, func(a, b dbMessage) int { return cmp.Compare(a.ID, b.ID) })
+
+	out := make([]queue.Message, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, queue.Message{
+			ID:         strconv.FormatInt(r.ID, 10),
+			Item:       queue.Item{EventType: r.EventType, Payload: r.Payload, OutboxID: r.OutboxID, Key: r.Key, TraceContext: r.Trace},
+			Deliveries: r.Deliveries,
+		})
+	}
+	return out, nil
+}
+
+func (q *Queue) Ack(ctx context.Context, id string) error {
+	const op = "queue.repo.ack"
+
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return err
+	}
+	if _, err := q.exec.ExecContext(ctx, `DELETE FROM event_queue WHERE id = $1;`, n); err != nil {
+		return dberrs.Map(err, op)
+	}
+	return nil
+}
+
+// Retry hides the message for delay and takes back the delivery its claim
+// counted.
+func (q *Queue) Retry(ctx context.Context, id string, delay time.Duration) error {
+	const op = "queue.repo.retry"
+
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return err
+	}
+	const query = `
+        UPDATE event_queue
+        SET deliveries = GREATEST(deliveries - 1, 0),
+            visible_at = NOW() + make_interval(secs => $2)
+        WHERE id = $1;
+    `
+	if _, err := q.exec.ExecContext(ctx, query, n, delay.Seconds()); err != nil {
+		return dberrs.Map(err, op)
+	}
+	return nil
+}
+
+func (q *Queue) Close(context.Context) error { return nil }
@@ -0,0 +1,148 @@
+//go:build integration
+
+package queuedb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/dbtest"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
+)
+
+var testDB *sqlx.DB
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m, &testDB)
+}
+
+type logRecorder struct {
+	mu     sync.Mutex
+	errors []string
+}
+
+func (l *logRecorder) Info(context.Context, string, ...any) {}
+
+func (l *logRecorder) Error(_ context.Context, msg string, _ ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, msg)
+}
+
+func newQueue(t *testing.T, r queue.Redelivery) (*Queue, *logRecorder) {
+	t.Helper()
+	dbtest.Truncate(t, testDB, "event_queue")
+
+	log := &logRecorder{}
+	return New(testDB, r, log), log
+}
+
+func outboxIDs(msgs []queue.Message) []int64 {
+	ids := make([]int64, 0, len(msgs))
+	for _, m := range msgs {
+		ids = append(ids, m.OutboxID)
+	}
+	return ids
+}
+
+func TestQueue_ClaimHidesUntilVisibilityExpiresThenDrops(t *testing.T) {
+	ctx := context.Background()
+	q, log := newQueue(t, queue.Redelivery{Visibility: 200 * time.Millisecond, MaxDeliveries: 2})
+
+	if err := q.Publish(ctx, []queue.Item{{EventType: "a", Payload: "{}", OutboxID: 1}, {EventType: "b", Payload: "{}", OutboxID: 2}}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	msgs, err := q.claim(ctx, 10)
+	if err != nil || len(msgs) != 2 || msgs[0].OutboxID != 1 || msgs[0].Deliveries != 1 {
+		t.Fatalf("first claim: %v, %+v", err, msgs)
+	}
+	if err := q.Ack(ctx, msgs[0].ID); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+
+	// Claimed messages stay hidden for the visibility timeout.
+	if msgs, err := q.claim(ctx, 10); err != nil || len(msgs) != 0 {
+		t.Fatalf("expected nothing visible: %v, %+v", err, msgs)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	msgs, err = q.claim(ctx, 10)
+	if err != nil || len(msgs) != 1 || msgs[0].OutboxID != 2 || msgs[0].Deliveries != 2 {
+		t.Fatalf("redelivery: %v, %+v", err, msgs)
+	}
+
+	// After MaxDeliveries the message is deleted and logged.
+	time.Sleep(300 * time.Millisecond)
+	if msgs, err := q.claim(ctx, 10); err != nil || len(msgs) != 0 {
+		t.Fatalf("expected the message to be dropped: %v, %+v", err, msgs)
+	}
+	var left int
+	if err := testDB.Get(&left, `SELECT COUNT(*) FROM event_queue`); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if left != 0 {
+		t.Fatalf("expected an empty queue, %d left", left)
+	}
+	if len(log.errors) != 1 || log.errors[0] != "queue message dropped after max deliveries" {
+		t.Fatalf("expected the drop to be logged, got %v", log.errors)
+	}
+}
+
+func TestQueue_ClaimKeepsKeyOrder(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newQueue(t, queue.Redelivery{Visibility: time.Minute})
+
+	items := []queue.Item{
+		{EventType: "a", Payload: "{}", OutboxID: 1, Key: "incident:1"},
+		{EventType: "a", Payload: "{}", OutboxID: 2, Key: "incident:1"},
+		{EventType: "a", Payload: "{}", OutboxID: 3},
+		{EventType: "a", Payload: "{}", OutboxID: 4, Key: "incident:2"},
+	}
+	if err := q.Publish(ctx, items); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	// The second incident:1 message waits for the first.
+	msgs, err := q.claim(ctx, 10)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if got := outboxIDs(msgs); len(got) != 3 || got[0] != 1 || got[1] != 3 || got[2] != 4 {
+		t.Fatalf("expected outbox ids [1 3 4], got %v", got)
+	}
+	if msgs, err := q.claim(ctx, 10); err != nil || len(msgs) != 0 {
+		t.Fatalf("expected nothing claimable before the ack: %v, %+v", err, msgs)
+	}
+
+	if err := q.Ack(ctx, msgs[0].ID); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	msgs, err = q.claim(ctx, 10)
+	if err != nil || len(msgs) != 1 || msgs[0].OutboxID != 2 {
+		t.Fatalf("expected outbox id 2 after the ack: %v, %+v", err, msgs)
+	}
+}
+
+func TestQueue_RetryDoesNotCountDelivery(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newQueue(t, queue.Redelivery{Visibility: time.Minute, MaxDeliveries: 1})
+
+	if err := q.Publish(ctx, []queue.Item{{EventType: "a", Payload: "{}", OutboxID: 1}}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	for range 3 {
+		msgs, err := q.claim(ctx, 10)
+		if err != nil || len(msgs) != 1 || msgs[0].Deliveries != 1 {
+			t.Fatalf("expected the message on its first counted delivery: %v, %+v", err, msgs)
+		}
+		if err := q.Retry(ctx, msgs[0].ID, 0); err != nil {
+			t.Fatalf("retry: %v", err)
+		}
+	}
+}
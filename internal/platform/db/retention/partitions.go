@@ -0,0 +1,153 @@
+package retentiondb
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+
+	dberrs "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/errs"
+)
+
+// Partition is a range partition of a table partitioned by a timestamptz
+// column. A zero From or To is an unbounded (MINVALUE/MAXVALUE) side.
+type Partition struct {
+	Name    string
+	From    time.Time
+	To      time.Time
+	Default bool
+}
+
+// Partitioned reports whether table is a partitioned table.
+func (r *Repository) Partitioned(ctx context.Context, table string) (bool, error) {
+	const op = "retention.repo.partitioned"
+
+	const q = `
+        SELECT EXISTS (
+            SELECT 1 FROM pg_partitioned_table WHERE partrelid = to_regclass($1)
+        );
+    `
+	var ok bool
+	if err := sqlx.GetContext(ctx, r.exec, &ok, q, table); err != nil {
+		return false, dberrs.Map(err, op)
+	}
+	return ok, nil
+}
+
+// Partitions lists the partitions of table.
+func (r *Repository) Partitions(ctx context.Context, table string) ([]Partition, error) {
+	const op = "retention.repo.partitions"
+
+	const q = `
+        SELECT c.relname AS name, pg_get_expr(c.relpartbound, c.oid) AS bound
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        WHERE i.inhparent = to_regclass($1)
+        ORDER BY c.relname;
+    `
+	var rows []struct {
+		Name  string `db:"name"`
+		Bound string `db:"bound"`
+	}
+	if err := sqlx.SelectContext(ctx, r.exec, &rows, q, table); err != nil {
+		return nil, dberrs.Map(err, op)
+	}
+
+	out := make([]Partition, 0, len(rows))
+	for _, row := range rows {
+		p, err := ParseBound(row.Bound)
+		if err != nil {
+			return nil, fmt.Errorf("%s: partition %s: %w", op, row.Name, err)
+		}
+		p.Name = row.Name
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// CreatePartition creates partition name of table for [from, to).
+func (r *Repository) CreatePartition(ctx context.Context, table, name string, from, to time.Time) error {
+	const op = "retention.repo.create_partition"
+
+	q := fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s');`,
+		pgx.Identifier{name}.Sanitize(),
+		pgx.Identifier{table}.Sanitize(),
+		from.UTC().Format(boundLayout),
+		to.UTC().Format(boundLayout),
+	)
+	if _, err := r.exec.ExecContext(ctx, q); err != nil {
+		return dberrs.Map(err, op)
+	}
+	return nil
+}
+
+// DropPartition drops partition name with all its rows. It briefly takes an
+// exclusive lock on the parent table.
+func (r *Repository) DropPartition(ctx context.Context, name string) error {
+	const op = "retention.repo.drop_partition"
+
+	if _, err := r.exec.ExecContext(ctx, "DROP TABLE IF EXISTS "+pgx.Identifier{name}.Sanitize()+";"); err != nil {
+		return dberrs.Map(err, op)
+	}
+	return nil
+}
+
+// OutboxPartitionHasPending reports whether outbox partition name still has
+// events waiting to be dispatched.
+func (r *Repository) OutboxPartitionHasPending(ctx context.Context, name string) (bool, error) {
+	const op = "retention.repo.outbox_partition_has_pending"
+
+	q := `SELECT EXISTS (SELECT 1 FROM ` + pgx.Identifier{name}.Sanitize() + ` WHERE status = 'pending');`
+
+	var ok bool
+	if err := sqlx.GetContext(ctx, r.exec, &ok, q); err != nil {
+		return false, dberrs.Map(err, op)
+	}
+	return ok, nil
+}
+
+const boundLayout = "2006-01-02 15:04:05-07"
+
+var rangeBound = regexp.MustCompile(`^FOR VALUES FROM \((.+)\) TO \((.+)\)$`)
+
+// ParseBound parses a range partition bound as printed by pg_get_expr, e.g.
+// FOR VALUES FROM ('2026-01-01 00:00:00+00') TO ('2026-01-02 00:00:00+00').
+func ParseBound(expr string) (Partition, error) {
+	if expr == "DEFAULT" {
+		return Partition{Default: true}, nil
+	}
+
+	m := rangeBound.FindStringSubmatch(expr)
+	if m == nil {
+		return Partition{}, fmt.Errorf("unsupported partition bound %q", expr)
+	}
+
+	from, err := parseBoundValue(m[1])
+	if err != nil {
+		return Partition{}, err
+	}
+	to, err := parseBoundValue(m[2])
+	if err != nil {
+		return Partition{}, err
+	}
+	return Partition{From: from, To: to}, nil
+}
+
+func parseBoundValue(v string) (time.Time, error) {
+	if v == "MINVALUE" || v == "MAXVALUE" {
+		return time.Time{}, nil
+	}
+
+	s := strings.Trim(v, "'")
+	for _, layout := range []string{boundLayout, "2006-01-02 15:04:05-07:00", "2006-01-02 15:04:05-07:00:00"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported partition bound value %q", v)
+}
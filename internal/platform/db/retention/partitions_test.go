@@ -0,0 +1,42 @@
+package retentiondb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBound(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want Partition
+	}{
+		{"DEFAULT", Partition{Default: true}},
+		{
+			"FOR VALUES FROM ('2026-01-01 00:00:00+00') TO ('2026-01-02 00:00:00+00')",
+			Partition{From: day, To: day.AddDate(0, 0, 1)},
+		},
+		{
+			"FOR VALUES FROM (MINVALUE) TO ('2026-01-01 03:00:00+03')",
+			Partition{To: day},
+		},
+		{
+			"FOR VALUES FROM ('2026-01-01 05:30:00+05:30') TO (MAXVALUE)",
+			Partition{From: day},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseBound(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		if got.Default != tt.want.Default || !got.From.Equal(tt.want.From) || !got.To.Equal(tt.want.To) {
+			t.Fatalf("%s: got %+v, want %+v", tt.expr, got, tt.want)
+		}
+	}
+
+	if _, err := ParseBound("FOR VALUES IN ('a')"); err == nil {
+		t.Fatalf("list partitions must be rejected")
+	}
+}
@@ -0,0 +1,100 @@
+// Package retentiondb deletes expired rows in bounded batches and maintains
+// time-partitioned tables.
+package retentiondb
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	dberrs "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/errs"
+)
+
+type Repository struct {
+	exec sqlx.ExtContext
+}
+
+func New(exec sqlx.ExtContext) *Repository { return &Repository{exec: exec} }
+
+// DeleteOutbox deletes up to limit outbox events with status created before
+// before. Pending events must never be passed here.
+func (r *Repository) DeleteOutbox(ctx context.Context, status string, before time.Time, limit int) (int64, error) {
+	const op = "retention.repo.delete_outbox"
+
+	const q = `
+        DELETE FROM webhook_outbox
+        WHERE id IN (
+            SELECT id
+            FROM webhook_outbox
+            WHERE status = $1 AND created_at < $2
+            LIMIT $3
+        );
+    `
+	return r.deleted(ctx, op, q, status, before, limit)
+}
+
+// DeleteDeliveries deletes up to limit delivery records last updated before
+// before.
+func (r *Repository) DeleteDeliveries(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const op = "retention.repo.delete_deliveries"
+
+	const q = `
+        DELETE FROM webhook_deliveries
+        WHERE (outbox_id, subscription_id) IN (
+            SELECT outbox_id, subscription_id
+            FROM webhook_deliveries
+            WHERE updated_at < $1
+            LIMIT $2
+        );
+    `
+	return r.deleted(ctx, op, q, before, limit)
+}
+
+// DeleteLocationChecks deletes up to limit location checks created before
+// before. Their incident links go with them through ON DELETE CASCADE, or
+// through DeleteOrphanCheckIncidents once the table is partitioned.
+func (r *Repository) DeleteLocationChecks(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const op = "retention.repo.delete_location_checks"
+
+	const q = `
+        DELETE FROM location_checks
+        WHERE id IN (
+            SELECT id
+            FROM location_checks
+            WHERE created_at < $1
+            LIMIT $2
+        );
+    `
+	return r.deleted(ctx, op, q, before, limit)
+}
+
+// DeleteOrphanCheckIncidents deletes up to limit incident links of location
+// checks that no longer exist. Links and their check are written in one
+// transaction, so a link below the smallest remaining check id is orphaned.
+func (r *Repository) DeleteOrphanCheckIncidents(ctx context.Context, limit int) (int64, error) {
+	const op = "retention.repo.delete_orphan_check_incidents"
+
+	const q = `
+        DELETE FROM location_check_incidents
+        WHERE (check_id, incident_id) IN (
+            SELECT check_id, incident_id
+            FROM location_check_incidents
+            WHERE check_id < COALESCE((SELECT MIN(id) FROM location_checks), 9223372036854775807)
+            LIMIT $1
+        );
+    `
+	return r.deleted(ctx, op, q, limit)
+}
+
+func (r *Repository) deleted(ctx context.Context, op, q string, args ...any) (int64, error) {
+	res, err := r.exec.ExecContext(ctx, q, args...)
+	if err != nil {
+		return 0, dberrs.Map(err, op)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, dberrs.Map(err, op)
+	}
+	return n, nil
+}
@@ -3,8 +3,10 @@ package txrunner
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 
 	incidentsapp "github.com/m1ll3r1337/geo-notifications-service/internal/app/incidents"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
 	incidentsdb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/incidents"
 	outboxdb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/outbox"
 	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/uow"
@@ -20,13 +22,16 @@ func NewIncidentsTxRunner(u *uow.UnitOfWork) *IncidentsTxRunner {
 
 func (r *IncidentsTxRunner) WithinTx(
 	ctx context.Context,
-	fn func(ctx context.Context, checker incidentsapp.Checker, outbox incidentsapp.OutboxRepository) error) error {
+	fn func(ctx context.Context, repos incidentsapp.TxRepos) error) error {
 	return r.u.WithinTxRoot(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted}, func(sc uow.Scope) error {
-		locRepo := incidentsdb.New(sc.Executor())
+		incRepo := incidentsdb.New(sc.Executor())
 		obRepo := outboxdb.New(sc.Executor())
 
-		outboxWriter := outboxWriterAdapter{repo: obRepo}
-		return fn(ctx, locRepo, outboxWriter)
+		return fn(ctx, incidentsapp.TxRepos{
+			Incidents: incRepo,
+			Checker:   incRepo,
+			Outbox:    outboxWriterAdapter{repo: obRepo},
+		})
 	})
 }
 
@@ -34,6 +39,10 @@ type outboxWriterAdapter struct {
 	repo *outboxdb.Repository
 }
 
-func (a outboxWriterAdapter) Enqueue(ctx context.Context, eventType string, payloadJSON string) error {
-	return a.repo.Enqueue(ctx, eventType, payloadJSON)
+func (a outboxWriterAdapter) Enqueue(ctx context.Context, ev events.Envelope, orderingKey string) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return a.repo.Enqueue(ctx, ev.Type, string(b), orderingKey)
 }
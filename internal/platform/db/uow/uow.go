@@ -6,8 +6,14 @@ import (
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/uow")
+
 type UnitOfWork struct {
 	db *sqlx.DB
 }
@@ -36,6 +42,19 @@ func (u *UnitOfWork) WithinTx(ctx context.Context, scope Scope, opts *sql.TxOpti
 		return fn(scope)
 	}
 
+	// The span covers the transaction from BEGIN to COMMIT or ROLLBACK.
+	ctx, span := tracer.Start(ctx, "db.transaction",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNamePostgreSQL),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	tx, err := u.db.BeginTxx(ctx, opts)
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
@@ -0,0 +1,115 @@
+package webhooksdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/webhooks"
+	dberrs "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/errs"
+)
+
+type DeliveriesRepository struct {
+	exec sqlx.ExtContext
+}
+
+func NewDeliveries(exec sqlx.ExtContext) *DeliveriesRepository {
+	return &DeliveriesRepository{exec: exec}
+}
+
+type dbDelivery struct {
+	OutboxID       int64          `db:"outbox_id"`
+	SubscriptionID int64          `db:"subscription_id"`
+	EventID        string         `db:"event_id"`
+	EventType      string         `db:"event_type"`
+	Status         string         `db:"status"`
+	Attempts       int            `db:"attempts"`
+	LastError      sql.NullString `db:"last_error"`
+	CreatedAt      time.Time      `db:"created_at"`
+	UpdatedAt      time.Time      `db:"updated_at"`
+}
+
+func (d dbDelivery) toDomain() webhooks.Delivery {
+	return webhooks.Delivery{
+		OutboxID:       d.OutboxID,
+		SubscriptionID: d.SubscriptionID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		Status:         webhooks.DeliveryStatus(d.Status),
+		Attempts:       d.Attempts,
+		LastError:      d.LastError.String,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+}
+
+// Record upserts the delivery of an event to a subscription with the
+// outcome of the latest attempt. A successful attempt clears the error.
+func (r *DeliveriesRepository) Record(ctx context.Context, a webhooks.DeliveryAttempt) error {
+	const op = "webhooks.repo.record_delivery"
+
+	const q = `
+        INSERT INTO webhook_deliveries (outbox_id, subscription_id, event_id, event_type, status, last_error)
+        VALUES ($1, $2, $3, $4, CASE WHEN $5::text = '' THEN 'delivered' ELSE 'failed' END, NULLIF($5::text, ''))
+        ON CONFLICT (outbox_id, subscription_id) DO UPDATE
+        SET status = EXCLUDED.status,
+            attempts = webhook_deliveries.attempts + 1,
+            last_error = EXCLUDED.last_error,
+            updated_at = NOW();
+    `
+
+	if _, err := r.exec.ExecContext(ctx, q, a.OutboxID, a.SubscriptionID, a.EventID, a.EventType, a.Err); err != nil {
+		return dberrs.Map(err, op)
+	}
+	return nil
+}
+
+// Delivered reports whether the event was delivered to the subscription.
+func (r *DeliveriesRepository) Delivered(ctx context.Context, outboxID, subscriptionID int64) (bool, error) {
+	const op = "webhooks.repo.delivered"
+
+	const q = `
+        SELECT EXISTS (
+            SELECT 1
+            FROM webhook_deliveries
+            WHERE outbox_id = $1 AND subscription_id = $2 AND status = 'delivered'
+        );
+    `
+
+	var ok bool
+	if err := sqlx.GetContext(ctx, r.exec, &ok, q, outboxID, subscriptionID); err != nil {
+		return false, dberrs.Map(err, op)
+	}
+	return ok, nil
+}
+
+// MarkDelivered is a no-op: Record already stored the successful attempt.
+// It lets the repository deduplicate deliveries without Redis.
+func (r *DeliveriesRepository) MarkDelivered(context.Context, int64, int64) error {
+	return nil
+}
+
+func (r *DeliveriesRepository) List(ctx context.Context, subscriptionID int64, f webhooks.DeliveryFilter) ([]webhooks.Delivery, error) {
+	const op = "webhooks.repo.list_deliveries"
+
+	const q = `
+        SELECT outbox_id, subscription_id, event_id, event_type, status, attempts, last_error, created_at, updated_at
+        FROM webhook_deliveries
+        WHERE subscription_id = $1 AND ($2::text = '' OR status = $2::text)
+        ORDER BY updated_at DESC
+        LIMIT $3 OFFSET $4;
+    `
+
+	var rows []dbDelivery
+	if err := sqlx.SelectContext(ctx, r.exec, &rows, q, subscriptionID, string(f.Status), f.Limit, f.Offset); err != nil {
+		return nil, dberrs.Map(err, op)
+	}
+
+	out := make([]webhooks.Delivery, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, row.toDomain())
+	}
+	return out, nil
+}
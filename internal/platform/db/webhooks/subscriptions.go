@@ -0,0 +1,493 @@
+package webhooksdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/webhooks"
+	dberrs "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/errs"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/secretbox"
+)
+
+type Logger interface {
+	Error(ctx context.Context, msg string, args ...any)
+}
+
+type Option func(*Repository)
+
+// WithSecrets encrypts TLS client keys with box before they are stored.
+func WithSecrets(box *secretbox.Box) Option {
+	return func(r *Repository) { r.secrets = box }
+}
+
+// WithLogger reports subscriptions left out of lists because their client
+// key cannot be decrypted.
+func WithLogger(log Logger) Option {
+	return func(r *Repository) { r.log = log }
+}
+
+type Repository struct {
+	exec    sqlx.ExtContext
+	secrets *secretbox.Box
+	log     Logger
+}
+
+func New(exec sqlx.ExtContext, opts ...Option) *Repository {
+	r := &Repository{exec: exec}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+type dbSubscription struct {
+	ID              int64          `db:"id"`
+	URL             string         `db:"url"`
+	PayloadMode     string         `db:"payload_mode"`
+	BodyTemplate    sql.NullString `db:"body_template"`
+	HeaderTemplates []byte         `db:"header_templates"`
+	ContentType     sql.NullString `db:"content_type"`
+	BatchMaxItems   int            `db:"batch_max_items"`
+	BatchMaxWaitMS  int64          `db:"batch_max_wait_ms"`
+	TLSCACert       sql.NullString `db:"tls_ca_cert"`
+	TLSClientCert   sql.NullString `db:"tls_client_cert"`
+	TLSClientKey    sql.NullString `db:"tls_client_key"`
+	Active          bool           `db:"active"`
+	VerifStatus     string         `db:"verification_status"`
+	VerifAttempts   int            `db:"verification_attempts"`
+	VerifError      sql.NullString `db:"verification_error"`
+	VerifiedAt      sql.NullTime   `db:"verified_at"`
+	CreatedAt       time.Time      `db:"created_at"`
+	UpdatedAt       time.Time      `db:"updated_at"`
+}
+
+func (d dbSubscription) toDomain() webhooks.Subscription {
+	out := webhooks.Subscription{
+		ID:          d.ID,
+		URL:         d.URL,
+		PayloadMode: webhooks.PayloadMode(d.PayloadMode),
+		Template: webhooks.Template{
+			Body:        d.BodyTemplate.String,
+			ContentType: d.ContentType.String,
+		},
+		Batching: webhooks.Batching{
+			MaxItems: d.BatchMaxItems,
+			MaxWait:  time.Duration(d.BatchMaxWaitMS) * time.Millisecond,
+		},
+		TLS: webhooks.TLS{
+			CACert:     d.TLSCACert.String,
+			ClientCert: d.TLSClientCert.String,
+			ClientKey:  d.TLSClientKey.String,
+		},
+		Active: d.Active,
+		Verification: webhooks.Verification{
+			Status:    webhooks.VerificationStatus(d.VerifStatus),
+			Attempts:  d.VerifAttempts,
+			LastError: d.VerifError.String,
+		},
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+	}
+	if d.VerifiedAt.Valid {
+		t := d.VerifiedAt.Time
+		out.Verification.VerifiedAt = &t
+	}
+	if len(d.HeaderTemplates) > 0 {
+		_ = json.Unmarshal(d.HeaderTemplates, &out.Template.Headers)
+	}
+	if len(out.Template.Headers) == 0 {
+		out.Template.Headers = nil
+	}
+	return out
+}
+
+// toDomain converts row and decrypts its client key.
+func (r *Repository) toDomain(row dbSubscription) (webhooks.Subscription, error) {
+	out := row.toDomain()
+	key, err := r.secrets.Open(out.TLS.ClientKey)
+	if err != nil {
+		return webhooks.Subscription{}, fmt.Errorf("subscription %d: tls client key: %w", row.ID, err)
+	}
+	out.TLS.ClientKey = key
+	return out, nil
+}
+
+// toDomainList converts rows, leaving out those whose client key cannot be
+// decrypted, e.g. after the secret key was changed: one broken subscription
+// must not stop deliveries to all the others.
+func (r *Repository) toDomainList(ctx context.Context, rows []dbSubscription) []webhooks.Subscription {
+	out := make([]webhooks.Subscription, 0, len(rows))
+	for _, row := range rows {
+		sub, err := r.toDomain(row)
+		if err != nil {
+			if r.log != nil {
+				r.log.Error(ctx, "webhook subscription skipped", "subscription_id", row.ID, "error", err)
+			}
+			continue
+		}
+		out = append(out, sub)
+	}
+	return out
+}
+
+func headersJSON(h map[string]string) (string, error) {
+	if h == nil {
+		h = map[string]string{}
+	}
+	b, err := json.Marshal(h)
+	return string(b), err
+}
+
+func nullString(s string) sql.NullString {
+	if s == "" {
+		return sql.NullString{Valid: false}
+	}
+	return sql.NullString{String: s, Valid: true}
+}
+
+const selectSubscriptionCols = `
+    id,
+    url,
+    payload_mode,
+    body_template,
+    header_templates,
+    content_type,
+    batch_max_items,
+    batch_max_wait_ms,
+    tls_ca_cert,
+    tls_client_cert,
+    tls_client_key,
+    active,
+    verification_status,
+    verification_attempts,
+    verification_error,
+    verified_at,
+    created_at,
+    updated_at
+`
+
+func (r *Repository) Create(ctx context.Context, in webhooks.CreateSubscription) (webhooks.Subscription, error) {
+	const op = "webhooks.repo.create"
+
+	const q = `
+        INSERT INTO webhook_subscriptions (
+            url, payload_mode, body_template, header_templates, content_type, batch_max_items, batch_max_wait_ms,
+            tls_ca_cert, tls_client_cert, tls_client_key
+        )
+        VALUES ($1, COALESCE(NULLIF($2, ''), 'full'), $3, $4::jsonb, $5, $6, $7, $8, $9, $10)
+        RETURNING ` + selectSubscriptionCols + `;
+    `
+
+	headers, err := headersJSON(in.Template.Headers)
+	if err != nil {
+		return webhooks.Subscription{}, dberrs.Map(err, op)
+	}
+	key, err := r.secrets.Seal(in.TLS.ClientKey)
+	if err != nil {
+		return webhooks.Subscription{}, dberrs.Map(err, op)
+	}
+
+	var row dbSubscription
+	if err := sqlx.GetContext(ctx, r.exec, &row, q,
+		in.URL,
+		string(in.PayloadMode),
+		nullString(in.Template.Body),
+		headers,
+		nullString(in.Template.ContentType),
+		in.Batching.MaxItems,
+		in.Batching.MaxWait.Milliseconds(),
+		nullString(in.TLS.CACert),
+		nullString(in.TLS.ClientCert),
+		nullString(key),
+	); err != nil {
+		return webhooks.Subscription{}, dberrs.Map(err, op)
+	}
+	sub, err := r.toDomain(row)
+	if err != nil {
+		return webhooks.Subscription{}, dberrs.Map(err, op)
+	}
+	return sub, nil
+}
+
+func (r *Repository) GetByID(ctx context.Context, id int64) (webhooks.Subscription, error) {
+	const op = "webhooks.repo.get_by_id"
+
+	const q = `
+        SELECT ` + selectSubscriptionCols + `
+        FROM webhook_subscriptions
+        WHERE id = $1;
+    `
+
+	var row dbSubscription
+	if err := sqlx.GetContext(ctx, r.exec, &row, q, id); err != nil {
+		return webhooks.Subscription{}, dberrs.Map(err, op)
+	}
+	sub, err := r.toDomain(row)
+	if err != nil {
+		return webhooks.Subscription{}, dberrs.Map(err, op)
+	}
+	return sub, nil
+}
+
+func (r *Repository) List(ctx context.Context, f webhooks.ListFilter) ([]webhooks.Subscription, error) {
+	const op = "webhooks.repo.list"
+
+	q := `SELECT ` + selectSubscriptionCols + ` FROM webhook_subscriptions`
+	if f.ActiveOnly {
+		q += ` WHERE active = TRUE`
+	}
+	q += ` ORDER BY id LIMIT $1 OFFSET $2`
+
+	var rows []dbSubscription
+	if err := sqlx.SelectContext(ctx, r.exec, &rows, q, f.Limit, f.Offset); err != nil {
+		return nil, dberrs.Map(err, op)
+	}
+	return r.toDomainList(ctx, rows), nil
+}
+
+// ListActive returns every active, verified subscription; it backs the
+// webhook worker.
+func (r *Repository) ListActive(ctx context.Context) ([]webhooks.Subscription, error) {
+	const op = "webhooks.repo.list_active"
+
+	const q = `
+        SELECT ` + selectSubscriptionCols + `
+        FROM webhook_subscriptions
+        WHERE active = TRUE AND verification_status = 'verified'
+        ORDER BY id;
+    `
+
+	var rows []dbSubscription
+	if err := sqlx.SelectContext(ctx, r.exec, &rows, q); err != nil {
+		return nil, dberrs.Map(err, op)
+	}
+	return r.toDomainList(ctx, rows), nil
+}
+
+func (r *Repository) Update(ctx context.Context, id int64, in webhooks.UpdateSubscription) (webhooks.Subscription, error) {
+	const op = "webhooks.repo.update"
+
+	setParts := make([]string, 0, 12)
+	args := make([]any, 0, 12)
+
+	add := func(sqlPart string, val any) {
+		args = append(args, val)
+		setParts = append(setParts, fmt.Sprintf(sqlPart, len(args)))
+	}
+
+	if in.URL != nil {
+		add("url = $%d", *in.URL)
+		// A new URL has to be verified again; the right-hand url is the old value.
+		changed := fmt.Sprintf("url IS DISTINCT FROM $%d", len(args))
+		setParts = append(setParts,
+			"verification_status = CASE WHEN "+changed+" THEN 'pending_verification' ELSE verification_status END",
+			"verification_attempts = CASE WHEN "+changed+" THEN 0 ELSE verification_attempts END",
+			"next_verification_at = CASE WHEN "+changed+" THEN NOW() ELSE next_verification_at END",
+		)
+	}
+	if in.PayloadMode != nil {
+		add("payload_mode = $%d", string(*in.PayloadMode))
+	}
+	if in.Template != nil {
+		headers, err := headersJSON(in.Template.Headers)
+		if err != nil {
+			return webhooks.Subscription{}, dberrs.Map(err, op)
+		}
+		add("body_template = $%d", nullString(in.Template.Body))
+		add("header_templates = $%d::jsonb", headers)
+		add("content_type = $%d", nullString(in.Template.ContentType))
+	}
+	if in.Batching != nil {
+		add("batch_max_items = $%d", in.Batching.MaxItems)
+		add("batch_max_wait_ms = $%d", in.Batching.MaxWait.Milliseconds())
+	}
+	if in.TLS != nil {
+		add("tls_ca_cert = $%d", nullString(in.TLS.CACert))
+		add("tls_client_cert = $%d", nullString(in.TLS.ClientCert))
+		key, err := r.secrets.Seal(in.TLS.ClientKey)
+		if err != nil {
+			return webhooks.Subscription{}, dberrs.Map(err, op)
+		}
+		add("tls_client_key = $%d", nullString(key))
+	}
+	if in.Active != nil {
+		add("active = $%d", *in.Active)
+	}
+
+	if len(setParts) == 0 {
+		return r.GetByID(ctx, id)
+	}
+
+	setParts = append(setParts, "updated_at = NOW()")
+
+	args = append(args, id)
+	idPos := len(args)
+
+	q := fmt.Sprintf(`
+        UPDATE webhook_subscriptions
+        SET %s
+        WHERE id = $%d
+        RETURNING %s;
+    `, strings.Join(setParts, ", "), idPos, selectSubscriptionCols)
+
+	var row dbSubscription
+	if err := sqlx.GetContext(ctx, r.exec, &row, q, args...); err != nil {
+		return webhooks.Subscription{}, dberrs.Map(err, op)
+	}
+	sub, err := r.toDomain(row)
+	if err != nil {
+		return webhooks.Subscription{}, dberrs.Map(err, op)
+	}
+	return sub, nil
+}
+
+func (r *Repository) Delete(ctx context.Context, id int64) error {
+	const op = "webhooks.repo.delete"
+
+	const q = `
+        DELETE FROM webhook_subscriptions
+        WHERE id = $1
+        RETURNING id;
+    `
+
+	var tmp int64
+	if err := sqlx.GetContext(ctx, r.exec, &tmp, q, id); err != nil {
+		return dberrs.Map(err, op)
+	}
+	return nil
+}
+
+// RequestVerification puts the subscription back into pending verification
+// with an immediate challenge.
+func (r *Repository) RequestVerification(ctx context.Context, id int64) (webhooks.Subscription, error) {
+	const op = "webhooks.repo.request_verification"
+
+	const q = `
+        UPDATE webhook_subscriptions
+        SET verification_status = 'pending_verification',
+            verification_attempts = 0,
+            next_verification_at = NOW()
+        WHERE id = $1
+        RETURNING ` + selectSubscriptionCols + `;
+    `
+
+	var row dbSubscription
+	if err := sqlx.GetContext(ctx, r.exec, &row, q, id); err != nil {
+		return webhooks.Subscription{}, dberrs.Map(err, op)
+	}
+	sub, err := r.toDomain(row)
+	if err != nil {
+		return webhooks.Subscription{}, dberrs.Map(err, op)
+	}
+	return sub, nil
+}
+
+// ClaimVerifications returns up to limit active subscriptions due for a
+// challenge and hides them from other callers for lease. A subscription is
+// due when it is pending verification, or when it is verified but every
+// delivery to it within failingFor has failed.
+func (r *Repository) ClaimVerifications(ctx context.Context, limit int, lease, failingFor time.Duration) ([]webhooks.Subscription, error) {
+	const op = "webhooks.repo.claim_verifications"
+
+	q := `
+        WITH due AS (
+            SELECT s.id
+            FROM webhook_subscriptions s
+            WHERE s.active = TRUE
+              AND s.next_verification_at <= NOW()
+              AND (
+                  s.verification_status = 'pending_verification'
+                  OR (
+                      s.verification_status = 'verified'
+                      AND COALESCE(s.verified_at, s.created_at) < NOW() - make_interval(secs => $3)
+                      AND EXISTS (
+                          SELECT 1 FROM webhook_deliveries d
+                          WHERE d.subscription_id = s.id AND d.status = 'failed'
+                            AND d.updated_at > NOW() - make_interval(secs => $3)
+                      )
+                      AND NOT EXISTS (
+                          SELECT 1 FROM webhook_deliveries d
+                          WHERE d.subscription_id = s.id AND d.status = 'delivered'
+                            AND d.updated_at > NOW() - make_interval(secs => $3)
+                      )
+                  )
+              )
+            ORDER BY s.next_verification_at, s.id
+            FOR UPDATE SKIP LOCKED
+            LIMIT $1
+        )
+        UPDATE webhook_subscriptions s
+        SET next_verification_at = NOW() + make_interval(secs => $2)
+        FROM due
+        WHERE s.id = due.id
+        RETURNING ` + prefixCols("s", selectSubscriptionCols) + `;
+    `
+
+	var rows []dbSubscription
+	if err := sqlx.SelectContext(ctx, r.exec, &rows, q, limit, lease.Seconds(), failingFor.Seconds()); err != nil {
+		return nil, dberrs.Map(err, op)
+	}
+	return r.toDomainList(ctx, rows), nil
+}
+
+// CompleteVerification stores the outcome of a challenge. It is ignored if
+// the subscription's URL changed since the challenge was sent.
+func (r *Repository) CompleteVerification(ctx context.Context, id int64, res webhooks.VerificationResult) error {
+	const op = "webhooks.repo.complete_verification"
+
+	if res.Deferred {
+		const q = `
+            UPDATE webhook_subscriptions
+            SET next_verification_at = $3
+            WHERE id = $1 AND url = $2;
+        `
+		if _, err := r.exec.ExecContext(ctx, q, id, res.URL, res.RetryAt); err != nil {
+			return dberrs.Map(err, op)
+		}
+		return nil
+	}
+
+	if res.Err == "" {
+		const q = `
+            UPDATE webhook_subscriptions
+            SET verification_status = 'verified',
+                verification_attempts = 0,
+                verification_error = NULL,
+                verified_at = NOW(),
+                next_verification_at = NOW()
+            WHERE id = $1 AND url = $2;
+        `
+		if _, err := r.exec.ExecContext(ctx, q, id, res.URL); err != nil {
+			return dberrs.Map(err, op)
+		}
+		return nil
+	}
+
+	const q = `
+        UPDATE webhook_subscriptions
+        SET verification_status = CASE WHEN $3 THEN 'verification_failed' ELSE 'pending_verification' END,
+            verification_attempts = verification_attempts + 1,
+            verification_error = $4,
+            next_verification_at = $5
+        WHERE id = $1 AND url = $2;
+    `
+	if _, err := r.exec.ExecContext(ctx, q, id, res.URL, res.GiveUp, res.Err, res.RetryAt); err != nil {
+		return dberrs.Map(err, op)
+	}
+	return nil
+}
+
+// prefixCols qualifies a comma-separated column list with table alias t.
+func prefixCols(t, cols string) string {
+	parts := strings.Split(cols, ",")
+	for i, p := range parts {
+		parts[i] = t + "." + strings.TrimSpace(p)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,57 @@
+package webhooksdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/secretbox"
+)
+
+type logRecorder struct {
+	msgs []string
+}
+
+func (l *logRecorder) Error(_ context.Context, msg string, _ ...any) { l.msgs = append(l.msgs, msg) }
+
+func newBox(t *testing.T, fill string) *secretbox.Box {
+	t.Helper()
+	box, err := secretbox.New(base64.StdEncoding.EncodeToString([]byte(strings.Repeat(fill, 32))))
+	if err != nil {
+		t.Fatalf("secretbox: %v", err)
+	}
+	return box
+}
+
+func TestRepository_ToDomainListSkipsUndecryptableKeys(t *testing.T) {
+	current, rotated := newBox(t, "a"), newBox(t, "b")
+
+	seal := func(box *secretbox.Box, key string) sql.NullString {
+		sealed, err := box.Seal(key)
+		if err != nil {
+			t.Fatalf("seal: %v", err)
+		}
+		return sql.NullString{String: sealed, Valid: true}
+	}
+	rows := []dbSubscription{
+		{ID: 1, URL: "https://a.example/hook", TLSClientKey: seal(current, "key-1")},
+		{ID: 2, URL: "https://b.example/hook", TLSClientKey: seal(rotated, "key-2")},
+		{ID: 3, URL: "https://c.example/hook"},
+	}
+
+	log := &logRecorder{}
+	r := New(nil, WithSecrets(current), WithLogger(log))
+	subs := r.toDomainList(context.Background(), rows)
+
+	if len(subs) != 2 || subs[0].ID != 1 || subs[1].ID != 3 {
+		t.Fatalf("expected subscriptions 1 and 3, got %+v", subs)
+	}
+	if subs[0].TLS.ClientKey != "key-1" {
+		t.Fatalf("client key not decrypted: %q", subs[0].TLS.ClientKey)
+	}
+	if len(log.msgs) != 1 {
+		t.Fatalf("expected the skipped subscription to be logged, got %v", log.msgs)
+	}
+}
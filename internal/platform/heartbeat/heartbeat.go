@@ -0,0 +1,56 @@
+// Package heartbeat tells a stuck worker loop from an idle one: the loop
+// beats on every iteration and a liveness probe fails once the beats stop
+// while the loop is supposed to be running.
+package heartbeat
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+type Heartbeat struct {
+	name   string
+	maxAge time.Duration
+	// last is the unix nano time of the last beat, zero while stopped.
+	last atomic.Int64
+}
+
+// New returns a heartbeat for name that goes stale maxAge after the last
+// beat.
+func New(name string, maxAge time.Duration) *Heartbeat {
+	return &Heartbeat{name: name, maxAge: maxAge}
+}
+
+// Beat records that the loop is making progress.
+func (h *Heartbeat) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+// Stop marks the loop as not running, e.g. a singleton on an instance that
+// is not the leader; a stopped heartbeat is never stale.
+func (h *Heartbeat) Stop() {
+	h.last.Store(0)
+}
+
+// Ping fails when the loop is running but has not beaten within maxAge.
+func (h *Heartbeat) Ping(context.Context) error {
+	last := h.last.Load()
+	if last == 0 {
+		return nil
+	}
+	if age := time.Since(time.Unix(0, last)); age > h.maxAge {
+		return fmt.Errorf("%s: no progress for %s", h.name, age.Round(time.Second))
+	}
+	return nil
+}
+
+// Report returns when the loop last made progress, for health output.
+func (h *Heartbeat) Report(context.Context) (any, error) {
+	last := h.last.Load()
+	if last == 0 {
+		return map[string]any{"running": false}, nil
+	}
+	return map[string]any{"running": true, "last_beat": time.Unix(0, last).UTC()}, nil
+}
@@ -0,0 +1,31 @@
+package heartbeat
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	h := New("worker", 20*time.Millisecond)
+	ctx := context.Background()
+
+	if err := h.Ping(ctx); err != nil {
+		t.Fatalf("a heartbeat that never started must not be stale: %v", err)
+	}
+
+	h.Beat()
+	if err := h.Ping(ctx); err != nil {
+		t.Fatalf("fresh beat reported stale: %v", err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if err := h.Ping(ctx); err == nil {
+		t.Fatalf("expected a stale heartbeat to fail")
+	}
+
+	h.Stop()
+	if err := h.Ping(ctx); err != nil {
+		t.Fatalf("a stopped heartbeat must not be stale: %v", err)
+	}
+}
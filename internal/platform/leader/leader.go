@@ -0,0 +1,251 @@
+// Package leader runs singleton jobs on exactly one instance at a time. An
+// Elector campaigns for a lease; while it holds it, it runs the jobs, and
+// when the lease is lost or the process stops, it cancels them so another
+// instance can take over.
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/errs"
+)
+
+type Logger interface {
+	Info(ctx context.Context, msg string, args ...any)
+	Error(ctx context.Context, msg string, args ...any)
+}
+
+// Lease is the current holder of a named lease. Token grows every time the
+// lease changes hands, so writes guarded by it fence off a deposed holder.
+type Lease struct {
+	Name       string
+	Holder     string
+	Token      int64
+	AcquiredAt time.Time
+	ExpiresAt  time.Time
+}
+
+type Store interface {
+	// Acquire takes or renews the lease for holder; ok is false while
+	// another holder's lease is unexpired.
+	Acquire(ctx context.Context, name, holder string, ttl time.Duration) (token int64, ok bool, err error)
+	Release(ctx context.Context, name, holder string) error
+	Current(ctx context.Context, name string) (Lease, error)
+}
+
+// Job is a singleton worker; it must return once ctx is canceled.
+type Job func(ctx context.Context) error
+
+type Option func(*Elector)
+
+// WithTTL sets how long the lease outlives its holder. It is renewed every
+// ttl/3.
+func WithTTL(ttl time.Duration) Option {
+	return func(e *Elector) {
+		if ttl > 0 {
+			e.ttl = ttl
+		}
+	}
+}
+
+// WithHolder sets the name the lease is held under; it defaults to
+// <hostname>-<pid>.
+func WithHolder(holder string) Option {
+	return func(e *Elector) {
+		if holder != "" {
+			e.holder = holder
+		}
+	}
+}
+
+type Elector struct {
+	store Store
+	name  string
+	log   Logger
+
+	holder string
+	ttl    time.Duration
+
+	// token is the lease token while leading, zero otherwise.
+	token atomic.Int64
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+func New(store Store, name string, log Logger, opts ...Option) *Elector {
+	e := &Elector{
+		store:  store,
+		name:   name,
+		log:    log,
+		holder: defaultHolder(),
+		ttl:    15 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+func defaultHolder() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+func (e *Elector) Name() string   { return e.name }
+func (e *Elector) Holder() string { return e.holder }
+
+// Token returns the lease token and whether this instance currently leads.
+func (e *Elector) Token() (int64, bool) {
+	t := e.token.Load()
+	return t, t != 0
+}
+
+// Run campaigns for the lease until ctx is canceled and runs jobs while it
+// is held. Jobs are canceled as soon as a renewal fails or cannot be
+// confirmed before the lease would expire; a job returning an error also
+// gives up leadership, after which the instance campaigns again.
+func (e *Elector) Run(ctx context.Context, jobs ...Job) error {
+	interval := e.ttl / 3
+
+	for {
+		token, ok, err := e.store.Acquire(ctx, e.name, e.holder, e.ttl)
+		e.setErr(err)
+		if err != nil && ctx.Err() == nil {
+			e.log.Error(ctx, "leader campaign failed", "lease", e.name, "error", err)
+		}
+		if ok {
+			e.lead(ctx, token, jobs)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// lead runs jobs under a lease acquired with token until it is lost, a job
+// fails or ctx is canceled.
+func (e *Elector) lead(ctx context.Context, token int64, jobs []Job) {
+	acquired := time.Now()
+	e.token.Store(token)
+	e.log.Info(ctx, "leader elected", "lease", e.name, "holder", e.holder, "token", token)
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, len(jobs))
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Go(func() { done <- job(jobCtx) })
+	}
+
+	e.renew(jobCtx, token, acquired, done)
+
+	e.token.Store(0)
+	cancel()
+	wg.Wait()
+
+	// Release with a fresh context: ctx may be canceled already, and a
+	// released lease lets another instance take over without waiting.
+	relCtx, relCancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
+	defer relCancel()
+	if err := e.store.Release(relCtx, e.name, e.holder); err != nil {
+		e.log.Error(ctx, "leader release failed", "lease", e.name, "error", err)
+	}
+	e.log.Info(ctx, "leader stepped down", "lease", e.name, "holder", e.holder, "token", token)
+}
+
+// renew keeps the lease until it is lost, a job returns or ctx is done.
+func (e *Elector) renew(ctx context.Context, token int64, renewed time.Time, done <-chan error) {
+	t := time.NewTicker(e.ttl / 3)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-done:
+			if err != nil && !errors.Is(err, context.Canceled) {
+				e.log.Error(ctx, "leader job failed", "lease", e.name, "error", err)
+			}
+			return
+		case <-t.C:
+		}
+
+		// Stop before the lease could have expired without us noticing.
+		attempt, cancel := context.WithDeadline(ctx, renewed.Add(e.ttl))
+		got, ok, err := e.store.Acquire(attempt, e.name, e.holder, e.ttl)
+		cancel()
+		e.setErr(err)
+
+		switch {
+		case err != nil && time.Since(renewed) >= e.ttl-e.ttl/3:
+			e.log.Error(ctx, "leader lease could not be renewed", "lease", e.name, "error", err)
+			return
+		case err != nil:
+			e.log.Error(ctx, "leader renew failed, retrying", "lease", e.name, "error", err)
+		case !ok || got != token:
+			e.log.Error(ctx, "leader lease lost", "lease", e.name, "token", token)
+			return
+		default:
+			renewed = time.Now()
+		}
+	}
+}
+
+func (e *Elector) setErr(err error) {
+	e.mu.Lock()
+	e.lastErr = err
+	e.mu.Unlock()
+}
+
+// Ping reports the last error talking to the store, for health checks.
+func (e *Elector) Ping(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lastErr
+}
+
+// Status is the lease as reported in health output.
+type Status struct {
+	Lease     string    `json:"lease"`
+	Leader    string    `json:"leader,omitempty"`
+	Token     int64     `json:"token,omitempty"`
+	Since     time.Time `json:"since,omitzero"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	// Self is true when this instance is the leader.
+	Self bool `json:"self"`
+}
+
+// Report returns who holds the lease. An expired lease is reported without
+// a leader.
+func (e *Elector) Report(ctx context.Context) (any, error) {
+	st := Status{Lease: e.name}
+	_, st.Self = e.Token()
+
+	l, err := e.store.Current(ctx, e.name)
+	if de, ok := errs.As(err); ok && de.Kind == errs.KindNotFound {
+		// Never acquired yet.
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	if l.ExpiresAt.After(time.Now()) {
+		st.Leader = l.Holder
+		st.Token = l.Token
+		st.Since = l.AcquiredAt
+		st.ExpiresAt = l.ExpiresAt
+	}
+	return st, nil
+}
@@ -0,0 +1,94 @@
+package leader
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(context.Context, string, ...any)  {}
+func (nopLogger) Error(context.Context, string, ...any) {}
+
+// memStore grants the lease to whoever asks first and lets tests take it
+// away.
+type memStore struct {
+	mu     sync.Mutex
+	holder string
+	token  int64
+}
+
+func (s *memStore) Acquire(_ context.Context, _ string, holder string, _ time.Duration) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holder != "" && s.holder != holder {
+		return 0, false, nil
+	}
+	if s.holder == "" {
+		s.token++
+		s.holder = holder
+	}
+	return s.token, true, nil
+}
+
+func (s *memStore) Release(_ context.Context, _ string, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holder == holder {
+		s.holder = ""
+	}
+	return nil
+}
+
+func (s *memStore) Current(context.Context, string) (Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Lease{Holder: s.holder, Token: s.token, ExpiresAt: time.Now().Add(time.Minute)}, nil
+}
+
+func (s *memStore) steal(holder string) {
+	s.mu.Lock()
+	s.holder = holder
+	s.token++
+	s.mu.Unlock()
+}
+
+func TestElector_StepsDownWhenLeaseIsLost(t *testing.T) {
+	store := &memStore{}
+	e := New(store, "jobs", nopLogger{}, WithHolder("a"), WithTTL(30*time.Millisecond))
+
+	started := make(chan struct{}, 1)
+	stopped := make(chan struct{}, 1)
+	job := func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		stopped <- struct{}{}
+		return ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = e.Run(ctx, job) }()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("job did not start")
+	}
+	if token, ok := e.Token(); !ok || token != 1 {
+		t.Fatalf("expected to lead with token 1, got %d %v", token, ok)
+	}
+
+	store.steal("b")
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("job kept running after the lease was lost")
+	}
+	if _, ok := e.Token(); ok {
+		t.Fatal("still reports leadership after losing the lease")
+	}
+}
@@ -0,0 +1,168 @@
+// Package metrics collects the service's Prometheus metrics and serves them
+// for scraping. Components take the narrow interface they need through an
+// option, so they stay free of the Prometheus client.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "geo"
+
+type Registry struct {
+	reg *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	cacheRequests *prometheus.CounterVec
+	cacheDegraded *prometheus.GaugeVec
+
+	spatialIndexSize   prometheus.Gauge
+	spatialIndexVerify *prometheus.CounterVec
+
+	relayBatchSize *prometheus.HistogramVec
+
+	webhookDeliveries *prometheus.CounterVec
+	webhookDuration   *prometheus.HistogramVec
+}
+
+// New returns a registry with the Go runtime and process collectors and the
+// service's own metrics registered.
+func New() *Registry {
+	r := &Registry{
+		reg: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_requests_total",
+			Help:      "Cache lookups by cache and result (hit, miss, error, bypass).",
+		}, []string{"cache", "result"}),
+		cacheDegraded: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "cache_degraded",
+			Help:      "1 while a cache is bypassed because its backend is failing.",
+		}, []string{"cache"}),
+		spatialIndexSize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "spatial_index_incidents",
+			Help:      "Active incidents held in the in-memory spatial index.",
+		}),
+		spatialIndexVerify: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "spatial_index_verify_total",
+			Help:      "Location checks repeated in PostGIS, by result (match, mismatch).",
+		}, []string{"result"}),
+		relayBatchSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "outbox_relay_batch_size",
+			Help:      "Events per batch the outbox relay published, by result (published, failed).",
+			Buckets:   []float64{1, 5, 10, 25, 50, 100, 250, 500},
+		}, []string{"result"}),
+		webhookDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_deliveries_total",
+			Help:      "Webhook delivery attempts per event and subscription, by outcome.",
+		}, []string{"outcome"}),
+		webhookDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "webhook_delivery_duration_seconds",
+			Help:      "Webhook delivery latency, including waiting for the destination's limits, by outcome.",
+			Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"outcome"}),
+	}
+
+	r.reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		r.httpRequests,
+		r.httpDuration,
+		r.cacheRequests,
+		r.cacheDegraded,
+		r.spatialIndexSize,
+		r.spatialIndexVerify,
+		r.relayBatchSize,
+		r.webhookDeliveries,
+		r.webhookDuration,
+	)
+	return r
+}
+
+// Handler serves the registry in the Prometheus exposition format. A
+// collector that fails is left out of the scrape instead of failing it.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.reg, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// Register adds collectors owned by the caller, such as the outbox backlog.
+func (r *Registry) Register(cs ...prometheus.Collector) {
+	r.reg.MustRegister(cs...)
+}
+
+// RegisterDB exports connection pool statistics of db under name.
+func (r *Registry) RegisterDB(db *sql.DB, name string) {
+	r.reg.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// ObserveHTTP records a served request. route is the matched route pattern,
+// never the raw path, to keep the label set bounded.
+func (r *Registry) ObserveHTTP(method, route string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	r.httpRequests.WithLabelValues(method, route, code).Inc()
+	r.httpDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
+
+// ObserveCache records a cache lookup.
+func (r *Registry) ObserveCache(cache, result string) {
+	r.cacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// SetCacheDegraded records whether a cache is bypassed.
+func (r *Registry) SetCacheDegraded(cache string, degraded bool) {
+	var v float64
+	if degraded {
+		v = 1
+	}
+	r.cacheDegraded.WithLabelValues(cache).Set(v)
+}
+
+// SetSpatialIndexSize records how many incidents the spatial index holds.
+func (r *Registry) SetSpatialIndexSize(n int) {
+	r.spatialIndexSize.Set(float64(n))
+}
+
+// ObserveSpatialIndexVerify records a check compared against PostGIS.
+func (r *Registry) ObserveSpatialIndexVerify(result string) {
+	r.spatialIndexVerify.WithLabelValues(result).Inc()
+}
+
+// ObserveRelayBatch records a batch the outbox relay tried to publish.
+func (r *Registry) ObserveRelayBatch(size int, result string) {
+	r.relayBatchSize.WithLabelValues(result).Observe(float64(size))
+}
+
+// ObserveWebhookDelivery records one delivery attempt of an event to a
+// subscription.
+func (r *Registry) ObserveWebhookDelivery(outcome string, d time.Duration) {
+	r.webhookDeliveries.WithLabelValues(outcome).Inc()
+	r.webhookDuration.WithLabelValues(outcome).Observe(d.Seconds())
+}
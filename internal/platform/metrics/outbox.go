@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/outbox"
+)
+
+// OutboxSummary reads the outbox backlog.
+type OutboxSummary interface {
+	Summary(ctx context.Context) (outbox.Summary, error)
+}
+
+// OutboxCollector reports the outbox backlog, read from the database on
+// every scrape.
+type OutboxCollector struct {
+	src     OutboxSummary
+	timeout time.Duration
+
+	events *prometheus.Desc
+	stuck  *prometheus.Desc
+	oldest *prometheus.Desc
+}
+
+// NewOutboxCollector reads the backlog from src, giving up after timeout so
+// a slow database cannot stall the scrape.
+func NewOutboxCollector(src OutboxSummary, timeout time.Duration) *OutboxCollector {
+	return &OutboxCollector{
+		src:     src,
+		timeout: timeout,
+		events: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "outbox", "events"),
+			"Outbox events by status.",
+			[]string{"status"}, nil,
+		),
+		stuck: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "outbox", "stuck_events"),
+			"Pending outbox events whose processing lease expired.",
+			nil, nil,
+		),
+		oldest: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "outbox", "oldest_pending_age_seconds"),
+			"Age of the oldest pending outbox event; zero when none is pending.",
+			nil, nil,
+		),
+	}
+}
+
+func (c *OutboxCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.events
+	ch <- c.stuck
+	ch <- c.oldest
+}
+
+func (c *OutboxCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	sum, err := c.src.Summary(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.events, err)
+		return
+	}
+
+	for status, n := range sum.Counts {
+		ch <- prometheus.MustNewConstMetric(c.events, prometheus.GaugeValue, float64(n), string(status))
+	}
+	ch <- prometheus.MustNewConstMetric(c.stuck, prometheus.GaugeValue, float64(sum.Stuck))
+
+	var age float64
+	if sum.OldestPendingAt != nil {
+		age = time.Since(*sum.OldestPendingAt).Seconds()
+	}
+	ch <- prometheus.MustNewConstMetric(c.oldest, prometheus.GaugeValue, age)
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPMetrics records served requests.
+type HTTPMetrics interface {
+	ObserveHTTP(method, route string, status int, d time.Duration)
+}
+
+// unmatchedRoute labels requests that matched no route, so that arbitrary
+// paths do not each create a new series.
+const unmatchedRoute = "unmatched"
+
+func Metrics(m HTTPMetrics) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		m.ObserveHTTP(ctx.Request.Method, route, ctx.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type observed struct {
+	method, route string
+	status        int
+}
+
+type recordingMetrics struct {
+	seen []observed
+}
+
+func (m *recordingMetrics) ObserveHTTP(method, route string, status int, _ time.Duration) {
+	m.seen = append(m.seen, observed{method: method, route: route, status: status})
+}
+
+func TestMetrics_LabelsRoutePattern(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := &recordingMetrics{}
+
+	r := gin.New()
+	r.Use(Metrics(m))
+	r.GET("/api/v1/incidents/:id", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+
+	for _, path := range []string{"/api/v1/incidents/1", "/api/v1/incidents/2", "/no/such/path/42"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	want := []observed{
+		{http.MethodGet, "/api/v1/incidents/:id", http.StatusNoContent},
+		{http.MethodGet, "/api/v1/incidents/:id", http.StatusNoContent},
+		{http.MethodGet, unmatchedRoute, http.StatusNotFound},
+	}
+	if len(m.seen) != len(want) {
+		t.Fatalf("expected %d observations, got %+v", len(want), m.seen)
+	}
+	for i := range want {
+		if m.seen[i] != want[i] {
+			t.Fatalf("observation %d: got %+v, want %+v", i, m.seen[i], want[i])
+		}
+	}
+}
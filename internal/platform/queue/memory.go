@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process queue for tests and single-node setups where the
+// relay and the webhook worker run in the same process. Its contents are
+// lost on restart; the outbox keeps events that were not dispatched yet, but
+// dispatched ones still in memory are not delivered.
+type Memory struct {
+	wait time.Duration
+	seq  *Sequencer
+	log  Logger
+
+	mu     sync.Mutex
+	lastID int64
+	signal chan struct{}
+}
+
+var (
+	_ Publisher = (*Memory)(nil)
+	_ Consumer  = (*Memory)(nil)
+)
+
+// Logger reports messages dropped after MaxDeliveries.
+type Logger interface {
+	Error(ctx context.Context, msg string, args ...any)
+}
+
+// NewMemory returns an empty queue. Receive waits up to wait for messages.
+func NewMemory(r Redelivery, wait time.Duration, log Logger) *Memory {
+	if wait <= 0 {
+		wait = 2 * time.Second
+	}
+	return &Memory{
+		wait:   wait,
+		seq:    NewSequencer(r),
+		log:    log,
+		signal: make(chan struct{}, 1),
+	}
+}
+
+func (m *Memory) Publish(_ context.Context, items []Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	msgs := make([]Message, 0, len(items))
+	m.mu.Lock()
+	for _, it := range items {
+		m.lastID++
+		msgs = append(msgs, Message{ID: strconv.FormatInt(m.lastID, 10), Item: it})
+	}
+	// Added under the lock so that concurrent publishes keep id order.
+	m.seq.Add(msgs...)
+	m.mu.Unlock()
+
+	m.notify()
+	return nil
+}
+
+func (m *Memory) Receive(ctx context.Context, max int) ([]Message, error) {
+	timer := time.NewTimer(m.wait)
+	defer timer.Stop()
+
+	for {
+		if msgs := m.next(ctx, max); len(msgs) > 0 {
+			return msgs, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return m.next(ctx, max), nil
+		case <-m.signal:
+		}
+	}
+}
+
+// next hands out up to max messages and logs those dropped after
+// MaxDeliveries.
+func (m *Memory) next(ctx context.Context, max int) []Message {
+	out, dropped := m.seq.Next(time.Now(), max)
+	for _, msg := range dropped {
+		m.log.Error(ctx, "queue message dropped after max deliveries", "message_id", msg.ID, "outbox_id", msg.OutboxID, "deliveries", msg.Deliveries)
+	}
+	return out
+}
+
+// Ack also wakes a waiting Receive, since it may free a key that held back
+// the next message.
+func (m *Memory) Ack(_ context.Context, id string) error {
+	if m.seq.Done(id) {
+		m.notify()
+	}
+	return nil
+}
+
+func (m *Memory) Retry(_ context.Context, id string, delay time.Duration) error {
+	m.seq.Retry(id, time.Now(), delay)
+	return nil
+}
+
+func (m *Memory) Close(context.Context) error { return nil }
+
+func (m *Memory) notify() {
+	select {
+	case m.signal <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,114 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type logRecorder struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *logRecorder) Error(_ context.Context, msg string, _ ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, msg)
+}
+
+func TestMemory_Redelivery(t *testing.T) {
+	ctx := context.Background()
+	log := &logRecorder{}
+	q := NewMemory(Redelivery{Visibility: 20 * time.Millisecond, MaxDeliveries: 2}, 100*time.Millisecond, log)
+
+	if err := q.Publish(ctx, []Item{{EventType: "a", OutboxID: 1}, {EventType: "b", OutboxID: 2}}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	msgs, err := q.Receive(ctx, 10)
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("receive: %v, got %d messages", err, len(msgs))
+	}
+	if msgs[0].OutboxID != 1 || msgs[0].Deliveries != 1 {
+		t.Fatalf("unexpected first message %+v", msgs[0])
+	}
+	if err := q.Ack(ctx, msgs[0].ID); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+
+	// The unacknowledged message comes back once its visibility expires.
+	msgs, err = q.Receive(ctx, 10)
+	if err != nil || len(msgs) != 1 || msgs[0].OutboxID != 2 || msgs[0].Deliveries != 2 {
+		t.Fatalf("redelivery: %v, %+v", err, msgs)
+	}
+
+	// After MaxDeliveries it is dropped.
+	time.Sleep(30 * time.Millisecond)
+	msgs, err = q.Receive(ctx, 10)
+	if err != nil || len(msgs) != 0 {
+		t.Fatalf("expected the message to be dropped: %v, %+v", err, msgs)
+	}
+	if len(log.msgs) != 1 || log.msgs[0] != "queue message dropped after max deliveries" {
+		t.Fatalf("expected the drop to be logged, got %v", log.msgs)
+	}
+}
+
+func TestMemory_KeyOrdering(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemory(Redelivery{Visibility: time.Minute}, 20*time.Millisecond, &logRecorder{})
+
+	items := []Item{
+		{OutboxID: 1, Key: "user:1"},
+		{OutboxID: 2, Key: "user:1"},
+		{OutboxID: 3, Key: "user:2"},
+		{OutboxID: 4},
+	}
+	if err := q.Publish(ctx, items); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	msgs, err := q.Receive(ctx, 10)
+	if err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	var got []int64
+	for _, m := range msgs {
+		got = append(got, m.OutboxID)
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 3 || got[2] != 4 {
+		t.Fatalf("expected outbox ids [1 3 4], got %v", got)
+	}
+
+	// The second user:1 message waits until the first is acknowledged.
+	if msgs, _ := q.Receive(ctx, 10); len(msgs) != 0 {
+		t.Fatalf("expected nothing while user:1 is in flight, got %+v", msgs)
+	}
+	if err := q.Ack(ctx, msgs[0].ID); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	msgs, err = q.Receive(ctx, 10)
+	if err != nil || len(msgs) != 1 || msgs[0].OutboxID != 2 {
+		t.Fatalf("expected outbox id 2 after ack: %v, %+v", err, msgs)
+	}
+}
+
+func TestMemory_RetryDoesNotCountDelivery(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemory(Redelivery{Visibility: time.Minute, MaxDeliveries: 1}, 20*time.Millisecond, &logRecorder{})
+
+	if err := q.Publish(ctx, []Item{{OutboxID: 1, Key: "user:1"}, {OutboxID: 2, Key: "user:1"}}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	for range 3 {
+		msgs, err := q.Receive(ctx, 10)
+		if err != nil || len(msgs) != 1 || msgs[0].OutboxID != 1 || msgs[0].Deliveries != 1 {
+			t.Fatalf("expected outbox id 1 on its first counted delivery: %v, %+v", err, msgs)
+		}
+		if err := q.Retry(ctx, msgs[0].ID, 0); err != nil {
+			t.Fatalf("retry: %v", err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Package queue defines how dispatched outbox events travel from the relay
+// to the webhook worker, independent of the backend carrying them.
+package queue
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+)
+
+// Item is an outbox event handed to the queue.
+type Item struct {
+	EventType string
+	Payload   string
+	OutboxID  int64
+	// Key orders items: items with the same non-empty key are received in
+	// publish order, one at a time.
+	Key string
+	// TraceContext is the encoded trace context of the request that
+	// enqueued the event (see tracing.Inject); empty when it had none.
+	TraceContext string
+}
+
+// Message is an item received from the queue.
+type Message struct {
+	ID string
+	Item
+	// Deliveries counts how many times the message was received, including
+	// this time.
+	Deliveries int
+}
+
+// Publisher appends items to the queue.
+type Publisher interface {
+	Publish(ctx context.Context, items []Item) error
+}
+
+// Consumer receives messages with at-least-once semantics: a message that is
+// not acknowledged within the backend's visibility timeout is received again,
+// and dropped once it has been received MaxDeliveries times.
+//
+// A message with a Key is not received while an earlier message with the
+// same Key is unacknowledged, across all consumers of the queue. Messages
+// with different keys are received concurrently.
+type Consumer interface {
+	// Receive returns up to max messages. It waits a bounded time for the
+	// first one and returns no messages and no error when none arrived.
+	Receive(ctx context.Context, max int) ([]Message, error)
+	Ack(ctx context.Context, id string) error
+	// Retry hands a received message out again after delay without
+	// counting the delivery, for messages that could not be attempted at
+	// all, such as while the receiver's circuit breaker is open.
+	Retry(ctx context.Context, id string, delay time.Duration) error
+	// Close releases the consumer's registration with the backend, if any.
+	Close(ctx context.Context) error
+}
+
+// Redelivery configures when unacknowledged messages are received again.
+type Redelivery struct {
+	// Visibility is how long a received message stays with its consumer
+	// before it is handed out again.
+	Visibility time.Duration
+	// MaxDeliveries is how many times a message is received before it is
+	// dropped.
+	MaxDeliveries int
+}
+
+// DefaultRedelivery is used where a Redelivery field is zero.
+var DefaultRedelivery = Redelivery{Visibility: 30 * time.Second, MaxDeliveries: 10}
+
+// WithDefaults fills zero fields from DefaultRedelivery.
+func (r Redelivery) WithDefaults() Redelivery {
+	if r.Visibility <= 0 {
+		r.Visibility = DefaultRedelivery.Visibility
+	}
+	if r.MaxDeliveries <= 0 {
+		r.MaxDeliveries = DefaultRedelivery.MaxDeliveries
+	}
+	return r
+}
+
+// Partition maps key to one of n partitions.
+func Partition(key string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
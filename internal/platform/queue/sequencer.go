@@ -0,0 +1,191 @@
+package queue
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sequencer buffers received messages for a consumer and hands them out so
+// that at most one message per key is in flight. Messages not acknowledged
+// within the visibility timeout are handed out again. It is safe for
+// concurrent use.
+type Sequencer struct {
+	redelivery Redelivery
+
+	mu       sync.Mutex
+	ready    []Message
+	inflight map[string]inflight
+	busy     map[string]string // key -> id of its in-flight message
+}
+
+type inflight struct {
+	msg      Message
+	deadline time.Time
+}
+
+func NewSequencer(r Redelivery) *Sequencer {
+	return &Sequencer{
+		redelivery: r.WithDefaults(),
+		inflight:   make(map[string]inflight),
+		busy:       make(map[string]string),
+	}
+}
+
+// Add buffers msgs after the messages already buffered. Deliveries is the
+// number of times a message was handed out before.
+func (s *Sequencer) Add(msgs ...Message) {
+	s.mu.Lock()
+	s.ready = append(s.ready, msgs...)
+	s.mu.Unlock()
+}
+
+// Next hands out up to max messages, in the order they were added, skipping
+// messages whose key is in flight. In-flight messages past their deadline
+// are handed out again first; those already handed out MaxDeliveries times
+// are removed and returned as dropped.
+func (s *Sequencer) Next(now time.Time, max int) (out, dropped []Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []Message
+	for id, f := range s.inflight {
+		if now.Before(f.deadline) {
+			continue
+		}
+		if f.msg.Deliveries >= s.redelivery.MaxDeliveries {
+			s.remove(id)
+			dropped = append(dropped, f.msg)
+			continue
+		}
+		expired = append(expired, f.msg)
+	}
+	slices.SortFunc(expired, func(a, b Message) int { return CompareIDs(a.ID, b.ID) })
+
+	for _, m := range expired {
+		if len(out) == max {
+			break
+		}
+		m.Deliveries++
+		s.inflight[m.ID] = inflight{msg: m, deadline: now.Add(s.redelivery.Visibility)}
+		out = append(out, m)
+	}
+
+	kept := s.ready[:0]
+	for _, m := range s.ready {
+		if len(out) == max || m.Key != "" && s.busy[m.Key] != "" {
+			kept = append(kept, m)
+			continue
+		}
+		m.Deliveries++
+		s.inflight[m.ID] = inflight{msg: m, deadline: now.Add(s.redelivery.Visibility)}
+		if m.Key != "" {
+			s.busy[m.Key] = m.ID
+		}
+		out = append(out, m)
+	}
+	clear(s.ready[len(kept):])
+	s.ready = kept
+
+	return out, dropped
+}
+
+// Done removes an acknowledged message and frees its key. It reports
+// whether the message was in flight.
+func (s *Sequencer) Done(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.inflight[id]
+	s.remove(id)
+	return ok
+}
+
+// Retry hands an in-flight message out again once delay has passed, without
+// counting the delivery; its key stays busy meanwhile. It reports whether the
+// message was in flight.
+func (s *Sequencer) Retry(id string, now time.Time, delay time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.inflight[id]
+	if !ok {
+		return false
+	}
+	f.msg.Deliveries = max(f.msg.Deliveries-1, 0)
+	f.deadline = now.Add(delay)
+	s.inflight[id] = f
+	return true
+}
+
+func (s *Sequencer) remove(id string) {
+	f, ok := s.inflight[id]
+	if !ok {
+		return
+	}
+	delete(s.inflight, id)
+	if f.msg.Key != "" && s.busy[f.msg.Key] == id {
+		delete(s.busy, f.msg.Key)
+	}
+}
+
+// Count returns how many buffered and in-flight messages match pred.
+func (s *Sequencer) Count(pred func(Message) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, m := range s.ready {
+		if pred(m) {
+			n++
+		}
+	}
+	for _, f := range s.inflight {
+		if pred(f.msg) {
+			n++
+		}
+	}
+	return n
+}
+
+// Forget removes every buffered and in-flight message matching pred.
+func (s *Sequencer) Forget(pred func(Message) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ready = slices.DeleteFunc(s.ready, pred)
+	for id, f := range s.inflight {
+		if pred(f.msg) {
+			s.remove(id)
+		}
+	}
+}
+
+// CompareIDs orders message ids in publish order. It accepts Redis stream
+// entry ids "<ms>-<seq>" and plain numeric ids, comparing each part
+// numerically.
+func CompareIDs(a, b string) int {
+	am, as := splitID(a)
+	bm, bs := splitID(b)
+	switch {
+	case am != bm:
+		if am < bm {
+			return -1
+		}
+		return 1
+	case as < bs:
+		return -1
+	case as > bs:
+		return 1
+	}
+	return 0
+}
+
+func splitID(id string) (uint64, uint64) {
+	ms, seq, _ := strings.Cut(id, "-")
+	m, _ := strconv.ParseUint(ms, 10, 64)
+	s, _ := strconv.ParseUint(seq, 10, 64)
+	return m, s
+}
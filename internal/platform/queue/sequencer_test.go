@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCompareIDs(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1700000000000-0", "1700000000000-0", 0},
+		{"1700000000000-2", "1700000000000-10", -1},
+		{"1700000000001-2", "1700000000000-10", 1},
+		{"999-5", "1000-0", -1},
+		{"1700000000001-0", "1700000000000-99", 1},
+		{"9", "10", -1},
+	}
+	for _, tc := range cases {
+		if got := CompareIDs(tc.a, tc.b); got != tc.want {
+			t.Errorf("CompareIDs(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestSequencer_ReplaysExpiredInPublishOrder(t *testing.T) {
+	s := NewSequencer(Redelivery{Visibility: time.Second, MaxDeliveries: 5})
+	ids := []string{"1700000000000-10", "1700000000001-2", "1700000000001-10", "1700000000010-0"}
+	for _, id := range ids {
+		s.Add(Message{ID: id})
+	}
+
+	now := time.Now()
+	if out, _ := s.Next(now, 10); len(out) != len(ids) {
+		t.Fatalf("first delivery: got %d messages, want %d", len(out), len(ids))
+	}
+
+	out, dropped := s.Next(now.Add(2*time.Second), 10)
+	if len(dropped) != 0 {
+		t.Fatalf("unexpected drops %+v", dropped)
+	}
+	got := make([]string, 0, len(out))
+	for _, m := range out {
+		got = append(got, m.ID)
+	}
+	if !slices.Equal(got, ids) {
+		t.Fatalf("redelivered %v, want %v", got, ids)
+	}
+}
@@ -0,0 +1,128 @@
+package incidentscache
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+)
+
+// A cell's entry holds the active incidents reaching into it. Its key
+// carries two versions: the generation of all cells, bumped when every cell
+// is dropped at once, and the cell's own version in a hash, bumped when an
+// incident reaching into it changes. A check that read the database before
+// a concurrent write committed stores its result under the old key, where
+// nobody reads it again.
+const (
+	cellsVersionKey = "incidents:cells:version"
+	cellVersionsKey = "incidents:cells:versions"
+)
+
+// FindNearby matches p against the incidents cached for its cell, computing
+// distances in Go. Without cells, or while the cache is bypassed, the
+// repository answers instead.
+func (c *CachedRepository) FindNearby(ctx context.Context, p incidents.Point, limit int) ([]incidents.NearbyIncident, error) {
+	if c.cells == nil {
+		return c.next.FindNearby(ctx, p, limit)
+	}
+
+	cell := c.cells.cellOf(p)
+	key, err := c.cellKey(ctx, cell)
+	if err != nil {
+		c.observe(cellCache, lookupResult(err))
+		return c.next.FindNearby(ctx, p, limit)
+	}
+
+	var b []byte
+	err = c.call(ctx, func(ctx context.Context) error {
+		var err error
+		b, err = c.rdb.Get(ctx, key).Bytes()
+		return err
+	})
+	switch {
+	case err == nil:
+		var cached []incidents.Incident
+		if err := json.Unmarshal(b, &cached); err == nil {
+			c.observe(cellCache, "hit")
+			return incidents.Nearby(cached, p, limit), nil
+		}
+		c.observe(cellCache, "error")
+	default:
+		c.observe(cellCache, lookupResult(err))
+	}
+
+	items, err := c.next.ListActiveNear(ctx, c.cells.box(cell))
+	if err != nil {
+		return nil, err
+	}
+
+	if b, err := json.Marshal(items); err == nil {
+		err := c.call(ctx, func(ctx context.Context) error {
+			return c.rdb.Set(ctx, key, b, c.cellTTL).Err()
+		})
+		c.logError(ctx, "incidents cell cache set failed", err)
+	}
+
+	return incidents.Nearby(items, p, limit), nil
+}
+
+// cellKey returns the key of the cell's current entry.
+func (c *CachedRepository) cellKey(ctx context.Context, cell string) (string, error) {
+	if err := c.clean(ctx); err != nil {
+		return "", err
+	}
+
+	var gen, ver string
+	err := c.call(ctx, func(ctx context.Context) error {
+		pipe := c.rdb.Pipeline()
+		genCmd := pipe.Get(ctx, cellsVersionKey)
+		verCmd := pipe.HGet(ctx, cellVersionsKey, cell)
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		gen, ver = cmp.Or(genCmd.Val(), "0"), cmp.Or(verCmd.Val(), "0")
+		return nil
+	})
+	if err != nil {
+		c.logError(ctx, "incidents cell cache version get failed", err)
+		return "", err
+	}
+	return fmt.Sprintf("incidents:cell:g%s:%s:v%s", gen, cell, ver), nil
+}
+
+// invalidateCells drops the cells the changed incidents reach into, or
+// every cell if they reach into more than the limit.
+func (c *CachedRepository) invalidateCells(ctx context.Context, changed []incidents.Incident) error {
+	if c.cells == nil || len(changed) == 0 {
+		return nil
+	}
+
+	var cells []string
+	for _, inc := range changed {
+		cs, ok := c.cells.cover(inc.Bounds(), c.cellLimit-len(cells))
+		if !ok {
+			err := c.call(ctx, func(ctx context.Context) error {
+				return c.rdb.Incr(ctx, cellsVersionKey).Err()
+			})
+			c.logError(ctx, "incidents cell cache flush failed", err)
+			return err
+		}
+		cells = append(cells, cs...)
+	}
+
+	err := c.call(ctx, func(ctx context.Context) error {
+		pipe := c.rdb.Pipeline()
+		for _, cell := range cells {
+			pipe.HIncrBy(ctx, cellVersionsKey, cell, 1)
+		}
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	c.logError(ctx, "incidents cell cache invalidation failed", err)
+	return err
+}
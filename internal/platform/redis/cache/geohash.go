@@ -0,0 +1,111 @@
+package incidentscache
+
+import (
+	"math"
+	"strings"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+)
+
+// Location checks are cached per geohash cell. A geohash of n characters
+// interleaves 5n bits, longitude first, so a cell is one square of a grid
+// of 2^lonBits by 2^latBits over the whole map.
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+type grid struct {
+	precision        int
+	latBits, lonBits int
+}
+
+func newGrid(precision int) grid {
+	bits := 5 * precision
+	return grid{precision: precision, latBits: bits / 2, lonBits: (bits + 1) / 2}
+}
+
+func (g grid) cellHeight() float64 { return 180 / float64(uint64(1)<<g.latBits) }
+func (g grid) cellWidth() float64  { return 360 / float64(uint64(1)<<g.lonBits) }
+
+func (g grid) latIndex(lat float64) uint64 {
+	n := uint64(1) << g.latBits
+	return min(uint64(max(math.Floor((lat+90)/g.cellHeight()), 0)), n-1)
+}
+
+func (g grid) lonIndex(lon float64) uint64 {
+	n := uint64(1) << g.lonBits
+	return min(uint64(max(math.Floor((lon+180)/g.cellWidth()), 0)), n-1)
+}
+
+// cellOf returns the geohash of the cell containing p.
+func (g grid) cellOf(p incidents.Point) string {
+	return g.encode(g.latIndex(p.Lat), g.lonIndex(p.Lon))
+}
+
+// cover returns the cells b overlaps, or false if there are more than limit.
+func (g grid) cover(b incidents.Box, limit int) ([]string, bool) {
+	parts := b.Split()
+
+	n := 0
+	for _, p := range parts {
+		n += int((g.latIndex(p.MaxLat) - g.latIndex(p.MinLat) + 1) * (g.lonIndex(p.MaxLon) - g.lonIndex(p.MinLon) + 1))
+	}
+	if n > limit {
+		return nil, false
+	}
+
+	cells := make([]string, 0, n)
+	for _, p := range parts {
+		for i := g.latIndex(p.MinLat); i <= g.latIndex(p.MaxLat); i++ {
+			for j := g.lonIndex(p.MinLon); j <= g.lonIndex(p.MaxLon); j++ {
+				cells = append(cells, g.encode(i, j))
+			}
+		}
+	}
+	return cells, true
+}
+
+// box returns the bounds of the cell with the given geohash.
+func (g grid) box(hash string) incidents.Box {
+	var lat, lon uint64
+	bit := 0
+	for _, c := range hash {
+		v := strings.IndexRune(geohashAlphabet, c)
+		for k := 4; k >= 0; k-- {
+			b := uint64(v>>k) & 1
+			if bit%2 == 0 {
+				lon = lon<<1 | b
+			} else {
+				lat = lat<<1 | b
+			}
+			bit++
+		}
+	}
+
+	h, w := g.cellHeight(), g.cellWidth()
+	minLat, minLon := float64(lat)*h-90, float64(lon)*w-180
+	return incidents.Box{MinLat: minLat, MinLon: minLon, MaxLat: minLat + h, MaxLon: minLon + w}
+}
+
+func (g grid) encode(lat, lon uint64) string {
+	var sb strings.Builder
+	sb.Grow(g.precision)
+
+	latBit, lonBit := g.latBits, g.lonBits
+	v, n := 0, 0
+	for bit := range 5 * g.precision {
+		var b uint64
+		if bit%2 == 0 {
+			lonBit--
+			b = lon >> lonBit & 1
+		} else {
+			latBit--
+			b = lat >> latBit & 1
+		}
+		v = v<<1 | int(b)
+		if n++; n == 5 {
+			sb.WriteByte(geohashAlphabet[v])
+			v, n = 0, 0
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,61 @@
+package incidentscache
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+)
+
+func TestGrid_CellOf(t *testing.T) {
+	tests := []struct {
+		p         incidents.Point
+		precision int
+		want      string
+	}{
+		{incidents.Point{Lat: 57.64911, Lon: 10.40744}, 11, "u4pruydqqvj"},
+		{incidents.Point{Lat: 55.7558, Lon: 37.6173}, 5, "ucfv0"},
+		{incidents.Point{Lat: -90, Lon: -180}, 3, "000"},
+		{incidents.Point{Lat: 90, Lon: 180}, 3, "zzz"},
+	}
+	for _, tt := range tests {
+		g := newGrid(tt.precision)
+		got := g.cellOf(tt.p)
+		if got != tt.want {
+			t.Fatalf("cellOf(%v, %d) = %q, want %q", tt.p, tt.precision, got, tt.want)
+		}
+		if b := g.box(got); !b.Contains(tt.p) {
+			t.Fatalf("box(%q) = %+v does not contain %v", got, b, tt.p)
+		}
+	}
+}
+
+func TestGrid_Cover(t *testing.T) {
+	g := newGrid(5)
+	inc := incidents.Incident{Center: incidents.Point{Lat: 55.7558, Lon: 37.6173}, Radius: 3000}
+
+	cells, ok := g.cover(inc.Bounds(), 100)
+	if !ok {
+		t.Fatal("expected the incident to fit the limit")
+	}
+	for _, p := range []incidents.Point{
+		inc.Center,
+		{Lat: inc.Center.Lat + 0.0269, Lon: inc.Center.Lon},
+		{Lat: inc.Center.Lat, Lon: inc.Center.Lon - 0.0477},
+	} {
+		if !slices.Contains(cells, g.cellOf(p)) {
+			t.Fatalf("cell of %v missing from cover %v", p, cells)
+		}
+	}
+
+	if _, ok := g.cover(inc.Bounds(), len(cells)-1); ok {
+		t.Fatal("expected the cover to exceed a smaller limit")
+	}
+
+	// Crossing the antimeridian covers cells on both sides.
+	edge := incidents.Incident{Center: incidents.Point{Lat: 0, Lon: 179.99}, Radius: 5000}
+	cells, _ = g.cover(edge.Bounds(), 100)
+	if !slices.Contains(cells, g.cellOf(incidents.Point{Lat: 0, Lon: -179.99})) {
+		t.Fatalf("cover %v misses the far side of the antimeridian", cells)
+	}
+}
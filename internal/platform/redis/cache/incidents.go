@@ -1,22 +1,52 @@
+// Package incidentscache caches lists of active incidents and, per geohash
+// cell, the incidents location checks are matched against in Redis. The
+// cache fails open: when Redis is slow or down, a circuit breaker routes
+// reads straight to the repository until Redis recovers.
 package incidentscache
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 
 	incidentsapp "github.com/m1ll3r1337/geo-notifications-service/internal/app/incidents"
 	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/breaker"
 )
 
+// ErrDegraded is reported by Report while the cache is bypassed.
+var ErrDegraded = errors.New("incidents cache bypassed")
+
 type Logger interface {
 	Info(ctx context.Context, msg string, args ...any)
 	Error(ctx context.Context, msg string, args ...any)
 }
 
+// Metrics records cache lookups and whether the cache is bypassed.
+type Metrics interface {
+	ObserveCache(cache, result string)
+	SetCacheDegraded(cache string, degraded bool)
+}
+
+// Cache names label lookups in Metrics.
+const (
+	listCache = "incidents_active"
+	cellCache = "incidents_cells"
+)
+
+const versionKey = "incidents:active:version"
+
+// Repository is what the cache reads through to.
+type Repository interface {
+	incidentsapp.IncidentsRepository
+	ListActiveNear(ctx context.Context, b incidents.Box) ([]incidents.Incident, error)
+}
+
 type Option func(*CachedRepository)
 
 func WithTTL(ttl time.Duration) Option {
@@ -27,18 +57,82 @@ func WithLogger(log Logger) Option {
 	return func(c *CachedRepository) { c.log = log }
 }
 
+// WithMetrics counts hits, misses, errors and bypassed lookups.
+func WithMetrics(m Metrics) Option {
+	return func(c *CachedRepository) { c.metrics = m }
+}
+
+// WithTimeout bounds every Redis call; a slow cache is no better than none.
+func WithTimeout(d time.Duration) Option {
+	return func(c *CachedRepository) {
+		if d > 0 {
+			c.timeout = d
+		}
+	}
+}
+
+// WithBreaker configures the breaker that bypasses Redis after consecutive
+// failures.
+func WithBreaker(cfg breaker.Config) Option {
+	return func(c *CachedRepository) { c.breaker = breaker.New(cfg) }
+}
+
+// WithCells caches location checks per geohash cell of the given precision
+// (1 to 12 characters) for ttl. Without it FindNearby goes to the repository.
+func WithCells(precision int, ttl time.Duration) Option {
+	return func(c *CachedRepository) {
+		if precision < 1 || precision > 12 {
+			c.cells = nil
+			return
+		}
+		g := newGrid(precision)
+		c.cells = &g
+		if ttl > 0 {
+			c.cellTTL = ttl
+		}
+	}
+}
+
+// WithCellInvalidateLimit caps the cells a single write invalidates one by
+// one; a write to a larger incident drops every cell at once.
+func WithCellInvalidateLimit(n int) Option {
+	return func(c *CachedRepository) {
+		if n > 0 {
+			c.cellLimit = n
+		}
+	}
+}
+
 type CachedRepository struct {
-	next incidentsapp.IncidentsRepository
+	next Repository
 	rdb  *redis.Client
 	ttl  time.Duration
 	log  Logger
+
+	cells     *grid
+	cellTTL   time.Duration
+	cellLimit int
+
+	timeout time.Duration
+	breaker *breaker.Breaker
+	// dirty is set when an invalidation could not reach Redis: cached
+	// entries may be stale, so the cache is bypassed until everything in it
+	// has been dropped. Only this instance knows; other replicas serve
+	// stale entries until they expire, which the TTLs bound.
+	dirty atomic.Bool
+
+	metrics Metrics
 }
 
-func New(rdb *redis.Client, next incidentsapp.IncidentsRepository, opts ...Option) *CachedRepository {
+func New(rdb *redis.Client, next Repository, opts ...Option) *CachedRepository {
 	c := &CachedRepository{
-		next: next,
-		rdb:  rdb,
-		ttl:  60 * time.Second,
+		next:      next,
+		rdb:       rdb,
+		ttl:       60 * time.Second,
+		cellTTL:   time.Minute,
+		cellLimit: 256,
+		timeout:   100 * time.Millisecond,
+		breaker:   breaker.New(breaker.Config{FailureThreshold: 5, OpenTimeout: 10 * time.Second}),
 	}
 	for _, opt := range opts {
 		opt(c)
@@ -46,14 +140,6 @@ func New(rdb *redis.Client, next incidentsapp.IncidentsRepository, opts ...Optio
 	return c
 }
 
-func (c *CachedRepository) Create(ctx context.Context, in incidents.CreateIncident) (incidents.Incident, error) {
-	inc, err := c.next.Create(ctx, in)
-	if err == nil {
-		_ = c.bumpVersion(ctx)
-	}
-	return inc, err
-}
-
 func (c *CachedRepository) GetByID(ctx context.Context, id int64) (incidents.Incident, error) {
 	return c.next.GetByID(ctx, id)
 }
@@ -63,14 +149,29 @@ func (c *CachedRepository) List(ctx context.Context, f incidents.ListFilter) ([]
 		return c.next.List(ctx, f)
 	}
 
-	ver := c.getVersion(ctx)
+	ver, err := c.version(ctx)
+	if err != nil {
+		c.observe(listCache, lookupResult(err))
+		return c.next.List(ctx, f)
+	}
 	key := fmt.Sprintf("incidents:active:v%s:limit:%d:offset:%d", ver, f.Limit, f.Offset)
 
-	if b, err := c.rdb.Get(ctx, key).Bytes(); err == nil {
+	var b []byte
+	err = c.call(ctx, func(ctx context.Context) error {
+		var err error
+		b, err = c.rdb.Get(ctx, key).Bytes()
+		return err
+	})
+	switch {
+	case err == nil:
 		var cached []incidents.Incident
 		if err := json.Unmarshal(b, &cached); err == nil {
+			c.observe(listCache, "hit")
 			return cached, nil
 		}
+		c.observe(listCache, "error")
+	default:
+		c.observe(listCache, lookupResult(err))
 	}
 
 	items, err := c.next.List(ctx, f)
@@ -79,59 +180,172 @@ func (c *CachedRepository) List(ctx context.Context, f incidents.ListFilter) ([]
 	}
 
 	if b, err := json.Marshal(items); err == nil {
-		if err := c.rdb.Set(ctx, key, b, c.ttl).Err(); err != nil && c.log != nil {
-			c.log.Error(ctx, "incidents cache set failed", "error", err)
-		}
+		err := c.call(ctx, func(ctx context.Context) error {
+			return c.rdb.Set(ctx, key, b, c.ttl).Err()
+		})
+		c.logError(ctx, "incidents cache set failed", err)
 	}
 
 	return items, nil
 }
 
-func (c *CachedRepository) Update(ctx context.Context, id int64, in incidents.UpdateIncident) (incidents.Incident, error) {
-	inc, err := c.next.Update(ctx, id, in)
-	if err == nil {
-		_ = c.bumpVersion(ctx)
+// Invalidate drops every cached list of active incidents and the cells the
+// changed incidents reach into. It is called after a write to the incident
+// set has committed, with the incidents as they were before and after it.
+// If Redis cannot be reached the cache stays bypassed until a later
+// invalidation gets through.
+func (c *CachedRepository) Invalidate(ctx context.Context, changed ...incidents.Incident) {
+	var err error
+	if c.dirty.Load() {
+		err = c.flush(ctx)
+	} else if err = c.bumpVersion(ctx); err == nil {
+		err = c.invalidateCells(ctx, changed)
+	}
+	c.dirty.Store(err != nil)
+	c.setDegraded()
+}
+
+func (c *CachedRepository) CountUniqueUsersSince(ctx context.Context, since time.Time) (int, error) {
+	return c.next.CountUniqueUsersSince(ctx, since)
+}
+
+// cacheStatus is the cache's state as reported in health output.
+type cacheStatus struct {
+	Breaker string `json:"breaker"`
+	// Dirty is true while a failed invalidation keeps the cache bypassed.
+	Dirty bool `json:"dirty,omitempty"`
+}
+
+// Report returns the breaker state, failing with ErrDegraded while reads
+// bypass the cache. The service keeps working, only slower.
+func (c *CachedRepository) Report(context.Context) (any, error) {
+	st := cacheStatus{Breaker: c.breaker.State().String(), Dirty: c.dirty.Load()}
+	if st.Breaker != breaker.StateClosed.String() || st.Dirty {
+		return st, ErrDegraded
 	}
-	return inc, err
+	return st, nil
 }
 
-func (c *CachedRepository) Deactivate(ctx context.Context, id int64) error {
-	err := c.next.Deactivate(ctx, id)
-	if err == nil {
-		_ = c.bumpVersion(ctx)
+// call runs fn against Redis within the timeout, through the breaker. A
+// missing key is a success; an open breaker returns breaker.ErrOpen without
+// calling fn.
+func (c *CachedRepository) call(ctx context.Context, fn func(context.Context) error) error {
+	if err := c.breaker.Allow(); err != nil {
+		c.setDegraded()
+		return err
 	}
+
+	callCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	err := fn(callCtx)
+	switch {
+	case err == nil || errors.Is(err, redis.Nil):
+		c.breaker.Success()
+	case ctx.Err() != nil:
+		// The caller went away; that says nothing about Redis.
+		c.breaker.Cancel()
+	default:
+		c.breaker.Failure()
+	}
+	c.setDegraded()
 	return err
 }
 
-func (c *CachedRepository) FindNearby(ctx context.Context, p incidents.Point, limit int) ([]incidents.NearbyIncident, error) {
-	return c.next.FindNearby(ctx, p, limit)
+// clean drops everything cached while the cache is dirty. It fails as long
+// as that cannot be done, so stale entries are never served.
+func (c *CachedRepository) clean(ctx context.Context) error {
+	if !c.dirty.Load() {
+		return nil
+	}
+	if err := c.flush(ctx); err != nil {
+		return err
+	}
+	c.dirty.Store(false)
+	c.setDegraded()
+	return nil
 }
 
-func (c *CachedRepository) CountUniqueUsersSince(ctx context.Context, since time.Time) (int, error) {
-	return c.next.CountUniqueUsersSince(ctx, since)
+// flush drops every cached list and cell by bumping both versions.
+func (c *CachedRepository) flush(ctx context.Context) error {
+	err := c.call(ctx, func(ctx context.Context) error {
+		pipe := c.rdb.TxPipeline()
+		pipe.Incr(ctx, versionKey)
+		pipe.Incr(ctx, cellsVersionKey)
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	c.logError(ctx, "incidents cache flush failed", err)
+	return err
 }
 
-func (c *CachedRepository) getVersion(ctx context.Context) string {
-	const key = "incidents:active:version"
-	val, err := c.rdb.Get(ctx, key).Result()
-	if err == redis.Nil {
-		_ = c.rdb.SetNX(ctx, key, "1", 0).Err()
-		return "1"
+// version returns the current version of the cached lists.
+func (c *CachedRepository) version(ctx context.Context) (string, error) {
+	if err := c.clean(ctx); err != nil {
+		return "", err
+	}
+
+	var val string
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		val, err = c.rdb.Get(ctx, versionKey).Result()
+		return err
+	})
+	if errors.Is(err, redis.Nil) {
+		_ = c.call(ctx, func(ctx context.Context) error {
+			return c.rdb.SetNX(ctx, versionKey, "1", 0).Err()
+		})
+		return "1", nil
 	}
 	if err != nil {
-		if c.log != nil {
-			c.log.Error(ctx, "incidents cache version get failed", "error", err)
-		}
-		return "0"
+		c.logError(ctx, "incidents cache version get failed", err)
+		return "", err
 	}
-	return val
+	return val, nil
 }
 
 func (c *CachedRepository) bumpVersion(ctx context.Context) error {
-	const key = "incidents:active:version"
-	if err := c.rdb.Incr(ctx, key).Err(); err != nil && c.log != nil {
-		c.log.Error(ctx, "incidents cache version bump failed", "error", err)
-		return err
+	err := c.call(ctx, func(ctx context.Context) error {
+		return c.rdb.Incr(ctx, versionKey).Err()
+	})
+	c.logError(ctx, "incidents cache version bump failed", err)
+	return err
+}
+
+// logError logs a failed Redis call. Calls turned away by the open breaker
+// are not logged; the failures that opened it were.
+func (c *CachedRepository) logError(ctx context.Context, msg string, err error) {
+	if err != nil && !errors.Is(err, breaker.ErrOpen) && c.log != nil {
+		c.log.Error(ctx, msg, "error", err)
+	}
+}
+
+func (c *CachedRepository) observe(cache, result string) {
+	if c.metrics != nil {
+		c.metrics.ObserveCache(cache, result)
+	}
+}
+
+func (c *CachedRepository) setDegraded() {
+	if c.metrics == nil {
+		return
+	}
+	degraded := c.breaker.State() != breaker.StateClosed || c.dirty.Load()
+	c.metrics.SetCacheDegraded(listCache, degraded)
+	if c.cells != nil {
+		c.metrics.SetCacheDegraded(cellCache, degraded)
+	}
+}
+
+// lookupResult labels a failed lookup: a miss, a bypass while the breaker
+// is open or the cache dirty, or an error.
+func lookupResult(err error) string {
+	switch {
+	case errors.Is(err, redis.Nil):
+		return "miss"
+	case errors.Is(err, breaker.ErrOpen):
+		return "bypass"
+	default:
+		return "error"
 	}
-	return nil
 }
@@ -0,0 +1,183 @@
+package incidentscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/breaker"
+)
+
+type listRepo struct {
+	Repository
+	items []incidents.Incident
+}
+
+func (r listRepo) List(context.Context, incidents.ListFilter) ([]incidents.Incident, error) {
+	return r.items, nil
+}
+
+type countingMetrics struct {
+	results  map[string]int
+	degraded bool
+}
+
+func (m *countingMetrics) ObserveCache(_, result string) { m.results[result]++ }
+
+func (m *countingMetrics) SetCacheDegraded(_ string, degraded bool) { m.degraded = degraded }
+
+func TestCachedRepository_FailsOpen(t *testing.T) {
+	// Nothing listens on port 1, so every Redis call fails at once.
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rdb.Close()
+
+	m := &countingMetrics{results: map[string]int{}}
+	c := New(rdb, listRepo{items: []incidents.Incident{{ID: 1}}},
+		WithBreaker(breaker.Config{FailureThreshold: 2, OpenTimeout: time.Minute}),
+		WithMetrics(m),
+	)
+	ctx := context.Background()
+
+	for range 3 {
+		items, err := c.List(ctx, incidents.ListFilter{ActiveOnly: true, Limit: 10})
+		if err != nil || len(items) != 1 {
+			t.Fatalf("List must fall back to the repository, got %v, %v", items, err)
+		}
+	}
+
+	if m.results["error"] != 2 || m.results["bypass"] != 1 {
+		t.Fatalf("expected 2 errors then a bypass, got %v", m.results)
+	}
+	if !m.degraded {
+		t.Fatal("expected the cache to be reported degraded")
+	}
+	if _, err := c.Report(ctx); !errors.Is(err, ErrDegraded) {
+		t.Fatalf("Report: got %v, want ErrDegraded", err)
+	}
+}
+
+// fakeRedis answers GET, SET, SETNX and INCR from a map through a client
+// hook, without a server. While failing is set every command fails.
+type fakeRedis struct {
+	mu      sync.Mutex
+	data    map[string]string
+	failing bool
+}
+
+func newFakeRedis() (*redis.Client, *fakeRedis) {
+	f := &fakeRedis{data: map[string]string{}}
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	rdb.AddHook(f)
+	return rdb, f
+}
+
+func (f *fakeRedis) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (f *fakeRedis) ProcessHook(redis.ProcessHook) redis.ProcessHook {
+	return func(_ context.Context, cmd redis.Cmder) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.process(cmd)
+		return cmd.Err()
+	}
+}
+
+func (f *fakeRedis) ProcessPipelineHook(redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(_ context.Context, cmds []redis.Cmder) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, cmd := range cmds {
+			f.process(cmd)
+			if err := cmd.Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (f *fakeRedis) process(cmd redis.Cmder) {
+	if f.failing {
+		cmd.SetErr(&net.OpError{Op: "dial", Err: errors.New("connection refused")})
+		return
+	}
+
+	args := cmd.Args()
+	key := ""
+	if len(args) > 1 {
+		key, _ = args[1].(string)
+	}
+	switch c := cmd.(type) {
+	case *redis.StringCmd:
+		if v, ok := f.data[key]; ok {
+			c.SetVal(v)
+		} else {
+			c.SetErr(redis.Nil)
+		}
+	case *redis.StatusCmd:
+		f.data[key] = string(args[2].([]byte))
+		c.SetVal("OK")
+	case *redis.BoolCmd:
+		if _, ok := f.data[key]; !ok {
+			f.data[key] = args[2].(string)
+		}
+		c.SetVal(true)
+	case *redis.IntCmd:
+		n, _ := strconv.ParseInt(f.data[key], 10, 64)
+		f.data[key] = strconv.FormatInt(n+1, 10)
+		c.SetVal(n + 1)
+	}
+}
+
+func (f *fakeRedis) fail(failing bool) {
+	f.mu.Lock()
+	f.failing = failing
+	f.mu.Unlock()
+}
+
+func TestCachedRepository_CountsLookups(t *testing.T) {
+	rdb, fake := newFakeRedis()
+	defer rdb.Close()
+
+	m := &countingMetrics{results: map[string]int{}}
+	c := New(rdb, listRepo{items: []incidents.Incident{{ID: 1}}}, WithMetrics(m))
+	ctx := context.Background()
+	f := incidents.ListFilter{ActiveOnly: true, Limit: 10}
+
+	list := func() {
+		t.Helper()
+		items, err := c.List(ctx, f)
+		if err != nil || len(items) != 1 || items[0].ID != 1 {
+			t.Fatalf("List: got %v, %v", items, err)
+		}
+	}
+
+	// The first lookup misses and fills the cache, the second hits it.
+	list()
+	list()
+	if m.results["miss"] != 1 || m.results["hit"] != 1 {
+		t.Fatalf("expected a miss then a hit, got %v", m.results)
+	}
+
+	// A failing Redis is counted as an error and read through.
+	fake.fail(true)
+	list()
+	if m.results["error"] != 1 {
+		t.Fatalf("expected an error, got %v", m.results)
+	}
+
+	// Inactive lists are not cached and not counted.
+	if _, err := c.List(ctx, incidents.ListFilter{Limit: 10}); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if total := m.results["miss"] + m.results["hit"] + m.results["error"] + m.results["bypass"]; total != 3 {
+		t.Fatalf("expected 3 counted lookups, got %v", m.results)
+	}
+}
@@ -0,0 +1,33 @@
+// Package deduperedis remembers delivered (outbox event, subscription) pairs
+// in Redis keys that expire after a TTL.
+package deduperedis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Store struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+func New(rdb *redis.Client, ttl time.Duration) *Store {
+	return &Store{rdb: rdb, ttl: ttl}
+}
+
+func key(outboxID, subscriptionID int64) string {
+	return fmt.Sprintf("processed:%d:%d", outboxID, subscriptionID)
+}
+
+func (s *Store) Delivered(ctx context.Context, outboxID, subscriptionID int64) (bool, error) {
+	n, err := s.rdb.Exists(ctx, key(outboxID, subscriptionID)).Result()
+	return n > 0, err
+}
+
+func (s *Store) MarkDelivered(ctx context.Context, outboxID, subscriptionID int64) error {
+	return s.rdb.Set(ctx, key(outboxID, subscriptionID), "1", s.ttl).Err()
+}
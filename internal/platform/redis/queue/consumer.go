@@ -0,0 +1,339 @@
+package redisqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
+)
+
+type Logger interface {
+	Info(ctx context.Context, msg string, args ...any)
+	Error(ctx context.Context, msg string, args ...any)
+}
+
+const (
+	// staleConsumerIdle is how long a consumer without pending messages may
+	// stay idle before pruneConsumers removes it from the group.
+	staleConsumerIdle = time.Hour
+	readBlock         = 2 * time.Second
+	// heldBlock bounds a read while buffered messages wait for their key,
+	// so they are handed out soon after the message ahead is acked.
+	heldBlock = 100 * time.Millisecond
+	// bufferSize caps how many read messages are buffered per consumer.
+	bufferSize = 1000
+)
+
+// ConsumerName returns a consumer name unique to this process, built from
+// the hostname and pid.
+func ConsumerName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+type ConsumerOption func(*Consumer)
+
+// WithPartitions sets how many partition streams the consumer reads; it
+// must match the publisher's.
+func WithPartitions(n int) ConsumerOption {
+	return func(c *Consumer) {
+		if n > 0 {
+			c.partitions = n
+		}
+	}
+}
+
+// WithLease sets how long a partition stays with a consumer that stopped
+// renewing its lease.
+func WithLease(d time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		if d > 0 {
+			c.lease = d
+		}
+	}
+}
+
+// Consumer reads the partition streams it holds a lease on as one consumer
+// of a group. Read entries are handed out through a queue.Sequencer, one
+// per ordering key at a time; entries not acked within the visibility
+// timeout are handed out again by the same consumer, which owns the
+// partition exclusively.
+type Consumer struct {
+	rdb        *redis.Client
+	stream     string
+	group      string
+	name       string
+	redelivery queue.Redelivery
+	log        Logger
+	partitions int
+	lease      time.Duration
+
+	seq *queue.Sequencer
+
+	// Fields below are used only by the goroutine calling Receive.
+	setup         sync.Once
+	owned         map[int]*partition
+	lastRebalance time.Time
+}
+
+var _ queue.Consumer = (*Consumer)(nil)
+
+// NewConsumer returns a consumer of group on stream. An empty name is
+// replaced by ConsumerName.
+func NewConsumer(rdb *redis.Client, stream, group, name string, r queue.Redelivery, log Logger, opts ...ConsumerOption) *Consumer {
+	if name == "" {
+		name = ConsumerName()
+	}
+	c := &Consumer{
+		rdb:        rdb,
+		stream:     stream,
+		group:      group,
+		name:       name,
+		redelivery: r.WithDefaults(),
+		log:        log,
+		partitions: 1,
+		lease:      15 * time.Second,
+		owned:      make(map[int]*partition),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.seq = queue.NewSequencer(c.redelivery)
+	return c
+}
+
+func (c *Consumer) Name() string { return c.name }
+
+func (c *Consumer) Receive(ctx context.Context, max int) ([]queue.Message, error) {
+	c.setup.Do(func() {
+		for p := range c.partitions {
+			_ = c.rdb.XGroupCreateMkStream(ctx, StreamName(c.stream, p, c.partitions), c.group, "0").Err()
+		}
+		c.pruneConsumers(ctx)
+	})
+
+	if time.Since(c.lastRebalance) >= c.lease/3 {
+		c.lastRebalance = time.Now()
+		if err := c.rebalance(ctx); err != nil {
+			return nil, fmt.Errorf("partitions: %w", err)
+		}
+	}
+
+	if msgs := c.next(ctx, max); len(msgs) > 0 {
+		return msgs, nil
+	}
+
+	var streams []string
+	for _, p := range c.ownedPartitions() {
+		if st := c.owned[p]; st.ready && !st.releasing {
+			streams = append(streams, st.stream)
+		}
+	}
+	held := c.seq.Count(func(queue.Message) bool { return true })
+	room := bufferSize - held
+
+	block := min(readBlock, c.lease/3)
+	if held > 0 {
+		block = heldBlock
+	}
+	if len(streams) == 0 || room <= 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(block):
+		}
+		return c.next(ctx, max), nil
+	}
+
+	args := make([]string, 0, 2*len(streams))
+	args = append(args, streams...)
+	for range streams {
+		args = append(args, ">")
+	}
+	res, err := c.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    c.group,
+		Consumer: c.name,
+		Streams:  args,
+		Count:    int64(room),
+		Block:    block,
+	}).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	for _, st := range res {
+		c.seq.Add(c.decode(ctx, c.partitionOf(st.Stream), st.Messages)...)
+	}
+	return c.next(ctx, max), nil
+}
+
+// next hands out buffered messages, acknowledging those the sequencer
+// dropped after MaxDeliveries.
+func (c *Consumer) next(ctx context.Context, max int) []queue.Message {
+	out, dropped := c.seq.Next(time.Now(), max)
+	for _, m := range dropped {
+		c.log.Error(ctx, "queue message dropped after max deliveries", "message_id", m.ID, "outbox_id", m.OutboxID, "deliveries", m.Deliveries)
+		if err := c.xack(ctx, m.ID); err != nil {
+			c.log.Error(ctx, "queue dropped message ack failed", "error", err, "message_id", m.ID)
+		}
+	}
+	return out
+}
+
+func (c *Consumer) Ack(ctx context.Context, id string) error {
+	if err := c.xack(ctx, id); err != nil {
+		return err
+	}
+	c.seq.Done(id)
+	return nil
+}
+
+// Retry keeps the entry pending with this consumer; the sequencer hands it
+// out again after delay.
+func (c *Consumer) Retry(_ context.Context, id string, delay time.Duration) error {
+	c.seq.Retry(id, time.Now(), delay)
+	return nil
+}
+
+func (c *Consumer) xack(ctx context.Context, id string) error {
+	p, sid, ok := parseMessageID(id)
+	if !ok {
+		return fmt.Errorf("invalid message id %q", id)
+	}
+	return c.rdb.XAck(ctx, StreamName(c.stream, p, c.partitions), c.group, sid).Err()
+}
+
+// messageID prefixes a stream entry id with its partition, since entry ids
+// are unique only within a stream.
+func messageID(p int, entryID string) string { return strconv.Itoa(p) + "/" + entryID }
+
+func parseMessageID(id string) (int, string, bool) {
+	ps, sid, ok := strings.Cut(id, "/")
+	if !ok {
+		return 0, "", false
+	}
+	p, err := strconv.Atoi(ps)
+	if err != nil {
+		return 0, "", false
+	}
+	return p, sid, true
+}
+
+func streamID(id string) string {
+	_, sid, _ := parseMessageID(id)
+	return sid
+}
+
+func (c *Consumer) partitionOf(stream string) int {
+	for p, st := range c.owned {
+		if st.stream == stream {
+			return p
+		}
+	}
+	return 0
+}
+
+// decode converts entries of partition p to messages. Malformed entries can
+// never be processed and are acknowledged right away.
+func (c *Consumer) decode(ctx context.Context, p int, msgs []redis.XMessage) []queue.Message {
+	stream := StreamName(c.stream, p, c.partitions)
+	out := make([]queue.Message, 0, len(msgs))
+	for _, m := range msgs {
+		it, err := decodeItem(m.Values)
+		if err != nil {
+			c.log.Error(ctx, "queue message dropped, malformed", "message_id", m.ID, "error", err)
+			_ = c.rdb.XAck(ctx, stream, c.group, m.ID).Err()
+			continue
+		}
+		out = append(out, queue.Message{ID: messageID(p, m.ID), Item: it})
+	}
+	return out
+}
+
+func decodeItem(values map[string]any) (queue.Item, error) {
+	body, ok := values["body"].(string)
+	if !ok {
+		return queue.Item{}, errors.New("missing body")
+	}
+	idStr, ok := values["outbox_id"].(string)
+	if !ok {
+		return queue.Item{}, errors.New("missing outbox_id")
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return queue.Item{}, errors.New("invalid outbox_id")
+	}
+	typ, _ := values["type"].(string)
+	key, _ := values["key"].(string)
+	trace, _ := values["trace"].(string)
+	return queue.Item{EventType: typ, Payload: body, OutboxID: id, Key: key, TraceContext: trace}, nil
+}
+
+// Close releases the consumer's partition leases and removes it from the
+// group on every partition where it owns no pending messages. Consumers
+// that still own pending messages are kept: XGROUP DELCONSUMER would
+// discard those entries, whereas leaving them lets the next owner of the
+// partition take them over.
+func (c *Consumer) Close(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	c.releaseAll(ctx)
+
+	for p := range c.partitions {
+		stream := StreamName(c.stream, p, c.partitions)
+		pending, err := c.rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream:   stream,
+			Group:    c.group,
+			Consumer: c.name,
+			Start:    "-",
+			End:      "+",
+			Count:    1,
+		}).Result()
+		if err != nil {
+			return fmt.Errorf("pending check: %w", err)
+		}
+		if len(pending) > 0 {
+			c.log.Info(ctx, "queue consumer kept, messages still pending", "consumer", c.name, "stream", stream)
+			continue
+		}
+		if err := c.rdb.XGroupDelConsumer(ctx, stream, c.group, c.name).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneConsumers removes consumers left behind by processes that exited
+// without closing, once they own no pending messages.
+func (c *Consumer) pruneConsumers(ctx context.Context) {
+	for p := range c.partitions {
+		stream := StreamName(c.stream, p, c.partitions)
+		consumers, err := c.rdb.XInfoConsumers(ctx, stream, c.group).Result()
+		if err != nil {
+			continue
+		}
+
+		for _, other := range consumers {
+			if other.Name == c.name || other.Pending > 0 || other.Idle < staleConsumerIdle {
+				continue
+			}
+			if err := c.rdb.XGroupDelConsumer(ctx, stream, c.group, other.Name).Err(); err != nil {
+				c.log.Error(ctx, "queue stale consumer delete failed", "error", err, "consumer", other.Name)
+				continue
+			}
+			c.log.Info(ctx, "queue stale consumer removed", "consumer", other.Name, "idle", other.Idle)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+//go:build integration
+
+package redisqueue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
+)
+
+var testRdb *redis.Client
+
+func TestMain(m *testing.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	addr, terminate, err := setupRedis(ctx)
+	cancel()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "integration setup failed:", err)
+		os.Exit(1)
+	}
+	testRdb = redis.NewClient(&redis.Options{Addr: addr})
+
+	code := m.Run()
+
+	_ = testRdb.Close()
+	if terminate != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		_ = terminate(ctx)
+		cancel()
+	}
+	os.Exit(code)
+}
+
+// setupRedis uses TEST_REDIS_ADDR when set, otherwise a throwaway container.
+func setupRedis(ctx context.Context) (string, func(context.Context) error, error) {
+	if addr := os.Getenv("TEST_REDIS_ADDR"); addr != "" {
+		return addr, nil, nil
+	}
+
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "redis:7-alpine",
+			ExposedPorts: []string{"6379/tcp"},
+			WaitingFor:   wait.ForListeningPort("6379/tcp").WithStartupTimeout(60 * time.Second),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("start container: %w", err)
+	}
+	terminate := func(ctx context.Context) error { return c.Terminate(ctx) }
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		_ = terminate(context.Background())
+		return "", nil, fmt.Errorf("container host: %w", err)
+	}
+	port, err := c.MappedPort(ctx, "6379/tcp")
+	if err != nil {
+		_ = terminate(context.Background())
+		return "", nil, fmt.Errorf("container port: %w", err)
+	}
+	return host + ":" + port.Port(), terminate, nil
+}
+
+func flush(t *testing.T) {
+	t.Helper()
+	if err := testRdb.FlushDB(context.Background()).Err(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+}
+
+// receiveUntil calls Receive until it returns messages or the deadline
+// passes.
+func receiveUntil(t *testing.T, c *Consumer, d time.Duration) []queue.Message {
+	t.Helper()
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		msgs, err := c.Receive(context.Background(), 10)
+		if err != nil {
+			t.Fatalf("receive: %v", err)
+		}
+		if len(msgs) > 0 {
+			return msgs
+		}
+	}
+	return nil
+}
+
+func TestPublisher_RoutesByKey(t *testing.T) {
+	flush(t)
+	ctx := context.Background()
+	pub := NewPublisher(testRdb, "events", 4)
+
+	items := []queue.Item{
+		{EventType: "a", Payload: "{}", OutboxID: 1, Key: "user:1"},
+		{EventType: "a", Payload: "{}", OutboxID: 2, Key: "user:2"},
+		{EventType: "a", Payload: "{}", OutboxID: 3, Key: "user:1"},
+		{EventType: "a", Payload: "{}", OutboxID: 4},
+	}
+	if err := pub.Publish(ctx, items); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	for _, it := range items {
+		stream := StreamName("events", partitionOf(it, 4), 4)
+		entries, err := testRdb.XRange(ctx, stream, "-", "+").Result()
+		if err != nil {
+			t.Fatalf("xrange %s: %v", stream, err)
+		}
+		found := false
+		for _, e := range entries {
+			if e.Values["outbox_id"] == fmt.Sprint(it.OutboxID) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("outbox id %d not in %s", it.OutboxID, stream)
+		}
+	}
+
+	// Keyed entries keep their publish order within the stream.
+	entries, _ := testRdb.XRange(ctx, StreamName("events", queue.Partition("user:1", 4), 4), "-", "+").Result()
+	var order []string
+	for _, e := range entries {
+		if e.Values["key"] == "user:1" {
+			order = append(order, e.Values["outbox_id"].(string))
+		}
+	}
+	if len(order) != 2 || order[0] != "1" || order[1] != "3" {
+		t.Fatalf("expected user:1 entries [1 3], got %v", order)
+	}
+}
+
+func TestConsumer_RebalancesAndTakesOverLeases(t *testing.T) {
+	flush(t)
+	ctx := context.Background()
+
+	const (
+		partitions = 4
+		lease      = 300 * time.Millisecond
+	)
+	r := queue.Redelivery{Visibility: 200 * time.Millisecond, MaxDeliveries: 5}
+	newConsumer := func(name string) *Consumer {
+		return NewConsumer(testRdb, "events", "workers", name, r, nopLogger{}, WithPartitions(partitions), WithLease(lease))
+	}
+	a, b := newConsumer("a"), newConsumer("b")
+
+	// Alone, a leases every partition.
+	if _, err := a.Receive(ctx, 10); err != nil {
+		t.Fatalf("receive a: %v", err)
+	}
+	if len(a.owned) != partitions {
+		t.Fatalf("a owns %v, want all %d partitions", a.ownedPartitions(), partitions)
+	}
+
+	// Once b joins, a gives up its excess and b picks it up.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(b.owned) < partitions/2 && time.Now().Before(deadline) {
+		if _, err := b.Receive(ctx, 10); err != nil {
+			t.Fatalf("receive b: %v", err)
+		}
+		if _, err := a.Receive(ctx, 10); err != nil {
+			t.Fatalf("receive a: %v", err)
+		}
+	}
+	if len(a.owned) != partitions/2 || len(b.owned) != partitions/2 {
+		t.Fatalf("expected an even split, a owns %v, b owns %v", a.ownedPartitions(), b.ownedPartitions())
+	}
+	for p := range a.owned {
+		if b.owned[p] != nil {
+			t.Fatalf("partition %d leased by both consumers", p)
+		}
+	}
+
+	// The owner of user:1 reads both of its entries but acks neither, then
+	// stops without releasing its leases.
+	owner, other := a, b
+	if b.owned[queue.Partition("user:1", partitions)] != nil {
+		owner, other = b, a
+	}
+	pub := NewPublisher(testRdb, "events", partitions)
+	if err := pub.Publish(ctx, []queue.Item{
+		{EventType: "a", Payload: "{}", OutboxID: 1, Key: "user:1"},
+		{EventType: "a", Payload: "{}", OutboxID: 2, Key: "user:1"},
+	}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	msgs := receiveUntil(t, owner, 5*time.Second)
+	if len(msgs) != 1 || msgs[0].OutboxID != 1 {
+		t.Fatalf("owner expected outbox id 1, got %+v", msgs)
+	}
+
+	// After the lease and visibility expire the other consumer takes the
+	// partition over and hands the pending entries out in key order.
+	msgs = receiveUntil(t, other, 5*time.Second)
+	if len(msgs) != 1 || msgs[0].OutboxID != 1 {
+		t.Fatalf("takeover expected outbox id 1 first, got %+v", msgs)
+	}
+	if len(other.owned) != partitions {
+		t.Fatalf("expected the survivor to own every partition, owns %v", other.ownedPartitions())
+	}
+	if err := other.Ack(ctx, msgs[0].ID); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	msgs = receiveUntil(t, other, 5*time.Second)
+	if len(msgs) != 1 || msgs[0].OutboxID != 2 {
+		t.Fatalf("expected outbox id 2 after the ack, got %+v", msgs)
+	}
+}
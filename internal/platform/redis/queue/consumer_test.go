@@ -0,0 +1,37 @@
+package redisqueue
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(context.Context, string, ...any)  {}
+func (nopLogger) Error(context.Context, string, ...any) {}
+
+func TestConsumerName(t *testing.T) {
+	host, _ := os.Hostname()
+	name := ConsumerName()
+	if want := host + "-" + strconv.Itoa(os.Getpid()); host != "" && name != want {
+		t.Fatalf("ConsumerName() = %q, want %q", name, want)
+	}
+
+	// Replicas share no hostname and processes on one host no pid, so an
+	// unnamed consumer never takes another one's pending entries for its own.
+	c := NewConsumer(nil, "events", "workers", "", queue.Redelivery{}, nopLogger{})
+	if c.name != name {
+		t.Fatalf("unnamed consumer is called %q, want %q", c.name, name)
+	}
+	if c := NewConsumer(nil, "events", "workers", "worker-1", queue.Redelivery{}, nopLogger{}); c.name != "worker-1" {
+		t.Fatalf("explicit name replaced by %q", c.name)
+	}
+	if !strings.HasSuffix(name, "-"+strconv.Itoa(os.Getpid())) {
+		t.Fatalf("ConsumerName() = %q does not end with the pid", name)
+	}
+}
@@ -0,0 +1,246 @@
+package redisqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
+)
+
+// ErrBacklog is reported by Monitor.Report when the entries not yet acked
+// by a consumer group exceed the configured maximum.
+var ErrBacklog = errors.New("queue backlog over limit")
+
+type MonitorOption func(*Monitor)
+
+// WithTrimInterval sets how often Run trims the streams.
+func WithTrimInterval(d time.Duration) MonitorOption {
+	return func(m *Monitor) {
+		if d > 0 {
+			m.interval = d
+		}
+	}
+}
+
+// WithMaxBacklog sets how many unacknowledged entries a stream may hold
+// before Report fails. Zero disables the check.
+func WithMaxBacklog(n int64) MonitorOption {
+	return func(m *Monitor) {
+		if n >= 0 {
+			m.maxBacklog = n
+		}
+	}
+}
+
+// Monitor trims acknowledged entries from the partition streams and reports
+// how far the consumer group is behind.
+//
+// Trimming uses MINID, never MAXLEN: the cut-off is the oldest entry some
+// group still needs, either its oldest pending entry or the first one it has
+// not read yet, so unacknowledged entries are never removed. A stuck group
+// therefore makes the stream grow; Report flags that once the backlog passes
+// the configured maximum.
+type Monitor struct {
+	rdb        *redis.Client
+	stream     string
+	group      string
+	partitions int
+	log        Logger
+
+	interval   time.Duration
+	maxBacklog int64
+}
+
+func NewMonitor(rdb *redis.Client, stream, group string, partitions int, log Logger, opts ...MonitorOption) *Monitor {
+	m := &Monitor{
+		rdb:        rdb,
+		stream:     stream,
+		group:      group,
+		partitions: max(partitions, 1),
+		log:        log,
+		interval:   30 * time.Second,
+		maxBacklog: 100_000,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Run trims the streams every interval until ctx is canceled.
+func (m *Monitor) Run(ctx context.Context) error {
+	t := time.NewTicker(m.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+
+		n, err := m.Trim(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			m.log.Error(ctx, "queue trim failed", "error", err)
+			continue
+		}
+		if n > 0 {
+			m.log.Info(ctx, "queue trimmed", "entries", n)
+		}
+	}
+}
+
+// Trim removes entries every group has acknowledged and returns how many
+// were removed. Streams without groups are left alone: nothing has read
+// them yet.
+func (m *Monitor) Trim(ctx context.Context) (int64, error) {
+	var total int64
+	for p := range m.partitions {
+		stream := StreamName(m.stream, p, m.partitions)
+
+		minID, ok, err := m.safeMinID(ctx, stream)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", stream, err)
+		}
+		if !ok {
+			continue
+		}
+
+		// The approximate form only removes whole radix tree nodes, all of
+		// whose entries are below minID.
+		n, err := m.rdb.XTrimMinIDApprox(ctx, stream, minID, 0).Result()
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", stream, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// safeMinID returns the oldest entry id any group of stream still needs.
+func (m *Monitor) safeMinID(ctx context.Context, stream string) (string, bool, error) {
+	groups, err := m.rdb.XInfoGroups(ctx, stream).Result()
+	if err != nil {
+		if isNoStream(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	if len(groups) == 0 {
+		return "", false, nil
+	}
+
+	var minID string
+	for _, g := range groups {
+		// Entries after the last delivered one were not read yet. The
+		// last delivered one itself is kept too, which costs one entry
+		// and saves incrementing the id.
+		need := g.LastDeliveredID
+		if g.Pending > 0 {
+			sum, err := m.rdb.XPending(ctx, stream, g.Name).Result()
+			if err != nil {
+				return "", false, err
+			}
+			need = sum.Lower
+		}
+		if minID == "" || queue.CompareIDs(need, minID) < 0 {
+			minID = need
+		}
+	}
+	return minID, true, nil
+}
+
+// Stats describes how far the consumer group is behind across partitions.
+type Stats struct {
+	Group string `json:"group"`
+	// Length is the number of entries kept in the streams.
+	Length int64 `json:"length"`
+	// Lag is the number of entries not yet read by the group, or -1 when
+	// Redis cannot tell.
+	Lag int64 `json:"lag"`
+	// Pending is the number of entries read but not acknowledged.
+	Pending int64 `json:"pending"`
+	// OldestPendingSeconds is how long ago the oldest pending entry was
+	// added.
+	OldestPendingSeconds float64 `json:"oldest_pending_seconds"`
+}
+
+// Stats returns the group's state summed over all partition streams.
+func (m *Monitor) Stats(ctx context.Context) (Stats, error) {
+	now := time.Now()
+	st := Stats{Group: m.group}
+
+	for p := range m.partitions {
+		stream := StreamName(m.stream, p, m.partitions)
+
+		n, err := m.rdb.XLen(ctx, stream).Result()
+		if err != nil {
+			return Stats{}, fmt.Errorf("%s: %w", stream, err)
+		}
+		st.Length += n
+
+		groups, err := m.rdb.XInfoGroups(ctx, stream).Result()
+		if err != nil {
+			if isNoStream(err) {
+				continue
+			}
+			return Stats{}, fmt.Errorf("%s: %w", stream, err)
+		}
+		for _, g := range groups {
+			if g.Name != m.group {
+				continue
+			}
+			if g.Lag < 0 || st.Lag < 0 {
+				st.Lag = -1
+			} else {
+				st.Lag += g.Lag
+			}
+			st.Pending += g.Pending
+			if g.Pending == 0 {
+				continue
+			}
+
+			sum, err := m.rdb.XPending(ctx, stream, g.Name).Result()
+			if err != nil {
+				return Stats{}, fmt.Errorf("%s: %w", stream, err)
+			}
+			if ms, ok := entryMillis(sum.Lower); ok {
+				st.OldestPendingSeconds = max(st.OldestPendingSeconds, now.Sub(time.UnixMilli(ms)).Seconds())
+			}
+		}
+	}
+	return st, nil
+}
+
+// Report returns Stats for the health endpoint, failing with ErrBacklog when
+// the unacknowledged entries exceed the maximum.
+func (m *Monitor) Report(ctx context.Context) (any, error) {
+	st, err := m.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if m.maxBacklog > 0 && st.Lag >= 0 && st.Lag+st.Pending > m.maxBacklog {
+		return st, fmt.Errorf("%w: %d entries", ErrBacklog, st.Lag+st.Pending)
+	}
+	return st, nil
+}
+
+func isNoStream(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "ERR no such key")
+}
+
+// entryMillis returns the millisecond timestamp part of a stream entry id.
+func entryMillis(id string) (int64, bool) {
+	ms, _, _ := strings.Cut(id, "-")
+	n, err := strconv.ParseInt(ms, 10, 64)
+	return n, err == nil
+}
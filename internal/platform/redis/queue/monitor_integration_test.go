@@ -0,0 +1,62 @@
+//go:build integration
+
+package redisqueue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMonitor_TrimNeverRemovesUnacknowledgedEntries(t *testing.T) {
+	flush(t)
+	ctx := context.Background()
+
+	// Enough entries to fill several radix tree nodes, so the approximate
+	// trim actually removes some.
+	var ids []string
+	for i := range 500 {
+		id, err := testRdb.XAdd(ctx, &redis.XAddArgs{Stream: "events", Values: map[string]any{"n": i}}).Result()
+		if err != nil {
+			t.Fatalf("xadd: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := testRdb.XGroupCreate(ctx, "events", "workers", "0").Err(); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	if err := testRdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "workers", Consumer: "c1", Streams: []string{"events", ">"}, Count: 500,
+	}).Err(); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	kept := ids[300]
+	for _, id := range ids {
+		if id != kept {
+			testRdb.XAck(ctx, "events", "workers", id)
+		}
+	}
+
+	// A second group that has never read keeps the whole stream.
+	if err := testRdb.XGroupCreate(ctx, "events", "audit", "0").Err(); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	m := NewMonitor(testRdb, "events", "workers", 1, nopLogger{})
+	if n, err := m.Trim(ctx); err != nil || n != 0 {
+		t.Fatalf("Trim with an unread group removed %d entries, err %v", n, err)
+	}
+
+	// Only entries before the pending one may go.
+	if err := testRdb.XGroupDestroy(ctx, "events", "audit").Err(); err != nil {
+		t.Fatalf("destroy group: %v", err)
+	}
+	n, err := m.Trim(ctx)
+	if err != nil || n == 0 {
+		t.Fatalf("Trim removed %d entries, err %v", n, err)
+	}
+	rest, err := testRdb.XRange(ctx, "events", kept, "+").Result()
+	if err != nil || len(rest) != 200 || rest[0].ID != kept {
+		t.Fatalf("entries from the pending one on must stay: %d left, err %v", len(rest), err)
+	}
+}
@@ -0,0 +1,169 @@
+package redisqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeStreams answers XINFO GROUPS, XPENDING, XLEN and XTRIM from fixed
+// stream state through a client hook, without a server. Streams missing
+// from groups do not exist.
+type fakeStreams struct {
+	groups  map[string][]redis.XInfoGroup
+	lower   map[string]string // "<stream>/<group>" -> oldest pending id
+	length  map[string]int64
+	trimmed map[string]string // stream -> MINID it was trimmed at
+}
+
+func newFakeStreams() (*redis.Client, *fakeStreams) {
+	f := &fakeStreams{
+		groups:  map[string][]redis.XInfoGroup{},
+		lower:   map[string]string{},
+		length:  map[string]int64{},
+		trimmed: map[string]string{},
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	rdb.AddHook(f)
+	return rdb, f
+}
+
+func (f *fakeStreams) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (f *fakeStreams) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (f *fakeStreams) ProcessHook(redis.ProcessHook) redis.ProcessHook {
+	return func(_ context.Context, cmd redis.Cmder) error {
+		args := cmd.Args()
+		// XINFO GROUPS names the stream after the subcommand.
+		stream := fmt.Sprint(args[1])
+		if cmd.Name() == "xinfo" {
+			stream = fmt.Sprint(args[2])
+		}
+		groups, exists := f.groups[stream]
+
+		switch c := cmd.(type) {
+		case *redis.XInfoGroupsCmd:
+			if !exists {
+				c.SetErr(errors.New("ERR no such key"))
+				break
+			}
+			c.SetVal(groups)
+		case *redis.XPendingCmd:
+			c.SetVal(&redis.XPending{Lower: f.lower[stream+"/"+fmt.Sprint(args[2])]})
+		case *redis.IntCmd:
+			if cmd.Name() == "xtrim" {
+				f.trimmed[stream] = fmt.Sprint(args[4])
+			}
+			c.SetVal(f.length[stream])
+		}
+		return cmd.Err()
+	}
+}
+
+func TestMonitor_TrimKeepsUnacknowledgedEntries(t *testing.T) {
+	cases := []struct {
+		name   string
+		groups []redis.XInfoGroup
+		lower  map[string]string
+		want   string // "" when the stream must not be trimmed
+	}{
+		{
+			name:   "all acknowledged",
+			groups: []redis.XInfoGroup{{Name: "workers", LastDeliveredID: "1700000000005-0"}},
+			want:   "1700000000005-0",
+		},
+		{
+			name:   "pending entry older than the last delivered one",
+			groups: []redis.XInfoGroup{{Name: "workers", LastDeliveredID: "1700000000005-0", Pending: 1}},
+			lower:  map[string]string{"workers": "1700000000002-0"},
+			want:   "1700000000002-0",
+		},
+		{
+			name: "group that has never read",
+			groups: []redis.XInfoGroup{
+				{Name: "workers", LastDeliveredID: "1700000000005-0"},
+				{Name: "audit", LastDeliveredID: "0-0"},
+			},
+			want: "0-0",
+		},
+		{
+			name: "sequence parts of different width",
+			groups: []redis.XInfoGroup{
+				{Name: "workers", LastDeliveredID: "1700000000001-2"},
+				{Name: "audit", LastDeliveredID: "1700000000000-10"},
+			},
+			want: "1700000000000-10",
+		},
+		{
+			name: "no groups",
+		},
+	}
+
+	for _, tc := range cases {
+		rdb, fake := newFakeStreams()
+		fake.groups["events:0"] = tc.groups
+		for g, id := range tc.lower {
+			fake.lower["events:0/"+g] = id
+		}
+
+		m := NewMonitor(rdb, "events", "workers", 2, nopLogger{})
+		if _, err := m.Trim(context.Background()); err != nil {
+			t.Fatalf("%s: Trim: %v", tc.name, err)
+		}
+		if got := fake.trimmed["events:0"]; got != tc.want {
+			t.Errorf("%s: trimmed at %q, want %q", tc.name, got, tc.want)
+		}
+		if got, ok := fake.trimmed["events:1"]; ok {
+			t.Errorf("%s: missing stream trimmed at %q", tc.name, got)
+		}
+		_ = rdb.Close()
+	}
+}
+
+func TestMonitor_ReportsBacklog(t *testing.T) {
+	rdb, fake := newFakeStreams()
+	defer rdb.Close()
+
+	oldest := strconv.FormatInt(time.Now().Add(-time.Minute).UnixMilli(), 10) + "-0"
+	for p := range 2 {
+		stream := StreamName("events", p, 2)
+		fake.length[stream] = 10
+		fake.groups[stream] = []redis.XInfoGroup{
+			{Name: "workers", Lag: 3, Pending: 2},
+			{Name: "audit", Lag: 100, Pending: 100},
+		}
+		fake.lower[stream+"/workers"] = oldest
+	}
+	ctx := context.Background()
+
+	st, err := NewMonitor(rdb, "events", "workers", 2, nopLogger{}, WithMaxBacklog(10)).Report(ctx)
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	s := st.(Stats)
+	if s.Length != 20 || s.Lag != 6 || s.Pending != 4 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if s.OldestPendingSeconds < 59 || s.OldestPendingSeconds > 120 {
+		t.Fatalf("oldest pending %.0fs, want about a minute", s.OldestPendingSeconds)
+	}
+
+	if _, err := NewMonitor(rdb, "events", "workers", 2, nopLogger{}, WithMaxBacklog(9)).Report(ctx); !errors.Is(err, ErrBacklog) {
+		t.Fatalf("Report over the limit: got %v, want ErrBacklog", err)
+	}
+
+	// Without a lag from Redis the backlog is unknown, not over the limit.
+	fake.groups["events:1"][0].Lag = -1
+	st, err = NewMonitor(rdb, "events", "workers", 2, nopLogger{}, WithMaxBacklog(1)).Report(ctx)
+	if err != nil || st.(Stats).Lag != -1 {
+		t.Fatalf("Report with unknown lag: %+v, %v", st, err)
+	}
+}
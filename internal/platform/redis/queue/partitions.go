@@ -0,0 +1,209 @@
+package redisqueue
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
+)
+
+// partition is a partition stream leased by this consumer.
+type partition struct {
+	stream string
+	// ready is set once entries left pending by the previous owner were
+	// taken over; until then no new entries are read, so they cannot
+	// overtake older ones with the same key.
+	ready bool
+	// releasing is set when the consumer owns more than its share; no new
+	// entries are read and the lease is released once nothing is left in
+	// flight.
+	releasing bool
+}
+
+var (
+	renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+func (c *Consumer) membersKey() string { return c.stream + ":consumers" }
+
+func (c *Consumer) leaseKey(p int) string { return fmt.Sprintf("%s:lease:%d", c.stream, p) }
+
+// inPartition matches messages read from partition p.
+func inPartition(p int) func(queue.Message) bool {
+	prefix := strconv.Itoa(p) + "/"
+	return func(m queue.Message) bool { return strings.HasPrefix(m.ID, prefix) }
+}
+
+// rebalance sends a heartbeat, renews the consumer's leases and moves its
+// share of partitions towards partitions divided by the number of live
+// consumers.
+func (c *Consumer) rebalance(ctx context.Context) error {
+	now := time.Now()
+
+	pipe := c.rdb.TxPipeline()
+	pipe.ZAdd(ctx, c.membersKey(), redis.Z{Score: float64(now.UnixMilli()), Member: c.name})
+	pipe.ZRemRangeByScore(ctx, c.membersKey(), "-inf", strconv.FormatInt(now.Add(-c.lease).UnixMilli(), 10))
+	live := pipe.ZCard(ctx, c.membersKey())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("heartbeat: %w", err)
+	}
+	share := (c.partitions + int(max(live.Val(), 1)) - 1) / int(max(live.Val(), 1))
+
+	for _, p := range c.ownedPartitions() {
+		ok, err := renewScript.Run(ctx, c.rdb, []string{c.leaseKey(p)}, c.name, c.lease.Milliseconds()).Int()
+		if err != nil {
+			return fmt.Errorf("renew lease: %w", err)
+		}
+		if ok == 0 {
+			// Another consumer took over after the lease expired; whatever
+			// is still in flight here may be delivered twice.
+			c.log.Error(ctx, "queue partition lease lost", "partition", p)
+			c.seq.Forget(inPartition(p))
+			delete(c.owned, p)
+		}
+	}
+
+	owned := c.ownedPartitions()
+	for i, p := range owned {
+		c.owned[p].releasing = len(owned)-i > share
+	}
+	for _, p := range owned {
+		if !c.owned[p].releasing || c.seq.Count(inPartition(p)) > 0 {
+			continue
+		}
+		if err := releaseScript.Run(ctx, c.rdb, []string{c.leaseKey(p)}, c.name).Err(); err != nil {
+			return fmt.Errorf("release lease: %w", err)
+		}
+		delete(c.owned, p)
+		c.log.Info(ctx, "queue partition released", "partition", p)
+	}
+
+	for p := 0; p < c.partitions && len(c.owned) < share; p++ {
+		if c.owned[p] != nil {
+			continue
+		}
+		ok, err := c.rdb.SetNX(ctx, c.leaseKey(p), c.name, c.lease).Result()
+		if err != nil {
+			return fmt.Errorf("acquire lease: %w", err)
+		}
+		if ok {
+			c.owned[p] = &partition{stream: StreamName(c.stream, p, c.partitions)}
+			c.log.Info(ctx, "queue partition acquired", "partition", p)
+		}
+	}
+
+	for _, p := range c.ownedPartitions() {
+		if c.owned[p].ready {
+			continue
+		}
+		ready, err := c.takeOver(ctx, p)
+		if err != nil {
+			return fmt.Errorf("take over partition %d: %w", p, err)
+		}
+		c.owned[p].ready = ready
+	}
+	return nil
+}
+
+// ownedPartitions returns the leased partitions in ascending order.
+func (c *Consumer) ownedPartitions() []int {
+	out := make([]int, 0, len(c.owned))
+	for p := range c.owned {
+		out = append(out, p)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// takeOver claims the entries of partition p left pending by other
+// consumers, oldest first, and buffers them ahead of new entries. It reports
+// false while an entry was delivered to another consumer less than the
+// visibility timeout ago: that consumer may still be processing it.
+func (c *Consumer) takeOver(ctx context.Context, p int) (bool, error) {
+	stream := c.owned[p].stream
+
+	var (
+		ids        []string
+		deliveries = make(map[string]int)
+		start      = "-"
+	)
+	for {
+		pending, err := c.rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: stream,
+			Group:  c.group,
+			Start:  start,
+			End:    "+",
+			Count:  1000,
+		}).Result()
+		if err != nil {
+			return false, err
+		}
+
+		for _, pe := range pending {
+			if pe.Consumer != c.name && pe.Idle < c.redelivery.Visibility {
+				return false, nil
+			}
+			if pe.Consumer == c.name && c.seq.Count(func(m queue.Message) bool { return m.ID == messageID(p, pe.ID) }) > 0 {
+				continue
+			}
+			if pe.RetryCount >= int64(c.redelivery.MaxDeliveries) {
+				c.log.Error(ctx, "queue message dropped after max deliveries", "message_id", pe.ID, "deliveries", pe.RetryCount)
+				_ = c.rdb.XAck(ctx, stream, c.group, pe.ID).Err()
+				continue
+			}
+			ids = append(ids, pe.ID)
+			deliveries[pe.ID] = int(pe.RetryCount)
+		}
+		if len(pending) < 1000 {
+			break
+		}
+		start = "(" + pending[len(pending)-1].ID
+	}
+	if len(ids) == 0 {
+		return true, nil
+	}
+
+	claimed, err := c.rdb.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    c.group,
+		Consumer: c.name,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return false, err
+	}
+
+	msgs := c.decode(ctx, p, claimed)
+	for i := range msgs {
+		msgs[i].Deliveries = deliveries[streamID(msgs[i].ID)]
+	}
+	c.seq.Add(msgs...)
+	c.log.Info(ctx, "queue partition pending entries taken over", "partition", p, "count", len(msgs))
+	return true, nil
+}
+
+// releaseAll gives up every lease and the heartbeat so other consumers can
+// take the partitions over without waiting for the leases to expire.
+func (c *Consumer) releaseAll(ctx context.Context) {
+	for p := range c.owned {
+		_ = releaseScript.Run(ctx, c.rdb, []string{c.leaseKey(p)}, c.name).Err()
+	}
+	clear(c.owned)
+	_ = c.rdb.ZRem(ctx, c.membersKey(), c.name).Err()
+}
@@ -1,42 +1,70 @@
-package queue
+// Package redisqueue carries queue items over Redis streams read by a
+// consumer group. Items are spread over partition streams by ordering key;
+// each partition is read by a single consumer at a time, which keeps items
+// with the same key in order while different keys are processed in
+// parallel.
+package redisqueue
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
 )
 
-type RedisQueue struct {
-	rdb    *redis.Client
-	stream string
+type Publisher struct {
+	rdb        *redis.Client
+	stream     string
+	partitions int
 }
 
-func New(rdb *redis.Client, stream string) *RedisQueue {
-	return &RedisQueue{rdb: rdb, stream: stream}
-}
+var _ queue.Publisher = (*Publisher)(nil)
 
-type Item struct {
-	EventType string
-	Payload   string
-	OutboxID  int64
+// NewPublisher returns a publisher to stream split into partitions streams.
+// With one partition stream itself is used.
+func NewPublisher(rdb *redis.Client, stream string, partitions int) *Publisher {
+	return &Publisher{rdb: rdb, stream: stream, partitions: max(partitions, 1)}
 }
 
-func (q *RedisQueue) EnqueueBatch(ctx context.Context, items []Item) error {
+func (p *Publisher) Publish(ctx context.Context, items []queue.Item) error {
 	if len(items) == 0 {
 		return nil
 	}
 
-	pipe := q.rdb.Pipeline()
+	// A transaction, so that a failed publish appends none of the items: a
+	// partial one could append an item without the earlier one with the
+	// same key, and the relay's retry would duplicate those that landed.
+	pipe := p.rdb.TxPipeline()
 	for _, it := range items {
 		pipe.XAdd(ctx, &redis.XAddArgs{
-			Stream: q.stream,
+			Stream: StreamName(p.stream, partitionOf(it, p.partitions), p.partitions),
 			Values: map[string]any{
 				"type":      it.EventType,
 				"body":      it.Payload,
 				"outbox_id": it.OutboxID,
+				"key":       it.Key,
+				"trace":     it.TraceContext,
 			},
 		})
 	}
 	_, err := pipe.Exec(ctx)
 	return err
 }
+
+// StreamName returns the stream holding partition p of n.
+func StreamName(stream string, p, n int) string {
+	if n <= 1 {
+		return stream
+	}
+	return fmt.Sprintf("%s:%d", stream, p)
+}
+
+// partitionOf routes keyed items by key and spreads the rest by outbox id.
+func partitionOf(it queue.Item, n int) int {
+	if it.Key != "" {
+		return queue.Partition(it.Key, n)
+	}
+	return int(uint64(it.OutboxID) % uint64(n))
+}
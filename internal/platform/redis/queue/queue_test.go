@@ -0,0 +1,47 @@
+package redisqueue
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
+)
+
+func TestStreamName(t *testing.T) {
+	if got := StreamName("webhook_events", 0, 1); got != "webhook_events" {
+		t.Fatalf("one partition should keep the unpartitioned name, got %q", got)
+	}
+	if got := StreamName("webhook_events", 3, 8); got != "webhook_events:3" {
+		t.Fatalf("StreamName(3, 8) = %q", got)
+	}
+}
+
+func TestPartitionOf(t *testing.T) {
+	const n = 8
+
+	used := make(map[int]bool)
+	for i := range 100 {
+		key := fmt.Sprintf("user:%d", i)
+		p := partitionOf(queue.Item{Key: key, OutboxID: int64(i)}, n)
+		if p < 0 || p >= n {
+			t.Fatalf("partition %d out of range", p)
+		}
+		// The key alone decides: later events with the key follow it.
+		if again := partitionOf(queue.Item{Key: key, OutboxID: int64(i + 1000)}, n); again != p {
+			t.Fatalf("key %q routed to %d and %d", key, p, again)
+		}
+		used[p] = true
+	}
+	if len(used) < n/2 {
+		t.Fatalf("100 keys landed in only %d of %d partitions", len(used), n)
+	}
+
+	for id := range int64(2 * n) {
+		if got := partitionOf(queue.Item{OutboxID: id}, n); got != int(id%n) {
+			t.Fatalf("unkeyed outbox id %d routed to %d, want %d", id, got, id%n)
+		}
+	}
+	if got := partitionOf(queue.Item{Key: "user:1", OutboxID: 7}, 1); got != 0 {
+		t.Fatalf("one partition routed to %d", got)
+	}
+}
@@ -0,0 +1,88 @@
+// Package secretbox encrypts secrets stored in the database, such as the
+// TLS client keys of webhook subscriptions, with AES-256-GCM.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks sealed values and the format version; values without it were
+// stored before encryption was enabled.
+const prefix = "sb1:"
+
+// ErrNoKey is returned when opening a sealed value without a key.
+var ErrNoKey = errors.New("secret is encrypted but no key is configured")
+
+// Box seals and opens secrets with one key. A nil Box stores secrets as
+// they are, for deployments that have not configured a key yet.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New returns a Box for a base64-encoded 32-byte key. An empty key returns
+// a nil Box.
+func New(key string) (*Box, error) {
+	if key == "" {
+		return nil, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts s. Empty values are kept empty.
+func (b *Box) Seal(s string) (string, error) {
+	if b == nil || s == "" {
+		return s, nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(s), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value returned by Seal. Values stored before encryption
+// was enabled are returned as they are.
+func (b *Box) Open(s string) (string, error) {
+	enc, ok := strings.CutPrefix(s, prefix)
+	if !ok {
+		return s, nil
+	}
+	if b == nil {
+		return "", ErrNoKey
+	}
+	raw, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", fmt.Errorf("decode secret: %w", err)
+	}
+	n := b.aead.NonceSize()
+	if len(raw) < n {
+		return "", errors.New("secret is too short")
+	}
+	plain, err := b.aead.Open(nil, raw[:n], raw[n:], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret: %w", err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,295 @@
+// Package spatial holds the active incidents in memory in an R-tree, so
+// location checks are matched without a round trip to PostGIS. The index is
+// reloaded whenever the incident set changes and, optionally, checks a share
+// of its answers against PostGIS.
+package spatial
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+)
+
+// ErrNotLoaded is reported by Report until the first load succeeds; checks
+// go to the repository meanwhile.
+var ErrNotLoaded = errors.New("spatial index not loaded")
+
+// ErrStale is reported by Report once the last successful load is older
+// than the maximum age; checks go to the repository until a reload
+// succeeds.
+var ErrStale = errors.New("spatial index stale")
+
+type Logger interface {
+	Info(ctx context.Context, msg string, args ...any)
+	Error(ctx context.Context, msg string, args ...any)
+}
+
+// Repository is the source of truth the index is loaded from and checked
+// against.
+type Repository interface {
+	ListActive(ctx context.Context) ([]incidents.Incident, error)
+	FindNearby(ctx context.Context, p incidents.Point, limit int) ([]incidents.NearbyIncident, error)
+}
+
+// Wakeup signals that the incident set may have changed.
+type Wakeup interface {
+	C() <-chan struct{}
+	// Connected reports whether signals are being delivered; while it is
+	// false the index reloads at its regular interval.
+	Connected() bool
+}
+
+// Metrics records the index size and the outcome of consistency checks.
+type Metrics interface {
+	SetSpatialIndexSize(n int)
+	ObserveSpatialIndexVerify(result string)
+}
+
+type Option func(*Index)
+
+// WithPollInterval sets how often the index reloads without a wakeup.
+func WithPollInterval(d time.Duration) Option {
+	return func(x *Index) {
+		if d > 0 {
+			x.pollInterval = d
+		}
+	}
+}
+
+// WithWakeup reloads the index as soon as w signals, and only every
+// safetyInterval otherwise while w is connected.
+func WithWakeup(w Wakeup, safetyInterval time.Duration) Option {
+	return func(x *Index) {
+		x.wakeup = w
+		if safetyInterval > 0 {
+			x.safetyInterval = safetyInterval
+		}
+	}
+}
+
+// WithMaxAge sets how old the last successful load may get before the
+// index stops answering. It defaults to three safety intervals.
+func WithMaxAge(d time.Duration) Option {
+	return func(x *Index) {
+		if d > 0 {
+			x.maxAge = d
+		}
+	}
+}
+
+// WithVerify repeats the given share of checks (0 to 1) against PostGIS in
+// the background and logs every disagreement.
+func WithVerify(rate float64) Option {
+	return func(x *Index) { x.verifyRate = min(max(rate, 0), 1) }
+}
+
+func WithMetrics(m Metrics) Option {
+	return func(x *Index) { x.metrics = m }
+}
+
+type Index struct {
+	repo    Repository
+	log     Logger
+	wakeup  Wakeup
+	metrics Metrics
+
+	pollInterval   time.Duration
+	safetyInterval time.Duration
+	maxAge         time.Duration
+	verifyRate     float64
+	verifyTimeout  time.Duration
+	verify         chan verifyJob
+
+	snap atomic.Pointer[snapshot]
+}
+
+type snapshot struct {
+	tree     *rtree
+	size     int
+	loadedAt time.Time
+}
+
+// verifyJob is a check answered by the index, to be repeated in PostGIS.
+type verifyJob struct {
+	p      incidents.Point
+	limit  int
+	got    []int64
+	loaded time.Time
+}
+
+func New(repo Repository, log Logger, opts ...Option) *Index {
+	x := &Index{
+		repo:           repo,
+		log:            log,
+		pollInterval:   5 * time.Second,
+		safetyInterval: time.Minute,
+		verifyTimeout:  5 * time.Second,
+		verify:         make(chan verifyJob, 256),
+	}
+	for _, opt := range opts {
+		opt(x)
+	}
+	if x.maxAge == 0 {
+		x.maxAge = 3 * max(x.safetyInterval, x.pollInterval)
+	}
+	return x
+}
+
+// FindNearby matches p against the index, or the repository while the index
+// has not loaded or its last load is older than the maximum age. Results can
+// trail a committed change by the time it takes the notification to arrive
+// and the index to reload.
+func (x *Index) FindNearby(ctx context.Context, p incidents.Point, limit int) ([]incidents.NearbyIncident, error) {
+	snap := x.snap.Load()
+	if snap == nil || x.stale(snap) {
+		return x.repo.FindNearby(ctx, p, limit)
+	}
+
+	out := incidents.Nearby(snap.tree.search(p), p, limit)
+	if x.verifyRate > 0 && rand.Float64() < x.verifyRate {
+		job := verifyJob{p: p, limit: limit, got: nearbyIDs(out), loaded: snap.loadedAt}
+		select {
+		case x.verify <- job:
+		default:
+			// Verification is falling behind; skip this one.
+		}
+	}
+	return out, nil
+}
+
+// Load replaces the index with the active incidents in the repository.
+func (x *Index) Load(ctx context.Context) error {
+	items, err := x.repo.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	x.snap.Store(&snapshot{tree: newRTree(items), size: len(items), loadedAt: time.Now().UTC()})
+	if x.metrics != nil {
+		x.metrics.SetSpatialIndexSize(len(items))
+	}
+	return nil
+}
+
+// Run loads the index and keeps reloading it until ctx is canceled.
+func (x *Index) Run(ctx context.Context) error {
+	var wake <-chan struct{}
+	if x.wakeup != nil {
+		wake = x.wakeup.C()
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	if x.verifyRate > 0 {
+		wg.Go(func() { x.runVerify(ctx) })
+	}
+
+	t := time.NewTimer(0)
+	defer t.Stop()
+
+	x.log.Info(ctx, "spatial index started")
+	for {
+		select {
+		case <-ctx.Done():
+			x.log.Info(ctx, "spatial index stopped")
+			return ctx.Err()
+		case <-wake:
+		case <-t.C:
+		}
+
+		if err := x.Load(ctx); err != nil && ctx.Err() == nil {
+			x.log.Error(ctx, "spatial index load failed", "error", err)
+		}
+		t.Reset(x.interval())
+	}
+}
+
+// stale reports whether snap was loaded longer than the maximum age ago,
+// e.g. because every reload since has failed.
+func (x *Index) stale(snap *snapshot) bool {
+	return time.Since(snap.loadedAt) > x.maxAge
+}
+
+// interval is the time until the next reload when no wakeup arrives.
+func (x *Index) interval() time.Duration {
+	if x.wakeup != nil && x.wakeup.Connected() {
+		return x.safetyInterval
+	}
+	return x.pollInterval
+}
+
+type indexStatus struct {
+	Incidents int        `json:"incidents"`
+	LoadedAt  *time.Time `json:"loaded_at,omitempty"`
+	Listening bool       `json:"listening"`
+}
+
+// Report returns the index size and when it was last loaded, failing with
+// ErrStale once that is longer than the maximum age ago.
+func (x *Index) Report(context.Context) (any, error) {
+	st := indexStatus{Listening: x.wakeup != nil && x.wakeup.Connected()}
+	snap := x.snap.Load()
+	if snap == nil {
+		return st, ErrNotLoaded
+	}
+	st.Incidents, st.LoadedAt = snap.size, &snap.loadedAt
+	if x.stale(snap) {
+		return st, ErrStale
+	}
+	return st, nil
+}
+
+func (x *Index) runVerify(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-x.verify:
+			x.check(ctx, job)
+		}
+	}
+}
+
+// check repeats job in PostGIS. A disagreement right after an incident
+// changed may only mean the index had not reloaded yet; the log says which
+// load answered the check.
+func (x *Index) check(ctx context.Context, job verifyJob) {
+	ctx, cancel := context.WithTimeout(ctx, x.verifyTimeout)
+	defer cancel()
+
+	want, err := x.repo.FindNearby(ctx, job.p, job.limit)
+	if err != nil {
+		if ctx.Err() == nil {
+			x.log.Error(ctx, "spatial index verify failed", "error", err)
+		}
+		return
+	}
+
+	result := "match"
+	if wantIDs := nearbyIDs(want); !slices.Equal(job.got, wantIDs) {
+		result = "mismatch"
+		x.log.Error(ctx, "spatial index disagrees with postgis",
+			"lat", job.p.Lat, "lon", job.p.Lon, "limit", job.limit,
+			"index", job.got, "postgis", wantIDs, "loaded_at", job.loaded)
+	}
+	if x.metrics != nil {
+		x.metrics.ObserveSpatialIndexVerify(result)
+	}
+}
+
+// nearbyIDs returns the ids of items, sorted, so that incidents at the same
+// distance compare equal in any order.
+func nearbyIDs(items []incidents.NearbyIncident) []int64 {
+	ids := make([]int64, 0, len(items))
+	for _, it := range items {
+		ids = append(ids, it.IncidentID)
+	}
+	slices.Sort(ids)
+	return ids
+}
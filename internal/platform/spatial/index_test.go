@@ -0,0 +1,132 @@
+package spatial
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(context.Context, string, ...any)  {}
+func (nopLogger) Error(context.Context, string, ...any) {}
+
+type fakeRepo struct {
+	items  []incidents.Incident
+	nearby int
+}
+
+func (r *fakeRepo) ListActive(context.Context) ([]incidents.Incident, error) { return r.items, nil }
+
+func (r *fakeRepo) FindNearby(_ context.Context, p incidents.Point, limit int) ([]incidents.NearbyIncident, error) {
+	r.nearby++
+	return incidents.Nearby(r.items, p, limit), nil
+}
+
+type verifyMetrics struct{ results []string }
+
+func (m *verifyMetrics) SetSpatialIndexSize(int) {}
+func (m *verifyMetrics) ObserveSpatialIndexVerify(result string) {
+	m.results = append(m.results, result)
+}
+
+func randomIncidents(rnd *rand.Rand, n int) []incidents.Incident {
+	items := make([]incidents.Incident, 0, n)
+	for i := range n {
+		items = append(items, incidents.Incident{
+			ID:     int64(i + 1),
+			Center: incidents.Point{Lat: 55 + rnd.Float64(), Lon: 37 + rnd.Float64()},
+			Radius: 100 + rnd.IntN(5000),
+			Active: true,
+		})
+	}
+	// One circle across the antimeridian.
+	return append(items, incidents.Incident{ID: int64(n + 1), Center: incidents.Point{Lat: 10, Lon: 179.99}, Radius: 5000, Active: true})
+}
+
+func TestRTree_MatchesBruteForce(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	items := randomIncidents(rnd, 2000)
+	tree := newRTree(slices.Clone(items))
+
+	points := []incidents.Point{{Lat: 10, Lon: -179.99}, {Lat: 10, Lon: 179.98}}
+	for range 500 {
+		points = append(points, incidents.Point{Lat: 55 + rnd.Float64(), Lon: 37 + rnd.Float64()})
+	}
+	for _, p := range points {
+		got := incidents.Nearby(tree.search(p), p, 500)
+		want := incidents.Nearby(items, p, 500)
+		if !slices.Equal(nearbyIDs(got), nearbyIDs(want)) {
+			t.Fatalf("at %v: index %v, brute force %v", p, nearbyIDs(got), nearbyIDs(want))
+		}
+	}
+}
+
+func TestIndex_FallsBackUntilLoaded(t *testing.T) {
+	repo := &fakeRepo{items: randomIncidents(rand.New(rand.NewPCG(3, 4)), 100)}
+	m := &verifyMetrics{}
+	x := New(repo, nopLogger{}, WithVerify(1), WithMetrics(m))
+	ctx := context.Background()
+	p := repo.items[0].Center
+
+	if _, err := x.FindNearby(ctx, p, 10); err != nil || repo.nearby != 1 {
+		t.Fatalf("expected the repository to answer before the first load, err %v", err)
+	}
+	if _, err := x.Report(ctx); err == nil {
+		t.Fatal("expected Report to fail before the first load")
+	}
+
+	if err := x.Load(ctx); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	got, err := x.FindNearby(ctx, p, 10)
+	if err != nil || repo.nearby != 1 || len(got) == 0 {
+		t.Fatalf("expected the index to answer after loading: %v, %v", got, err)
+	}
+
+	// The check was queued for verification; a change the index has not
+	// seen yet shows up as a mismatch.
+	repo.items = nil
+	x.check(ctx, <-x.verify)
+	if !slices.Equal(m.results, []string{"mismatch"}) {
+		t.Fatalf("expected a mismatch, got %v", m.results)
+	}
+}
+
+func TestIndex_FallsBackWhenStale(t *testing.T) {
+	repo := &fakeRepo{items: randomIncidents(rand.New(rand.NewPCG(5, 6)), 100)}
+	x := New(repo, nopLogger{}, WithMaxAge(time.Minute))
+	ctx := context.Background()
+	p := repo.items[0].Center
+
+	if err := x.Load(ctx); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, err := x.Report(ctx); err != nil {
+		t.Fatalf("Report after loading: %v", err)
+	}
+
+	// Every reload since has failed.
+	snap := *x.snap.Load()
+	snap.loadedAt = snap.loadedAt.Add(-2 * time.Minute)
+	x.snap.Store(&snap)
+
+	if _, err := x.FindNearby(ctx, p, 10); err != nil || repo.nearby != 1 {
+		t.Fatalf("expected the repository to answer from a stale index, err %v", err)
+	}
+	if _, err := x.Report(ctx); !errors.Is(err, ErrStale) {
+		t.Fatalf("Report: got %v, want ErrStale", err)
+	}
+
+	if err := x.Load(ctx); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, err := x.FindNearby(ctx, p, 10); err != nil || repo.nearby != 1 {
+		t.Fatalf("expected the index to answer after reloading, err %v", err)
+	}
+}
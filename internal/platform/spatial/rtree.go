@@ -0,0 +1,121 @@
+package spatial
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+)
+
+// nodeCapacity is the fan-out of the tree: entries per leaf and children per
+// inner node.
+const nodeCapacity = 16
+
+// rtree is an R-tree packed once with Sort-Tile-Recursive and never modified:
+// a change to the incident set builds a new tree, so readers need no lock.
+type rtree struct {
+	root *node
+}
+
+type entry struct {
+	box incidents.Box
+	inc incidents.Incident
+}
+
+type node struct {
+	box      incidents.Box
+	children []*node // inner nodes
+	entries  []entry // leaves
+}
+
+// newRTree indexes the incidents by the bounds of their circles. A circle
+// crossing the antimeridian is indexed under both halves of its box; a
+// point falls into at most one of them.
+func newRTree(items []incidents.Incident) *rtree {
+	entries := make([]entry, 0, len(items))
+	for _, inc := range items {
+		for _, b := range inc.Bounds().Split() {
+			entries = append(entries, entry{box: b, inc: inc})
+		}
+	}
+	if len(entries) == 0 {
+		return &rtree{}
+	}
+
+	level := make([]*node, 0)
+	for _, group := range pack(entries, func(e entry) incidents.Box { return e.box }) {
+		n := &node{entries: group, box: group[0].box}
+		for _, e := range group[1:] {
+			n.box = union(n.box, e.box)
+		}
+		level = append(level, n)
+	}
+	for len(level) > 1 {
+		next := make([]*node, 0, len(level)/nodeCapacity+1)
+		for _, group := range pack(level, func(n *node) incidents.Box { return n.box }) {
+			n := &node{children: group, box: group[0].box}
+			for _, c := range group[1:] {
+				n.box = union(n.box, c.box)
+			}
+			next = append(next, n)
+		}
+		level = next
+	}
+	return &rtree{root: level[0]}
+}
+
+// search returns the incidents whose box contains p.
+func (t *rtree) search(p incidents.Point) []incidents.Incident {
+	var out []incidents.Incident
+	if t.root == nil {
+		return out
+	}
+
+	stack := []*node{t.root}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !n.box.Contains(p) {
+			continue
+		}
+		for _, e := range n.entries {
+			if e.box.Contains(p) {
+				out = append(out, e.inc)
+			}
+		}
+		stack = append(stack, n.children...)
+	}
+	return out
+}
+
+// pack groups items into nodes of nodeCapacity: items are sorted by
+// longitude into vertical slices, and each slice by latitude into nodes,
+// so that nodes overlap little.
+func pack[T any](items []T, box func(T) incidents.Box) [][]T {
+	centerLon := func(t T) float64 { b := box(t); return (b.MinLon + b.MaxLon) / 2 }
+	centerLat := func(t T) float64 { b := box(t); return (b.MinLat + b.MaxLat) / 2 }
+
+	nodes := (len(items) + nodeCapacity - 1) / nodeCapacity
+	perSlice := int(math.Ceil(math.Sqrt(float64(nodes)))) * nodeCapacity
+
+	slices.SortFunc(items, func(a, b T) int { return cmp.Compare(centerLon(a), centerLon(b)) })
+
+	groups := make([][]T, 0, nodes)
+	for s := range slices.Chunk(items, perSlice) {
+		slices.SortFunc(s, func(a, b T) int { return cmp.Compare(centerLat(a), centerLat(b)) })
+		for g := range slices.Chunk(s, nodeCapacity) {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+func union(a, b incidents.Box) incidents.Box {
+	return incidents.Box{
+		MinLat: min(a.MinLat, b.MinLat),
+		MinLon: min(a.MinLon, b.MinLon),
+		MaxLat: max(a.MaxLat, b.MaxLat),
+		MaxLon: max(a.MaxLon, b.MaxLon),
+	}
+}
@@ -0,0 +1,110 @@
+// Package tracing configures OpenTelemetry tracing and carries trace context
+// across the hops that are not HTTP: the outbox row and the queue message
+// store it as an encoded string so that the webhook delivery joins the trace
+// of the request that caused it.
+package tracing
+
+import (
+	"context"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Config struct {
+	ServiceName string
+	// Endpoint is the OTLP/HTTP collector address (host:port). When empty
+	// the exporter falls back to the standard OTEL_EXPORTER_OTLP_* variables.
+	Endpoint string
+	Insecure bool
+	// SampleRatio is the fraction of new traces recorded; traces started
+	// upstream follow the caller's sampling decision.
+	SampleRatio float64
+}
+
+func init() {
+	// Propagate W3C trace context even with tracing disabled, so that a
+	// caller's traceparent still reaches the outbox and webhook receivers.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
+
+// Setup exports spans over OTLP/HTTP and installs the provider globally.
+// The returned function flushes pending spans and must be called on exit.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exp, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := NewProvider(cfg, sdktrace.WithBatcher(exp))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// NewProvider builds a tracer provider for cfg exporting through processor;
+// tests pass a synchronous in-memory exporter (see tracetest).
+func NewProvider(cfg Config, processor sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		processor,
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+	)
+}
+
+// Inject encodes the trace context of ctx for storage, or returns "" when
+// ctx carries none.
+func Inject(ctx context.Context) string {
+	c := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, c)
+	if len(c) == 0 {
+		return ""
+	}
+	v := url.Values{}
+	for k, val := range c {
+		v.Set(k, val)
+	}
+	return v.Encode()
+}
+
+// Extract returns ctx with the remote trace context encoded by Inject. An
+// empty or malformed value leaves ctx unchanged.
+func Extract(ctx context.Context, encoded string) context.Context {
+	if encoded == "" {
+		return ctx
+	}
+	v, err := url.ParseQuery(encoded)
+	if err != nil {
+		return ctx
+	}
+	c := propagation.MapCarrier{}
+	for k := range v {
+		c[k] = v.Get(k)
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, c)
+}
+
+// Link returns a link to the span encoded by Inject, for spans that handle
+// work from several traces at once.
+func Link(encoded string) (trace.Link, bool) {
+	sc := trace.SpanContextFromContext(Extract(context.Background(), encoded))
+	if !sc.IsValid() {
+		return trace.Link{}, false
+	}
+	return trace.Link{SpanContext: sc}, true
+}
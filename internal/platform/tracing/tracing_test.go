@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectExtract(t *testing.T) {
+	tp := NewProvider(Config{SampleRatio: 1}, sdktrace.WithSyncer(tracetest.NewInMemoryExporter()))
+	ctx, span := tp.Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+
+	encoded := Inject(ctx)
+	if encoded == "" {
+		t.Fatalf("expected an encoded trace context")
+	}
+
+	got := trace.SpanContextFromContext(Extract(context.Background(), encoded))
+	if !got.IsRemote() || got.TraceID() != span.SpanContext().TraceID() || got.SpanID() != span.SpanContext().SpanID() {
+		t.Fatalf("extracted %+v, want span %+v", got, span.SpanContext())
+	}
+
+	if l, ok := Link(encoded); !ok || l.SpanContext.SpanID() != span.SpanContext().SpanID() {
+		t.Fatalf("link does not point at the span")
+	}
+}
+
+func TestInjectWithoutSpan(t *testing.T) {
+	if got := Inject(context.Background()); got != "" {
+		t.Fatalf("expected no trace context, got %q", got)
+	}
+	if _, ok := Link("garbage"); ok {
+		t.Fatalf("expected no link from a malformed value")
+	}
+}
@@ -0,0 +1,182 @@
+// Package urlpolicy decides which outbound URLs and addresses the service may
+// connect to, protecting runtime-configured endpoints such as webhooks
+// against server-side request forgery.
+package urlpolicy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrBlocked is wrapped by every error reporting a policy violation.
+var ErrBlocked = errors.New("blocked by url policy")
+
+// maxRedirects matches the net/http default.
+const maxRedirects = 10
+
+// metadataAddrs are cloud instance metadata endpoints outside the link-local
+// ranges.
+var metadataAddrs = []netip.Addr{
+	netip.MustParseAddr("100.100.100.200"), // Alibaba Cloud
+	netip.MustParseAddr("fd00:ec2::254"),   // AWS IPv6
+}
+
+// privateRanges are internal address spaces not covered by
+// netip.Addr.IsPrivate (RFC 1918 and fc00::/7).
+var privateRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+}
+
+type Policy struct {
+	// Allow, when non-empty, restricts connections to these ranges. Ranges
+	// listed here are allowed even if they are blocked by default or by Deny.
+	Allow []netip.Prefix
+	// Deny blocks these ranges in addition to the defaults.
+	Deny []netip.Prefix
+	// RequireHTTPS rejects plain http URLs and redirects.
+	RequireHTTPS bool
+}
+
+// ParsePrefixes parses a list of CIDRs or single addresses.
+func ParsePrefixes(items []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(items))
+	for _, it := range items {
+		it = strings.TrimSpace(it)
+		if it == "" {
+			continue
+		}
+		if !strings.Contains(it, "/") {
+			addr, err := netip.ParseAddr(it)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q: %w", it, err)
+			}
+			out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(it)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q: %w", it, err)
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+// CheckURL validates what can be known about raw without resolving it: the
+// scheme and, for literal IP hosts, the address.
+func (p Policy) CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%w: invalid url: %v", ErrBlocked, err)
+	}
+	return p.checkURL(u)
+}
+
+func (p Policy) checkURL(u *url.URL) error {
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if p.RequireHTTPS {
+			return fmt.Errorf("%w: https is required", ErrBlocked)
+		}
+	default:
+		return fmt.Errorf("%w: scheme %q is not allowed", ErrBlocked, u.Scheme)
+	}
+
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		if !p.allowed(netip.MustParseAddr("127.0.0.1")) {
+			return fmt.Errorf("%w: host %q is not allowed", ErrBlocked, host)
+		}
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return p.CheckAddr(addr)
+	}
+	return nil
+}
+
+// CheckAddr reports whether connecting to addr is allowed.
+func (p Policy) CheckAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+
+	if p.allowed(addr) {
+		return nil
+	}
+	if len(p.Allow) > 0 {
+		return fmt.Errorf("%w: address %s is not in the allow list", ErrBlocked, addr)
+	}
+	if reason := blockedByDefault(addr); reason != "" {
+		return fmt.Errorf("%w: address %s is %s", ErrBlocked, addr, reason)
+	}
+	for _, d := range p.Deny {
+		if d.Contains(addr) {
+			return fmt.Errorf("%w: address %s is in denied range %s", ErrBlocked, addr, d)
+		}
+	}
+	return nil
+}
+
+func (p Policy) allowed(addr netip.Addr) bool {
+	for _, a := range p.Allow {
+		if a.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func blockedByDefault(addr netip.Addr) string {
+	switch {
+	case addr.IsLoopback():
+		return "loopback"
+	case addr.IsLinkLocalUnicast(), addr.IsLinkLocalMulticast():
+		// Covers 169.254.169.254, the metadata endpoint of most clouds.
+		return "link-local"
+	case addr.IsUnspecified():
+		return "unspecified"
+	case addr.IsMulticast(), addr.IsInterfaceLocalMulticast():
+		return "multicast"
+	case addr.IsPrivate():
+		return "private"
+	}
+	for _, r := range privateRanges {
+		if r.Contains(addr) {
+			return "private"
+		}
+	}
+	for _, m := range metadataAddrs {
+		if addr == m {
+			return "a cloud metadata endpoint"
+		}
+	}
+	return ""
+}
+
+// Control is a net.Dialer Control function that checks the resolved address
+// of every connection, so that DNS names cannot be used to reach blocked
+// addresses.
+func (p Policy) Control(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: invalid address %q", ErrBlocked, address)
+	}
+	return p.CheckAddr(ap.Addr())
+}
+
+// CheckRedirect is an http.Client CheckRedirect function that applies the
+// policy to redirect targets.
+func (p Policy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if err := p.checkURL(req.URL); err != nil {
+		return fmt.Errorf("redirect to %s: %w", req.URL.Redacted(), err)
+	}
+	return nil
+}
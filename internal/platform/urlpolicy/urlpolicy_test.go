@@ -0,0 +1,75 @@
+package urlpolicy
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPolicy_CheckURL(t *testing.T) {
+	allowLoopback, _ := ParsePrefixes([]string{"127.0.0.0/8"})
+	allowPrivate, _ := ParsePrefixes([]string{"10.0.0.0/8"})
+	deny, _ := ParsePrefixes([]string{"10.0.0.0/8"})
+
+	cases := []struct {
+		name    string
+		policy  Policy
+		url     string
+		blocked bool
+	}{
+		{name: "public host", url: "https://example.com/hook"},
+		{name: "loopback", url: "http://127.0.0.1:9090/hook", blocked: true},
+		{name: "localhost", url: "http://localhost/hook", blocked: true},
+		{name: "ipv6 loopback", url: "http://[::1]/hook", blocked: true},
+		{name: "mapped loopback", url: "http://[::ffff:127.0.0.1]/hook", blocked: true},
+		{name: "metadata", url: "http://169.254.169.254/latest/meta-data", blocked: true},
+		{name: "denied range", policy: Policy{Deny: deny}, url: "http://10.1.2.3/hook", blocked: true},
+		{name: "rfc1918", url: "http://10.1.2.3/hook", blocked: true},
+		{name: "rfc1918 172", url: "http://172.16.0.1/hook", blocked: true},
+		{name: "rfc1918 192", url: "http://192.168.1.1/hook", blocked: true},
+		{name: "cgnat", url: "http://100.64.0.1/hook", blocked: true},
+		{name: "this network", url: "http://0.1.2.3/hook", blocked: true},
+		{name: "ipv6 ula", url: "http://[fd12:3456::1]/hook", blocked: true},
+		{name: "mapped private", url: "http://[::ffff:10.1.2.3]/hook", blocked: true},
+		{name: "public next to cgnat", url: "http://100.128.0.1/hook"},
+		{name: "allow opens private range", policy: Policy{Allow: allowPrivate}, url: "http://10.1.2.3/hook"},
+		{name: "allow overrides defaults", policy: Policy{Allow: allowLoopback}, url: "http://localhost/hook"},
+		{name: "outside allow list", policy: Policy{Allow: allowLoopback}, url: "http://93.184.216.34/hook", blocked: true},
+		{name: "http in production", policy: Policy{RequireHTTPS: true}, url: "http://example.com/hook", blocked: true},
+		{name: "other scheme", url: "ftp://example.com/hook", blocked: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.CheckURL(tc.url)
+			if tc.blocked != errors.Is(err, ErrBlocked) {
+				t.Fatalf("blocked=%v, got err %v", tc.blocked, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_DialAndRedirect(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer target.Close()
+
+	var p Policy
+	dialer := &net.Dialer{Timeout: time.Second, Control: p.Control}
+	client := &http.Client{
+		Transport:     &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: p.CheckRedirect,
+	}
+
+	// Nothing checks the URL up front; the loopback address is caught at dial time.
+	if _, err := client.Get("http://" + target.Listener.Addr().String()); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("expected dial to be blocked, got %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://169.254.169.254/", nil)
+	if err := p.CheckRedirect(req, nil); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("expected redirect to be blocked, got %v", err)
+	}
+}
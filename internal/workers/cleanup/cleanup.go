@@ -0,0 +1,282 @@
+// Package cleanup deletes expired outbox events, delivery records and
+// location checks, and maintains daily partitions of partitioned tables.
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	retentiondb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/retention"
+)
+
+type Logger interface {
+	Info(ctx context.Context, msg string, args ...any)
+	Error(ctx context.Context, msg string, args ...any)
+}
+
+type Store interface {
+	DeleteOutbox(ctx context.Context, status string, before time.Time, limit int) (int64, error)
+	DeleteDeliveries(ctx context.Context, before time.Time, limit int) (int64, error)
+	DeleteLocationChecks(ctx context.Context, before time.Time, limit int) (int64, error)
+	DeleteOrphanCheckIncidents(ctx context.Context, limit int) (int64, error)
+
+	Partitioned(ctx context.Context, table string) (bool, error)
+	Partitions(ctx context.Context, table string) ([]retentiondb.Partition, error)
+	CreatePartition(ctx context.Context, table, name string, from, to time.Time) error
+	DropPartition(ctx context.Context, name string) error
+	OutboxPartitionHasPending(ctx context.Context, name string) (bool, error)
+}
+
+const (
+	tableOutbox         = "webhook_outbox"
+	tableLocationChecks = "location_checks"
+)
+
+// Retention is how long rows are kept; zero keeps them forever.
+type Retention struct {
+	OutboxDispatched time.Duration
+	OutboxDead       time.Duration
+	Deliveries       time.Duration
+	LocationChecks   time.Duration
+}
+
+type Option func(*Worker)
+
+func WithInterval(d time.Duration) Option {
+	return func(w *Worker) {
+		if d > 0 {
+			w.interval = d
+		}
+	}
+}
+
+// WithBatch bounds every DELETE to size rows and sleeps pause between
+// batches, so that cleanup never holds locks for long.
+func WithBatch(size int, pause time.Duration) Option {
+	return func(w *Worker) {
+		if size > 0 {
+			w.batchSize = size
+		}
+		if pause >= 0 {
+			w.batchPause = pause
+		}
+	}
+}
+
+// WithPartitionsAhead sets how many future daily partitions are kept ready
+// for partitioned tables.
+func WithPartitionsAhead(days int) Option {
+	return func(w *Worker) {
+		if days >= 0 {
+			w.partitionsAhead = days
+		}
+	}
+}
+
+type Worker struct {
+	store     Store
+	retention Retention
+	log       Logger
+
+	interval        time.Duration
+	batchSize       int
+	batchPause      time.Duration
+	partitionsAhead int
+}
+
+func New(store Store, retention Retention, log Logger, opts ...Option) *Worker {
+	w := &Worker{
+		store:           store,
+		retention:       retention,
+		log:             log,
+		interval:        time.Hour,
+		batchSize:       5000,
+		batchPause:      100 * time.Millisecond,
+		partitionsAhead: 3,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+func (w *Worker) Run(ctx context.Context) error {
+	t := time.NewTimer(0)
+	defer t.Stop()
+
+	w.log.Info(ctx, "cleanup worker started", "interval", w.interval)
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info(ctx, "cleanup worker stopped")
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		w.runOnce(ctx)
+		t.Reset(w.interval)
+	}
+}
+
+func (w *Worker) runOnce(ctx context.Context) {
+	now := time.Now().UTC()
+
+	// Partitions are dropped only once every row in them has expired, so
+	// outbox partitions follow the longest outbox retention.
+	outboxKeep := max(w.retention.OutboxDispatched, w.retention.OutboxDead)
+	if w.retention.OutboxDispatched == 0 || w.retention.OutboxDead == 0 {
+		outboxKeep = 0
+	}
+	w.maintainPartitions(ctx, tableOutbox, now, outboxKeep, w.store.OutboxPartitionHasPending)
+	w.maintainPartitions(ctx, tableLocationChecks, now, w.retention.LocationChecks, nil)
+
+	if d := w.retention.OutboxDispatched; d > 0 {
+		w.deleteExpired(ctx, "webhook_outbox.dispatched", func(ctx context.Context, limit int) (int64, error) {
+			return w.store.DeleteOutbox(ctx, "dispatched", now.Add(-d), limit)
+		})
+	}
+	if d := w.retention.OutboxDead; d > 0 {
+		w.deleteExpired(ctx, "webhook_outbox.dead", func(ctx context.Context, limit int) (int64, error) {
+			return w.store.DeleteOutbox(ctx, "dead", now.Add(-d), limit)
+		})
+	}
+	if d := w.retention.Deliveries; d > 0 {
+		w.deleteExpired(ctx, "webhook_deliveries", func(ctx context.Context, limit int) (int64, error) {
+			return w.store.DeleteDeliveries(ctx, now.Add(-d), limit)
+		})
+	}
+	if d := w.retention.LocationChecks; d > 0 {
+		w.deleteExpired(ctx, "location_checks", func(ctx context.Context, limit int) (int64, error) {
+			return w.store.DeleteLocationChecks(ctx, now.Add(-d), limit)
+		})
+		w.deleteExpired(ctx, "location_check_incidents", w.store.DeleteOrphanCheckIncidents)
+	}
+}
+
+// deleteExpired runs del until a batch comes back short.
+func (w *Worker) deleteExpired(ctx context.Context, name string, del func(ctx context.Context, limit int) (int64, error)) {
+	var total int64
+	defer func() {
+		if total > 0 {
+			w.log.Info(ctx, "cleanup deleted expired rows", "table", name, "count", total)
+		}
+	}()
+
+	for ctx.Err() == nil {
+		n, err := del(ctx, w.batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				w.log.Error(ctx, "cleanup delete failed", "table", name, "error", err)
+			}
+			return
+		}
+		total += n
+		if n < int64(w.batchSize) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(w.batchPause):
+		}
+	}
+}
+
+// maintainPartitions keeps daily partitions of table ready ahead of now and
+// drops partitions older than keep. Tables that are not partitioned are left
+// to batched deletes.
+func (w *Worker) maintainPartitions(ctx context.Context, table string, now time.Time, keep time.Duration, busy func(ctx context.Context, name string) (bool, error)) {
+	if err := w.partitions(ctx, table, now, keep, busy); err != nil && ctx.Err() == nil {
+		w.log.Error(ctx, "cleanup partition maintenance failed", "table", table, "error", err)
+	}
+}
+
+func (w *Worker) partitions(ctx context.Context, table string, now time.Time, keep time.Duration, busy func(ctx context.Context, name string) (bool, error)) error {
+	ok, err := w.store.Partitioned(ctx, table)
+	if err != nil || !ok {
+		return err
+	}
+
+	parts, err := w.store.Partitions(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range missingPartitions(table, parts, now, w.partitionsAhead) {
+		if err := w.store.CreatePartition(ctx, table, p.Name, p.From, p.To); err != nil {
+			return fmt.Errorf("create partition %s: %w", p.Name, err)
+		}
+		w.log.Info(ctx, "cleanup created partition", "table", table, "partition", p.Name)
+	}
+
+	if keep <= 0 {
+		return nil
+	}
+	for _, p := range expiredPartitions(parts, now.Add(-keep)) {
+		if busy != nil {
+			b, err := busy(ctx, p.Name)
+			if err != nil {
+				return err
+			}
+			if b {
+				continue
+			}
+		}
+		if err := w.store.DropPartition(ctx, p.Name); err != nil {
+			return fmt.Errorf("drop partition %s: %w", p.Name, err)
+		}
+		w.log.Info(ctx, "cleanup dropped partition", "table", table, "partition", p.Name)
+	}
+	return nil
+}
+
+// missingPartitions returns the daily partitions from today through ahead
+// days that do not overlap an existing range partition.
+func missingPartitions(table string, existing []retentiondb.Partition, now time.Time, ahead int) []retentiondb.Partition {
+	today := now.UTC().Truncate(24 * time.Hour)
+
+	var out []retentiondb.Partition
+	for i := range ahead + 1 {
+		from := today.AddDate(0, 0, i)
+		to := from.AddDate(0, 0, 1)
+		if overlaps(existing, from, to) {
+			continue
+		}
+		out = append(out, retentiondb.Partition{
+			Name: table + "_p" + from.Format("20060102"),
+			From: from,
+			To:   to,
+		})
+	}
+	return out
+}
+
+func overlaps(parts []retentiondb.Partition, from, to time.Time) bool {
+	for _, p := range parts {
+		if p.Default {
+			continue
+		}
+		startsBefore := p.From.IsZero() || p.From.Before(to)
+		endsAfter := p.To.IsZero() || p.To.After(from)
+		if startsBefore && endsAfter {
+			return true
+		}
+	}
+	return false
+}
+
+// expiredPartitions returns the range partitions that end at or before
+// cutoff. The default partition and unbounded ranges are never expired.
+func expiredPartitions(parts []retentiondb.Partition, cutoff time.Time) []retentiondb.Partition {
+	var out []retentiondb.Partition
+	for _, p := range parts {
+		if p.Default || p.To.IsZero() {
+			continue
+		}
+		if !p.To.After(cutoff) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
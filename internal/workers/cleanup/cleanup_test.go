@@ -0,0 +1,51 @@
+package cleanup
+
+import (
+	"testing"
+	"time"
+
+	retentiondb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/retention"
+)
+
+func TestMissingPartitions(t *testing.T) {
+	now := time.Date(2026, 1, 10, 15, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	existing := []retentiondb.Partition{
+		{Name: "location_checks_legacy", To: day(11)},
+		{Name: "location_checks_p20260112", From: day(12), To: day(13)},
+		{Name: "location_checks_default", Default: true},
+	}
+
+	got := missingPartitions("location_checks", existing, now, 3)
+
+	want := []string{"location_checks_p20260111", "location_checks_p20260113"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d partitions (%+v), want %v", len(got), got, want)
+	}
+	for i, p := range got {
+		if p.Name != want[i] {
+			t.Fatalf("partition %d: got %s, want %s", i, p.Name, want[i])
+		}
+		if !p.To.Equal(p.From.AddDate(0, 0, 1)) {
+			t.Fatalf("partition %s must span one day", p.Name)
+		}
+	}
+}
+
+func TestExpiredPartitions(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	parts := []retentiondb.Partition{
+		{Name: "legacy", To: day(2)},
+		{Name: "p1", From: day(2), To: day(3)},
+		{Name: "p2", From: day(3), To: day(4)},
+		{Name: "open", From: day(4)},
+		{Name: "default", Default: true},
+	}
+
+	got := expiredPartitions(parts, day(3).Add(time.Hour))
+	if len(got) != 2 || got[0].Name != "legacy" || got[1].Name != "p1" {
+		t.Fatalf("got %+v", got)
+	}
+}
@@ -2,87 +2,263 @@ package outboxrelay
 
 import (
 	"context"
+	"fmt"
+	"math/rand/v2"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	outboxdb "github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/outbox"
 	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/db/uow"
-	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/redis/queue"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/tracing"
 )
 
+var tracer = otel.Tracer("github.com/m1ll3r1337/geo-notifications-service/internal/workers/outboxrelay")
+
 type Logger interface {
 	Info(ctx context.Context, msg string, args ...any)
 	Error(ctx context.Context, msg string, args ...any)
 }
 
+// Wakeup signals that new events may have been enqueued.
+type Wakeup interface {
+	C() <-chan struct{}
+	// Connected reports whether signals are being delivered; while it is
+	// false the relay polls at its regular interval.
+	Connected() bool
+}
+
+// Fence confirms, inside the claim transaction, that this instance may
+// still dispatch: a relay that lost leadership claims nothing. A batch
+// claimed before that is still published and marked.
+type Fence interface {
+	Check(ctx context.Context, exec sqlx.ExtContext) error
+}
+
+// Metrics records the batches the relay publishes.
+type Metrics interface {
+	ObserveRelayBatch(size int, result string)
+}
+
+// Heartbeat is told about every loop iteration, so that a liveness probe
+// can tell a stuck relay from an idle one.
+type Heartbeat interface {
+	Beat()
+	Stop()
+}
+
+type Option func(*Relay)
+
+// WithHeartbeat beats h at least every safety interval while Run runs.
+func WithHeartbeat(h Heartbeat) Option {
+	return func(r *Relay) { r.heartbeat = h }
+}
+
+// WithMetrics records the size of every batch and whether it was published.
+func WithMetrics(m Metrics) Option {
+	return func(r *Relay) { r.metrics = m }
+}
+
+// WithFence guards every claim with f.
+func WithFence(f Fence) Option {
+	return func(r *Relay) { r.fence = f }
+}
+
+// WithPollInterval sets how often the outbox is polled without a connected
+// wakeup source.
+func WithPollInterval(d time.Duration) Option {
+	return func(r *Relay) {
+		if d > 0 {
+			r.pollInterval = d
+		}
+	}
+}
+
+// WithWakeup makes the relay process the outbox as soon as w signals,
+// polling only every safetyInterval while w is connected.
+func WithWakeup(w Wakeup, safetyInterval time.Duration) Option {
+	return func(r *Relay) {
+		r.wakeup = w
+		if safetyInterval > 0 {
+			r.safetyInterval = safetyInterval
+		}
+	}
+}
+
 type Relay struct {
-	uow   *uow.UnitOfWork
-	queue *queue.RedisQueue
-	log   Logger
+	uow       *uow.UnitOfWork
+	queue     queue.Publisher
+	wakeup    Wakeup
+	fence     Fence
+	metrics   Metrics
+	heartbeat Heartbeat
+	log       Logger
 
-	batchSize     int
-	pollInterval  time.Duration
+	batchSize      int
+	pollInterval   time.Duration
+	safetyInterval time.Duration
+	// processingFor is the lease on claimed events; it must cover pushing a
+	// batch to the queue and recording the outcome.
 	processingFor time.Duration
 	maxAttempts   int
 }
 
-func New(db *sqlx.DB, q *queue.RedisQueue, log Logger) *Relay {
-	return &Relay{
-		uow:           uow.New(db),
-		queue:         q,
-		log:           log,
-		batchSize:     100,
-		pollInterval:  500 * time.Millisecond,
-		processingFor: 30 * time.Second,
-		maxAttempts:   10,
+func New(db *sqlx.DB, q queue.Publisher, log Logger, opts ...Option) *Relay {
+	r := &Relay{
+		uow:            uow.New(db),
+		queue:          q,
+		log:            log,
+		batchSize:      100,
+		pollInterval:   500 * time.Millisecond,
+		safetyInterval: 15 * time.Second,
+		processingFor:  30 * time.Second,
+		maxAttempts:    10,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 func (r *Relay) Run(ctx context.Context) error {
-	t := time.NewTicker(r.pollInterval)
+	var wake <-chan struct{}
+	if r.wakeup != nil {
+		wake = r.wakeup.C()
+	}
+
+	t := time.NewTimer(0)
 	defer t.Stop()
 
+	if r.heartbeat != nil {
+		defer r.heartbeat.Stop()
+	}
+
 	r.log.Info(ctx, "outbox relay started")
 	for {
+		r.beat()
 		select {
 		case <-ctx.Done():
 			r.log.Info(ctx, "outbox relay stopped")
 			return ctx.Err()
+		case <-wake:
 		case <-t.C:
-			if err := r.process(ctx); err != nil {
-				r.log.Error(ctx, "outbox relay process failed", "error", err)
-			}
+		}
+
+		r.drain(ctx)
+		t.Reset(r.interval(ctx))
+	}
+}
+
+func (r *Relay) beat() {
+	if r.heartbeat != nil {
+		r.heartbeat.Beat()
+	}
+}
+
+// interval is the time until the next poll when no wakeup arrives. With a
+// connected wakeup source that is the safety interval, cut short when a
+// retry or an expired lease falls due earlier, since neither is notified.
+func (r *Relay) interval(ctx context.Context) time.Duration {
+	if r.wakeup == nil || !r.wakeup.Connected() {
+		return r.pollInterval
+	}
+
+	next, ok, err := outboxdb.New(r.uow.Scope().Executor()).NextDue(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.log.Error(ctx, "outbox relay next due lookup failed", "error", err)
+		}
+		return r.pollInterval
+	}
+	return untilDue(time.Now(), next, ok, r.pollInterval, r.safetyInterval)
+}
+
+// untilDue is the wait until next, kept between floor and ceil; without a
+// next due time it is ceil.
+func untilDue(now, next time.Time, ok bool, floor, ceil time.Duration) time.Duration {
+	if !ok {
+		return ceil
+	}
+	return min(max(next.Sub(now), floor), ceil)
+}
+
+// drain processes batches until the outbox has no more due events.
+func (r *Relay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		r.beat()
+		n, err := r.process(ctx)
+		if err != nil {
+			r.log.Error(ctx, "outbox relay process failed", "error", err)
+			return
+		}
+		if n < r.batchSize {
+			return
 		}
 	}
 }
 
-func (r *Relay) process(ctx context.Context) error {
+// process dispatches one batch and returns the number of claimed events.
+func (r *Relay) process(ctx context.Context) (int, error) {
 	var events []outboxdb.Event
 	err := r.uow.WithinTxRoot(ctx, nil, func(sc uow.Scope) error {
+		if r.fence != nil {
+			if err := r.fence.Check(ctx, sc.Executor()); err != nil {
+				return err
+			}
+		}
 		repo := outboxdb.New(sc.Executor())
 		var err error
-		events, err = repo.ClaimBatch(ctx, r.batchSize)
+		events, err = repo.ClaimBatch(ctx, r.batchSize, r.processingFor)
 		return err
 	})
 	if err != nil || len(events) == 0 {
-		return err
+		return 0, err
 	}
 
 	items := make([]queue.Item, 0, len(events))
 	ids := make([]int64, 0, len(events))
+	var links []trace.Link
 	for _, ev := range events {
 		items = append(items, queue.Item{
-			EventType: ev.EventType,
-			Payload:   ev.PayloadJSON,
-			OutboxID:  ev.ID,
+			EventType:    ev.EventType,
+			Payload:      ev.PayloadJSON,
+			OutboxID:     ev.ID,
+			Key:          ev.OrderingKey,
+			TraceContext: ev.TraceContext,
 		})
 		ids = append(ids, ev.ID)
+		if l, ok := tracing.Link(ev.TraceContext); ok {
+			links = append(links, l)
+		}
 	}
 
-	pushErr := r.queue.EnqueueBatch(ctx, items)
+	// A batch mixes events from many requests: its span links to each of
+	// them, while the items keep their own trace context for delivery.
+	pubCtx, span := tracer.Start(ctx, "outbox.relay publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("messaging.batch.message_count", len(items))),
+	)
+	pushErr := r.queue.Publish(pubCtx, items)
+	if pushErr != nil {
+		span.RecordError(pushErr)
+		span.SetStatus(codes.Error, pushErr.Error())
+	}
+	span.End()
+	if r.metrics != nil {
+		result := "published"
+		if pushErr != nil {
+			result = "failed"
+		}
+		r.metrics.ObserveRelayBatch(len(items), result)
+	}
 
-	return r.uow.WithinTxRoot(ctx, nil, func(sc uow.Scope) error {
+	return len(events), r.uow.WithinTxRoot(ctx, nil, func(sc uow.Scope) error {
 		repo := outboxdb.New(sc.Executor())
 
 		if pushErr == nil {
@@ -92,43 +268,40 @@ func (r *Relay) process(ctx context.Context) error {
 
 		r.log.Error(ctx, "outbox relay enqueue failed", "error", pushErr, "count", len(ids))
 
-		var retryIDs []int64
+		now := time.Now().UTC()
+		var (
+			retries []outboxdb.Retry
+			dead    []int64
+		)
 		for _, ev := range events {
 			if ev.Attempts >= r.maxAttempts {
-				_ = repo.MarkDead(ctx, ev.ID, pushErr.Error())
+				dead = append(dead, ev.ID)
 				continue
 			}
-			retryIDs = append(retryIDs, ev.ID)
+			retries = append(retries, outboxdb.Retry{ID: ev.ID, NextAttemptAt: now.Add(backoff(ev.Attempts))})
+		}
+
+		if err := repo.MarkDeadBatch(ctx, dead, pushErr.Error()); err != nil {
+			return fmt.Errorf("mark %d events dead: %w", len(dead), err)
+		}
+		for _, id := range dead {
+			r.log.Error(ctx, "outbox event dead after max attempts", "outbox_id", id, "attempts", r.maxAttempts, "error", pushErr)
 		}
 
-		next := time.Now().UTC().Add(backoff(minAttempts(events)))
-		return repo.MarkRetryBatch(ctx, retryIDs, next, pushErr.Error())
+		return repo.MarkRetryBatch(ctx, retries, pushErr.Error())
 	})
 }
 
+// backoff returns the delay before retrying an event that failed attempt
+// times: exponential from 2s up to 5m, with the upper half randomized so
+// that events failing together do not retry together.
 func backoff(attempt int) time.Duration {
-	d := time.Duration(1<<attempt) * time.Second
-	if d < 2*time.Second {
-		return 2 * time.Second
+	d := 5 * time.Minute
+	if attempt < 9 {
+		d = max(time.Duration(1<<attempt)*time.Second, 2*time.Second)
 	}
-	if d > 5*time.Minute {
-		return 5 * time.Minute
-	}
-	return d
-}
+	d = min(d, 5*time.Minute)
 
-func minAttempts(events []outboxdb.Event) int {
-	if len(events) == 0 {
-		return 1
-	}
-	min := events[0].Attempts
-	for _, e := range events {
-		if e.Attempts < min {
-			min = e.Attempts
-		}
-	}
-	if min < 1 {
-		return 1
-	}
-	return min
+	half := d / 2
+	return half + rand.N(half+1)
 }
@@ -0,0 +1,48 @@
+package outboxrelay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{attempt: 0, min: time.Second, max: 2 * time.Second},
+		{attempt: 3, min: 4 * time.Second, max: 8 * time.Second},
+		{attempt: 8, min: 128 * time.Second, max: 256 * time.Second},
+		{attempt: 50, min: 150 * time.Second, max: 5 * time.Minute},
+	}
+
+	for _, tc := range cases {
+		for range 100 {
+			if d := backoff(tc.attempt); d < tc.min || d > tc.max {
+				t.Fatalf("backoff(%d) = %s, want within [%s, %s]", tc.attempt, d, tc.min, tc.max)
+			}
+		}
+	}
+}
+
+func TestUntilDue(t *testing.T) {
+	now := time.Now()
+	const floor, ceil = 500 * time.Millisecond, 15 * time.Second
+
+	cases := []struct {
+		name string
+		next time.Time
+		ok   bool
+		want time.Duration
+	}{
+		{name: "nothing scheduled", want: ceil},
+		{name: "retry soon", next: now.Add(3 * time.Second), ok: true, want: 3 * time.Second},
+		{name: "retry later than the safety poll", next: now.Add(time.Minute), ok: true, want: ceil},
+		{name: "already due", next: now.Add(-time.Second), ok: true, want: floor},
+	}
+	for _, tc := range cases {
+		if got := untilDue(now, tc.next, tc.ok, floor, ceil); got != tc.want {
+			t.Errorf("%s: untilDue = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package webhookworker
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/webhooks"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+)
+
+// defaultBatchWait applies to subscriptions that set a batch size but no
+// wait.
+const defaultBatchWait = time.Second
+
+// maxPendingBatches bounds how many batches' worth of items a subscription
+// may have waiting or in flight. Beyond that new items are refused, so a
+// slow receiver holds back its own messages instead of filling memory.
+const maxPendingBatches = 4
+
+// batchItem is one event waiting in a subscription's batch. done is called
+// exactly once with the outcome of its delivery.
+type batchItem struct {
+	ev       events.Envelope
+	outboxID int64
+	// link points at the span handling the item's message.
+	link trace.Link
+	done func(error)
+}
+
+type batch struct {
+	sub   webhooks.Subscription
+	items []batchItem
+	timer *time.Timer
+}
+
+// batcher accumulates events per subscription and flushes a batch once it
+// holds MaxItems events or MaxWait has passed since its first event.
+type batcher struct {
+	ctx   context.Context
+	flush func(ctx context.Context, sub webhooks.Subscription, items []batchItem)
+
+	mu      sync.Mutex
+	open    map[int64]*batch
+	pending map[int64]int // items per subscription not flushed yet
+	wg      sync.WaitGroup
+}
+
+func newBatcher(ctx context.Context, flush func(context.Context, webhooks.Subscription, []batchItem)) *batcher {
+	return &batcher{ctx: ctx, flush: flush, open: map[int64]*batch{}, pending: map[int64]int{}}
+}
+
+// add queues it for sub. It reports false, leaving it.done uncalled, when
+// sub already has maxPendingBatches batches' worth of items pending.
+func (b *batcher) add(sub webhooks.Subscription, it batchItem) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pending[sub.ID] >= sub.Batching.MaxItems*maxPendingBatches {
+		return false
+	}
+	b.pending[sub.ID]++
+
+	bt := b.open[sub.ID]
+	if bt == nil {
+		wait := sub.Batching.MaxWait
+		if wait <= 0 {
+			wait = defaultBatchWait
+		}
+		bt = &batch{sub: sub}
+		bt.timer = time.AfterFunc(wait, func() { b.expire(sub.ID, bt) })
+		b.open[sub.ID] = bt
+	}
+
+	bt.items = append(bt.items, it)
+	if len(bt.items) >= sub.Batching.MaxItems {
+		bt.timer.Stop()
+		delete(b.open, sub.ID)
+		b.start(bt)
+	}
+	return true
+}
+
+func (b *batcher) expire(subID int64, bt *batch) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The batch may have been flushed for being full in the meantime.
+	if b.open[subID] != bt {
+		return
+	}
+	delete(b.open, subID)
+	b.start(bt)
+}
+
+// start flushes bt in the background; b.mu must be held.
+func (b *batcher) start(bt *batch) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.flush(b.ctx, bt.sub, bt.items)
+
+		b.mu.Lock()
+		if b.pending[bt.sub.ID] -= len(bt.items); b.pending[bt.sub.ID] <= 0 {
+			delete(b.pending, bt.sub.ID)
+		}
+		b.mu.Unlock()
+	}()
+}
+
+// close flushes every open batch without waiting for it to fill up and
+// waits for all flushes to finish. No items may be added afterwards.
+func (b *batcher) close() {
+	b.mu.Lock()
+	for id, bt := range b.open {
+		bt.timer.Stop()
+		delete(b.open, id)
+		b.start(bt)
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+// batchResponse is the optional body a receiver returns with a 2xx status to
+// reject part of a batch. Items not listed are considered delivered.
+type batchResponse struct {
+	Failed []struct {
+		ID    string `json:"id"`
+		Error string `json:"error"`
+	} `json:"failed"`
+}
+
+// parseBatchFailures returns the event ids the receiver rejected, mapped to
+// the reason it gave. Bodies that are not a batchResponse reject nothing.
+func parseBatchFailures(body []byte) map[string]string {
+	var resp batchResponse
+	if len(body) == 0 || json.Unmarshal(body, &resp) != nil || len(resp.Failed) == 0 {
+		return nil
+	}
+
+	out := make(map[string]string, len(resp.Failed))
+	for _, f := range resp.Failed {
+		msg := f.Error
+		if msg == "" {
+			msg = "rejected by receiver"
+		}
+		out[f.ID] = msg
+	}
+	return out
+}
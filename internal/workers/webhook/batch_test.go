@@ -0,0 +1,99 @@
+package webhookworker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/webhooks"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+)
+
+func TestBatcher_FlushesWhenFullAndOnTimeout(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		flushed [][]string
+	)
+	b := newBatcher(context.Background(), func(_ context.Context, _ webhooks.Subscription, items []batchItem) {
+		ids := make([]string, 0, len(items))
+		for _, it := range items {
+			ids = append(ids, it.ev.ID)
+			it.done(nil)
+		}
+		mu.Lock()
+		flushed = append(flushed, ids)
+		mu.Unlock()
+	})
+
+	sub := webhooks.Subscription{ID: 1, Batching: webhooks.Batching{MaxItems: 2, MaxWait: 20 * time.Millisecond}}
+	for _, id := range []string{"a", "b", "c"} {
+		b.add(sub, batchItem{ev: events.Envelope{ID: id}, done: func(error) {}})
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	b.close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(flushed) != 2 || len(flushed[0]) != 2 || len(flushed[1]) != 1 || flushed[1][0] != "c" {
+		t.Fatalf("unexpected batches: %v", flushed)
+	}
+}
+
+func TestBatcher_CloseFlushesOpenBatches(t *testing.T) {
+	var got int
+	b := newBatcher(context.Background(), func(_ context.Context, _ webhooks.Subscription, items []batchItem) {
+		got += len(items)
+	})
+
+	sub := webhooks.Subscription{ID: 1, Batching: webhooks.Batching{MaxItems: 10, MaxWait: time.Hour}}
+	b.add(sub, batchItem{ev: events.Envelope{ID: "a"}})
+	b.close()
+
+	if got != 1 {
+		t.Fatalf("expected open batch to be flushed on close, got %d items", got)
+	}
+}
+
+func TestBatcher_RefusesItemsBeyondPendingLimit(t *testing.T) {
+	release := make(chan struct{})
+	b := newBatcher(context.Background(), func(_ context.Context, _ webhooks.Subscription, items []batchItem) {
+		<-release
+	})
+
+	sub := webhooks.Subscription{ID: 1, Batching: webhooks.Batching{MaxItems: 2, MaxWait: time.Hour}}
+	other := webhooks.Subscription{ID: 2, Batching: sub.Batching}
+	for i := range 2 * maxPendingBatches {
+		if !b.add(sub, batchItem{}) {
+			t.Fatalf("item %d refused below the limit", i)
+		}
+	}
+	if b.add(sub, batchItem{}) {
+		t.Fatal("expected an item beyond the limit to be refused while batches are in flight")
+	}
+	if !b.add(other, batchItem{}) {
+		t.Fatal("the limit of one subscription held back another")
+	}
+
+	// Once the stuck flushes finish the subscription takes items again.
+	close(release)
+	defer b.close()
+	deadline := time.Now().Add(time.Second)
+	for !b.add(sub, batchItem{}) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected items to be accepted after the flushes finished")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestParseBatchFailures(t *testing.T) {
+	failed := parseBatchFailures([]byte(`{"failed":[{"id":"a","error":"bad payload"},{"id":"b"}]}`))
+	if failed["a"] != "bad payload" || failed["b"] == "" || len(failed) != 2 {
+		t.Fatalf("unexpected failures: %v", failed)
+	}
+	if parseBatchFailures([]byte(`ok`)) != nil || parseBatchFailures(nil) != nil {
+		t.Fatalf("non-report bodies must not reject items")
+	}
+}
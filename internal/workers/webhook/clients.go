@@ -0,0 +1,239 @@
+package webhookworker
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/webhooks"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/urlpolicy"
+)
+
+const (
+	// tlsFileCheckInterval bounds how often TLS files are checked for changes.
+	tlsFileCheckInterval = 10 * time.Second
+	// clientIdleTTL is how long an unused client is kept before its
+	// connections are closed.
+	clientIdleTTL = 10 * time.Minute
+)
+
+// TLSFiles names PEM files used for every destination whose subscription
+// does not carry its own certificates. Files are re-read when they change.
+type TLSFiles struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// clients keeps an http.Client per destination and TLS material, so that
+// destinations do not share connection pools and rotated certificates take
+// effect without a restart. Clients for untrusted destinations enforce the
+// URL policy on every connection and redirect.
+type clients struct {
+	timeout time.Duration
+	policy  *urlpolicy.Policy
+
+	ca, cert, key *pemFile
+
+	mu         sync.Mutex
+	items      map[string]*clientEntry
+	lastPruned time.Time
+}
+
+type clientEntry struct {
+	client   *http.Client
+	lastUsed time.Time
+}
+
+func newClients(timeout time.Duration, files TLSFiles, policy *urlpolicy.Policy) *clients {
+	return &clients{
+		timeout: timeout,
+		policy:  policy,
+		ca:      &pemFile{path: files.CAFile},
+		cert:    &pemFile{path: files.CertFile},
+		key:     &pemFile{path: files.KeyFile},
+		items:   map[string]*clientEntry{},
+	}
+}
+
+// get returns the client for requests to dst made on behalf of a
+// subscription with the given certificates. Trusted clients skip the URL
+// policy.
+func (c *clients) get(dst string, t webhooks.TLS, trusted bool) (*http.Client, error) {
+	m, err := c.material(t)
+	if err != nil {
+		return nil, err
+	}
+	var policy *urlpolicy.Policy
+	if !trusted {
+		policy = c.policy
+	}
+	key := fmt.Sprintf("%s|%s|%t", dst, m.fingerprint(), policy != nil)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.prune(now)
+
+	if e, ok := c.items[key]; ok {
+		e.lastUsed = now
+		return e.client, nil
+	}
+
+	client, err := m.client(c.timeout, policy)
+	if err != nil {
+		return nil, err
+	}
+	c.items[key] = &clientEntry{client: client, lastUsed: now}
+	return client, nil
+}
+
+// prune drops clients unused for clientIdleTTL, such as the ones built for
+// certificates that have since been rotated. c.mu must be held.
+func (c *clients) prune(now time.Time) {
+	if now.Sub(c.lastPruned) < time.Minute {
+		return
+	}
+	c.lastPruned = now
+
+	for k, e := range c.items {
+		if now.Sub(e.lastUsed) > clientIdleTTL {
+			e.client.CloseIdleConnections()
+			delete(c.items, k)
+		}
+	}
+}
+
+type tlsMaterial struct {
+	ca, cert, key []byte
+}
+
+// material resolves the certificates for a subscription: its own PEM first,
+// then the configured files.
+func (c *clients) material(t webhooks.TLS) (tlsMaterial, error) {
+	var m tlsMaterial
+
+	if t.CACert != "" {
+		m.ca = []byte(t.CACert)
+	} else {
+		ca, err := c.ca.load()
+		if err != nil {
+			return tlsMaterial{}, err
+		}
+		m.ca = ca
+	}
+
+	if t.ClientCert != "" {
+		m.cert, m.key = []byte(t.ClientCert), []byte(t.ClientKey)
+		return m, nil
+	}
+	cert, err := c.cert.load()
+	if err != nil {
+		return tlsMaterial{}, err
+	}
+	key, err := c.key.load()
+	if err != nil {
+		return tlsMaterial{}, err
+	}
+	m.cert, m.key = cert, key
+	return m, nil
+}
+
+func (m tlsMaterial) fingerprint() string {
+	if m.ca == nil && m.cert == nil && m.key == nil {
+		return "default"
+	}
+	h := sha256.New()
+	for _, b := range [][]byte{m.ca, m.cert, m.key} {
+		_, _ = fmt.Fprintf(h, "%d:", len(b))
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (m tlsMaterial) client(timeout time.Duration, policy *urlpolicy.Policy) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	client := &http.Client{Timeout: timeout, Transport: transport}
+
+	if policy != nil {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   policy.Control,
+		}
+		transport.DialContext = dialer.DialContext
+		// A proxy would dial on our behalf, bypassing the address check.
+		transport.Proxy = nil
+		client.CheckRedirect = policy.CheckRedirect
+	}
+
+	if m.ca != nil || m.cert != nil {
+		cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if m.ca != nil {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(m.ca) {
+				return nil, errors.New("tls: no certificates in CA bundle")
+			}
+			cfg.RootCAs = pool
+		}
+		if m.cert != nil {
+			pair, err := tls.X509KeyPair(m.cert, m.key)
+			if err != nil {
+				return nil, fmt.Errorf("tls: client certificate: %w", err)
+			}
+			cfg.Certificates = []tls.Certificate{pair}
+		}
+		transport.TLSClientConfig = cfg
+	}
+
+	return client, nil
+}
+
+// pemFile caches the contents of a file and re-reads it when its
+// modification time changes, checking at most every tlsFileCheckInterval.
+type pemFile struct {
+	path string
+
+	mu      sync.Mutex
+	checked time.Time
+	modTime time.Time
+	data    []byte
+}
+
+func (f *pemFile) load() ([]byte, error) {
+	if f.path == "" {
+		return nil, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.data != nil && time.Since(f.checked) < tlsFileCheckInterval {
+		return f.data, nil
+	}
+	f.checked = time.Now()
+
+	st, err := os.Stat(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	if f.data != nil && st.ModTime().Equal(f.modTime) {
+		return f.data, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	f.data, f.modTime = data, st.ModTime()
+	return data, nil
+}
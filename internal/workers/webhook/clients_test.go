@@ -0,0 +1,67 @@
+package webhookworker
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/webhooks"
+)
+
+func TestClients_SubscriptionCA(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer srv.Close()
+
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+	c := newClients(time.Second, TLSFiles{}, nil)
+
+	def, err := c.get(srv.URL, webhooks.TLS{}, false)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if _, err := def.Get(srv.URL); err == nil {
+		t.Fatalf("expected the default client to reject the test CA")
+	}
+
+	custom, err := c.get(srv.URL, webhooks.TLS{CACert: caPEM}, false)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp, err := custom.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request with subscription CA: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestPEMFile_ReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, []byte("v1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &pemFile{path: path}
+	if b, err := f.load(); err != nil || string(b) != "v1" {
+		t.Fatalf("load: %q %v", b, err)
+	}
+
+	if err := os.WriteFile(path, []byte("v2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, _ := f.load(); string(b) != "v1" {
+		t.Fatalf("expected cached contents within the check interval, got %q", b)
+	}
+	f.checked = time.Time{}
+	if b, err := f.load(); err != nil || string(b) != "v2" {
+		t.Fatalf("expected reloaded contents, got %q %v", b, err)
+	}
+}
@@ -0,0 +1,129 @@
+package webhookworker
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/breaker"
+)
+
+// errDestinationBusy is returned when a destination has no free delivery slot
+// within acquireTimeout; the message stays pending and is retried later.
+var errDestinationBusy = errors.New("destination busy")
+
+type DestinationLimits struct {
+	// Concurrency is the maximum number of in-flight requests per destination.
+	Concurrency int
+	// RPS and Burst configure the per-destination token bucket; RPS <= 0 disables it.
+	RPS   float64
+	Burst int
+	// AcquireTimeout bounds how long a delivery waits for a slot before giving up.
+	AcquireTimeout time.Duration
+
+	Breaker breaker.Config
+}
+
+type destination struct {
+	key     string
+	breaker *breaker.Breaker
+	limiter *rate.Limiter
+	slots   chan struct{}
+}
+
+type destinations struct {
+	limits DestinationLimits
+
+	mu    sync.Mutex
+	items map[string]*destination
+}
+
+func newDestinations(limits DestinationLimits) *destinations {
+	if limits.Concurrency <= 0 {
+		limits.Concurrency = 4
+	}
+	if limits.AcquireTimeout <= 0 {
+		limits.AcquireTimeout = time.Second
+	}
+	if limits.Burst <= 0 {
+		limits.Burst = 1
+	}
+	return &destinations{limits: limits, items: map[string]*destination{}}
+}
+
+// get returns the destination for targetURL; endpoints sharing scheme and host
+// share limits and breaker state.
+func (d *destinations) get(targetURL string) *destination {
+	key := destinationKey(targetURL)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if dst, ok := d.items[key]; ok {
+		return dst
+	}
+
+	dst := &destination{
+		key:     key,
+		breaker: breaker.New(d.limits.Breaker),
+		slots:   make(chan struct{}, d.limits.Concurrency),
+	}
+	if d.limits.RPS > 0 {
+		dst.limiter = rate.NewLimiter(rate.Limit(d.limits.RPS), d.limits.Burst)
+	}
+	d.items[key] = dst
+	return dst
+}
+
+// acquire reserves a delivery slot. The returned release func must be called
+// with the delivery outcome.
+func (d *destinations) acquire(ctx context.Context, dst *destination) (func(ok bool), error) {
+	if err := dst.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, d.limits.AcquireTimeout)
+	defer cancel()
+
+	select {
+	case dst.slots <- struct{}{}:
+	case <-waitCtx.Done():
+		dst.breaker.Cancel()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errDestinationBusy
+	}
+
+	if dst.limiter != nil {
+		if err := dst.limiter.Wait(waitCtx); err != nil {
+			<-dst.slots
+			dst.breaker.Cancel()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, errDestinationBusy
+		}
+	}
+
+	return func(ok bool) {
+		<-dst.slots
+		if ok {
+			dst.breaker.Success()
+		} else {
+			dst.breaker.Failure()
+		}
+	}, nil
+}
+
+func destinationKey(targetURL string) string {
+	u, err := url.Parse(targetURL)
+	if err != nil || u.Host == "" {
+		return targetURL
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,86 @@
+package webhookworker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/webhooks"
+)
+
+// Subscriptions lists the endpoints events are fanned out to.
+type Subscriptions interface {
+	ListActive(ctx context.Context) ([]webhooks.Subscription, error)
+}
+
+// Deliveries records the outcome of every delivery attempt.
+type Deliveries interface {
+	Record(ctx context.Context, a webhooks.DeliveryAttempt) error
+}
+
+// Dedupe remembers which subscriptions already received an outbox event, so
+// that a redelivered message skips them.
+type Dedupe interface {
+	Delivered(ctx context.Context, outboxID, subscriptionID int64) (bool, error)
+	MarkDelivered(ctx context.Context, outboxID, subscriptionID int64) error
+}
+
+// staticSubscriptionID identifies the target configured on the worker itself.
+const staticSubscriptionID = 0
+
+// subscriptionCache keeps the active subscriptions in memory for refresh so
+// that the database is not queried for every message. The statically
+// configured target, if any, is always included with ID 0.
+type subscriptionCache struct {
+	src     Subscriptions
+	static  []webhooks.Subscription
+	refresh time.Duration
+
+	mu        sync.Mutex
+	items     []webhooks.Subscription
+	fetchedAt time.Time
+}
+
+func newSubscriptionCache(targetURL string) *subscriptionCache {
+	c := &subscriptionCache{refresh: 5 * time.Second}
+	if targetURL != "" {
+		c.static = []webhooks.Subscription{{
+			ID:          staticSubscriptionID,
+			URL:         targetURL,
+			PayloadMode: webhooks.PayloadFull,
+			Active:      true,
+		}}
+	}
+	return c
+}
+
+// list returns the current subscriptions. On a refresh error the previous
+// list is served and the error is returned alongside it.
+func (c *subscriptionCache) list(ctx context.Context) ([]webhooks.Subscription, error) {
+	if c.src == nil {
+		return c.static, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.items != nil && time.Since(c.fetchedAt) < c.refresh {
+		return c.items, nil
+	}
+
+	subs, err := c.src.ListActive(ctx)
+	if err != nil {
+		if c.items != nil {
+			return c.items, err
+		}
+		return nil, err
+	}
+
+	items := make([]webhooks.Subscription, 0, len(c.static)+len(subs))
+	items = append(items, c.static...)
+	items = append(items, subs...)
+
+	c.items = items
+	c.fetchedAt = time.Now()
+	return items, nil
+}
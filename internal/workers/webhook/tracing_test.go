@@ -0,0 +1,93 @@
+package webhookworker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/tracing"
+)
+
+type ackConsumer struct{ acked, retried []string }
+
+func (c *ackConsumer) Receive(context.Context, int) ([]queue.Message, error) { return nil, nil }
+func (c *ackConsumer) Ack(_ context.Context, id string) error {
+	c.acked = append(c.acked, id)
+	return nil
+}
+func (c *ackConsumer) Retry(_ context.Context, id string, _ time.Duration) error {
+	c.retried = append(c.retried, id)
+	return nil
+}
+func (c *ackConsumer) Close(context.Context) error { return nil }
+
+func TestWorker_DeliveryContinuesEnqueuingTrace(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider(tracing.Config{ServiceName: "test", SampleRatio: 1}, sdktrace.WithSyncer(exp))
+	otel.SetTracerProvider(tp)
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	traceparent := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		traceparent <- r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	// The request that enqueued the event.
+	reqCtx, reqSpan := tp.Tracer("test").Start(context.Background(), "POST /api/v1/incidents")
+	encoded := tracing.Inject(reqCtx)
+	reqSpan.End()
+	traceID := reqSpan.SpanContext().TraceID()
+
+	ev, err := events.New("incident.created", events.Schema("incident.created", 1), "incident/1", time.Now(), map[string]int{"id": 1})
+	if err != nil {
+		t.Fatalf("new event: %v", err)
+	}
+	payload, _ := json.Marshal(ev)
+
+	q := &ackConsumer{}
+	w := New(q, srv.URL, nopLogger{})
+	w.busy.Add(1)
+	w.process(context.Background(), queue.Message{
+		ID: "1-0",
+		Item: queue.Item{
+			EventType:    ev.Type,
+			Payload:      string(payload),
+			OutboxID:     1,
+			TraceContext: encoded,
+		},
+		Deliveries: 1,
+	})
+
+	if len(q.acked) != 1 {
+		t.Fatalf("expected the message to be acked, got %v", q.acked)
+	}
+
+	header := <-traceparent
+	sent := trace.SpanContextFromContext(tracing.Extract(context.Background(), "traceparent="+header))
+	if sent.TraceID() != traceID {
+		t.Fatalf("receiver got traceparent %q, want trace %s", header, traceID)
+	}
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, s := range exp.GetSpans() {
+		spans[s.Name] = s
+	}
+	process, send := spans["webhook process"], spans["webhook send"]
+	if process.SpanContext.TraceID() != traceID || process.Parent.SpanID() != reqSpan.SpanContext().SpanID() {
+		t.Fatalf("process span is not a child of the enqueuing request: %+v", process.Parent)
+	}
+	if send.Parent.SpanID() != process.SpanContext.SpanID() {
+		t.Fatalf("send span is not a child of the process span")
+	}
+}
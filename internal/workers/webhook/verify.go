@@ -0,0 +1,154 @@
+package webhookworker
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/webhooks"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/breaker"
+)
+
+const (
+	maxVerificationAttempts = 10
+	verificationBaseBackoff = time.Minute
+	verificationMaxBackoff  = time.Hour
+	verificationBatch       = 10
+	// verificationLease hides a claimed subscription from other workers
+	// while its challenge is in flight.
+	verificationLease = 2 * time.Minute
+)
+
+// Verifications stores the verification state of subscriptions.
+type Verifications interface {
+	ClaimVerifications(ctx context.Context, limit int, lease, failingFor time.Duration) ([]webhooks.Subscription, error)
+	CompleteVerification(ctx context.Context, id int64, res webhooks.VerificationResult) error
+}
+
+// WithVerification challenges pending subscriptions every interval, and
+// re-verifies subscriptions whose deliveries have all failed for failingFor.
+func WithVerification(v Verifications, interval, failingFor time.Duration) Option {
+	return func(w *Worker) {
+		w.verifications = v
+		if interval > 0 {
+			w.verifyInterval = interval
+		}
+		if failingFor > 0 {
+			w.reverifyAfter = failingFor
+		}
+	}
+}
+
+// verifyLoop runs challenges until ctx is canceled.
+func (w *Worker) verifyLoop(ctx context.Context) {
+	t := time.NewTicker(w.verifyInterval)
+	defer t.Stop()
+
+	for {
+		if err := w.verifyDue(ctx); err != nil && ctx.Err() == nil {
+			w.log.Error(ctx, "webhook verification failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (w *Worker) verifyDue(ctx context.Context) error {
+	subs, err := w.verifications.ClaimVerifications(ctx, verificationBatch, verificationLease, w.reverifyAfter)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		res := webhooks.VerificationResult{URL: sub.URL}
+
+		err := w.challenge(ctx, sub)
+		switch {
+		case ctx.Err() != nil:
+			// Shutting down; the claim lease runs out and the challenge is
+			// sent again later.
+			return nil
+		case errors.Is(err, breaker.ErrOpen), errors.Is(err, errDestinationBusy):
+			// The destination is only throttled or recovering, which says
+			// nothing about the subscription.
+			res.Deferred = true
+			res.RetryAt = time.Now().Add(deferDelay)
+		case err != nil:
+			attempts := sub.Verification.Attempts + 1
+			res.Err = err.Error()
+			res.RetryAt = time.Now().Add(verificationBackoff(attempts))
+			res.GiveUp = attempts >= maxVerificationAttempts
+			w.log.Error(ctx, "webhook verification challenge failed", "error", err, "subscription_id", sub.ID, "attempts", attempts, "give_up", res.GiveUp)
+		default:
+			w.log.Info(ctx, "webhook subscription verified", "subscription_id", sub.ID)
+		}
+
+		if err := w.verifications.CompleteVerification(ctx, sub.ID, res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// challenge sends a webhook.verification event with a fresh token and
+// checks that the receiver echoes it.
+func (w *Worker) challenge(ctx context.Context, sub webhooks.Subscription) error {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(raw)
+
+	ev, err := events.New(
+		webhooks.EventTypeVerification,
+		events.Schema(webhooks.EventTypeVerification, webhooks.VerificationEventVersion),
+		strconv.FormatInt(sub.ID, 10),
+		time.Now(),
+		webhooks.VerificationData{SubscriptionID: sub.ID, Token: token},
+	)
+	if err != nil {
+		return err
+	}
+
+	body, headers, err := ev.HTTPBody(events.ModeStructured)
+	if err != nil {
+		return err
+	}
+	headers.Set("X-Event-Type", ev.Type)
+	headers.Set("Idempotency-Key", ev.ID)
+
+	resp, err := w.send(ctx, sub, body, headers)
+	if err != nil {
+		return err
+	}
+
+	var echo struct {
+		Token string `json:"token"`
+	}
+	if json.Unmarshal(resp, &echo) != nil {
+		echo.Token = string(bytes.TrimSpace(resp))
+	}
+	if echo.Token != token {
+		return fmt.Errorf("receiver did not echo the verification token")
+	}
+	return nil
+}
+
+func verificationBackoff(attempts int) time.Duration {
+	d := verificationBaseBackoff
+	for i := 1; i < attempts && d < verificationMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, verificationMaxBackoff)
+}
@@ -0,0 +1,122 @@
+package webhookworker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/webhooks"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/breaker"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(context.Context, string, ...any)  {}
+func (nopLogger) Error(context.Context, string, ...any) {}
+
+func TestWorker_Challenge(t *testing.T) {
+	echo := true
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ev, err := events.Decode(body)
+		if err != nil || ev.Type != webhooks.EventTypeVerification {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var data webhooks.VerificationData
+		_ = json.Unmarshal(ev.Data, &data)
+		if !echo {
+			data.Token = "wrong"
+		}
+		_ = json.NewEncoder(rw).Encode(map[string]string{"token": data.Token})
+	}))
+	defer srv.Close()
+
+	w := New(nil, "", nopLogger{})
+	sub := webhooks.Subscription{ID: 1, URL: srv.URL}
+
+	if err := w.challenge(context.Background(), sub); err != nil {
+		t.Fatalf("challenge: %v", err)
+	}
+
+	echo = false
+	if err := w.challenge(context.Background(), sub); err == nil {
+		t.Fatalf("expected a wrong token to fail verification")
+	}
+}
+
+func TestVerificationBackoff(t *testing.T) {
+	if got := verificationBackoff(1); got != time.Minute {
+		t.Fatalf("first retry: got %s", got)
+	}
+	if got := verificationBackoff(3); got != 4*time.Minute {
+		t.Fatalf("third retry: got %s", got)
+	}
+	if got := verificationBackoff(20); got != time.Hour {
+		t.Fatalf("backoff must be capped, got %s", got)
+	}
+}
+
+// memoryVerifications hands out the same subscription on every claim and
+// records the results.
+type memoryVerifications struct {
+	sub     webhooks.Subscription
+	results []webhooks.VerificationResult
+}
+
+func (v *memoryVerifications) ClaimVerifications(context.Context, int, time.Duration, time.Duration) ([]webhooks.Subscription, error) {
+	return []webhooks.Subscription{v.sub}, nil
+}
+
+func (v *memoryVerifications) CompleteVerification(_ context.Context, _ int64, res webhooks.VerificationResult) error {
+	v.results = append(v.results, res)
+	return nil
+}
+
+func TestWorker_VerifyDueDefersThrottledChallenges(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	v := &memoryVerifications{sub: webhooks.Subscription{ID: 1, URL: srv.URL}}
+	w := New(nil, "", nopLogger{},
+		WithVerification(v, time.Minute, time.Hour),
+		WithDestinationLimits(DestinationLimits{Breaker: breaker.Config{FailureThreshold: 1, OpenTimeout: time.Minute}}),
+	)
+	ctx := context.Background()
+
+	// A failed challenge is an attempt; it also opens the breaker.
+	if err := w.verifyDue(ctx); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	// With the breaker open nothing is sent and no attempt is counted.
+	if err := w.verifyDue(ctx); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if len(v.results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", v.results)
+	}
+	if r := v.results[0]; r.Deferred || r.Err == "" {
+		t.Fatalf("expected a failed attempt, got %+v", r)
+	}
+	if r := v.results[1]; !r.Deferred || r.GiveUp || r.RetryAt.IsZero() {
+		t.Fatalf("expected a deferred challenge, got %+v", r)
+	}
+
+	// A challenge cut short by shutdown is not recorded at all.
+	w = New(nil, "", nopLogger{}, WithVerification(v, time.Minute, time.Hour))
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := w.verifyDue(canceled); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if len(v.results) != 2 {
+		t.Fatalf("expected no result on shutdown, got %+v", v.results[2:])
+	}
+}
@@ -4,137 +4,738 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/webhooks"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/breaker"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/tracing"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/urlpolicy"
 )
 
+var tracer = otel.Tracer("github.com/m1ll3r1337/geo-notifications-service/internal/workers/webhook")
+
 type Logger interface {
 	Info(ctx context.Context, msg string, args ...any)
 	Error(ctx context.Context, msg string, args ...any)
 }
 
+// errDeliveryFailed marks a message for which at least one subscription
+// delivery failed; the individual failures are logged by handle.
+var errDeliveryFailed = errors.New("webhook delivery failed")
+
+// errDeliveryDeferred marks a message whose deliveries were all held back by
+// a destination's breaker or limits without being attempted. Such a message
+// is retried after deferDelay without counting towards MaxDeliveries, so an
+// outage of a receiver does not drop its events.
+var errDeliveryDeferred = errors.New("webhook delivery deferred")
+
+// deferDelay is how long a deferred message waits before it is retried.
+const deferDelay = 5 * time.Second
+
+// maxResponseBody bounds how much of a receiver's response is read.
+const maxResponseBody = 1 << 20
+
+// legacyCheckEventType is the outbox event type used before events were
+// wrapped in CloudEvents envelopes.
+const legacyCheckEventType = "location_check"
+
+// Metrics records delivery attempts.
+type Metrics interface {
+	ObserveWebhookDelivery(outcome string, d time.Duration)
+}
+
+// Heartbeat is told about every receive loop iteration, so that a liveness
+// probe can tell a stuck worker from an idle one.
+type Heartbeat interface {
+	Beat()
+	Stop()
+}
+
+type Option func(*Worker)
+
+// WithHeartbeat beats h on every receive while Run runs.
+func WithHeartbeat(h Heartbeat) Option {
+	return func(w *Worker) { w.heartbeat = h }
+}
+
+// WithMetrics records the outcome and latency of every delivery attempt.
+func WithMetrics(m Metrics) Option {
+	return func(w *Worker) { w.metrics = m }
+}
+
+// WithSubscriptions fans events out to the active subscriptions from src,
+// re-read at most every refresh.
+func WithSubscriptions(src Subscriptions, refresh time.Duration) Option {
+	return func(w *Worker) {
+		w.subs.src = src
+		if refresh > 0 {
+			w.subs.refresh = refresh
+		}
+	}
+}
+
+// WithDeliveries records every delivery attempt, including the error of
+// failed ones, in d.
+func WithDeliveries(d Deliveries) Option {
+	return func(w *Worker) { w.deliveries = d }
+}
+
+// WithDedupe skips subscriptions that already received a redelivered
+// message. Without it every redelivery goes to all subscriptions.
+func WithDedupe(d Dedupe) Option {
+	return func(w *Worker) { w.dedupe = d }
+}
+
+// WithContentMode selects structured or binary CloudEvents HTTP encoding.
+func WithContentMode(m events.Mode) Option {
+	return func(w *Worker) { w.contentMode = m }
+}
+
+// WithDestinationLimits sets per-destination concurrency, rate and breaker limits.
+func WithDestinationLimits(l DestinationLimits) Option {
+	return func(w *Worker) { w.destinations = newDestinations(l) }
+}
+
+// WithConcurrency sets the number of deliveries processed in parallel.
+func WithConcurrency(n int) Option {
+	return func(w *Worker) {
+		if n > 0 {
+			w.concurrency = n
+		}
+	}
+}
+
+// WithDrainTimeout bounds how long Run waits for in-flight deliveries after
+// its context is canceled.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(w *Worker) {
+		if d > 0 {
+			w.drainTimeout = d
+		}
+	}
+}
+
+// WithTimeout sets the per-request HTTP timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(w *Worker) {
+		if d > 0 {
+			w.timeout = d
+		}
+	}
+}
+
+// WithURLPolicy restricts the addresses subscriptions may deliver to. The
+// target passed to New is configured by the operator and exempt from it.
+func WithURLPolicy(p urlpolicy.Policy) Option {
+	return func(w *Worker) { w.policy = &p }
+}
+
+// WithTLSFiles sets the CA bundle and client certificate used for
+// destinations whose subscription has no certificates of its own.
+func WithTLSFiles(f TLSFiles) Option {
+	return func(w *Worker) { w.tlsFiles = f }
+}
+
 type Worker struct {
-	rdb      *redis.Client
-	stream   string
-	group    string
-	consumer string
+	queue queue.Consumer
+
+	timeout    time.Duration
+	tlsFiles   TLSFiles
+	policy     *urlpolicy.Policy
+	clients    *clients
+	subs       *subscriptionCache
+	deliveries Deliveries
+	dedupe     Dedupe
+
+	verifications  Verifications
+	verifyInterval time.Duration
+	reverifyAfter  time.Duration
+	contentMode    events.Mode
+	destinations   *destinations
+	batches        *batcher
 
-	httpClient *http.Client
-	targetURL  string
+	concurrency  int
+	drainTimeout time.Duration
 
-	dedupeTTL time.Duration
+	busy atomic.Int64
+
+	metrics   Metrics
+	heartbeat Heartbeat
 	log       Logger
 }
 
-func New(rdb *redis.Client, stream, group, consumer, targetURL string, log Logger) *Worker {
-	return &Worker{
-		rdb:      rdb,
-		stream:   stream,
-		group:    group,
-		consumer: consumer,
-		httpClient: &http.Client{
-			Timeout: 5 * time.Second,
-		},
-		targetURL: targetURL,
-		dedupeTTL: 24 * time.Hour,
-		log:       log,
+// New constructs a webhook worker delivering messages from q. A non-empty
+// targetURL receives every event in addition to the subscriptions configured
+// with WithSubscriptions.
+func New(q queue.Consumer, targetURL string, log Logger, opts ...Option) *Worker {
+	w := &Worker{
+		queue:          q,
+		timeout:        5 * time.Second,
+		subs:           newSubscriptionCache(targetURL),
+		contentMode:    events.ModeStructured,
+		destinations:   newDestinations(DestinationLimits{}),
+		concurrency:    8,
+		drainTimeout:   8 * time.Second,
+		verifyInterval: 30 * time.Second,
+		reverifyAfter:  time.Hour,
+		log:            log,
 	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	w.clients = newClients(w.timeout, w.tlsFiles, w.policy)
+	return w
 }
 
+// Run reads the queue with a pool of w.concurrency delivery goroutines until
+// ctx is canceled, then drains in-flight deliveries and closes the consumer.
 func (w *Worker) Run(ctx context.Context) error {
-	_ = w.rdb.XGroupCreateMkStream(ctx, w.stream, w.group, "0").Err()
+	// Deliveries outlive ctx so that a shutdown does not abort requests that
+	// are already on the wire; drain cancels them after drainTimeout.
+	deliveryCtx, cancelDeliveries := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelDeliveries()
 
-	w.log.Info(ctx, "webhook worker started")
-	for {
-		select {
-		case <-ctx.Done():
-			w.log.Info(ctx, "webhook worker stopped")
-			return ctx.Err()
-		default:
-		}
-
-		streams, err := w.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
-			Group:    w.group,
-			Consumer: w.consumer,
-			Streams:  []string{w.stream, ">"},
-			Count:    1,
-			Block:    2 * time.Second,
-		}).Result()
-		if err == redis.Nil || err == nil && len(streams) == 0 {
+	w.batches = newBatcher(deliveryCtx, w.flushBatch)
+
+	jobs := make(chan queue.Message)
+	var wg sync.WaitGroup
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range jobs {
+				w.process(deliveryCtx, msg)
+			}
+		}()
+	}
+
+	var verifier sync.WaitGroup
+	if w.verifications != nil {
+		verifier.Add(1)
+		go func() {
+			defer verifier.Done()
+			w.verifyLoop(ctx)
+		}()
+	}
+
+	w.log.Info(ctx, "webhook worker started", "concurrency", w.concurrency)
+	w.consume(ctx, jobs)
+	close(jobs)
+	verifier.Wait()
+
+	w.drain(ctx, &wg, cancelDeliveries)
+	if err := w.queue.Close(deliveryCtx); err != nil {
+		w.log.Error(ctx, "webhook queue consumer close failed", "error", err)
+	}
+
+	w.log.Info(ctx, "webhook worker stopped")
+	return ctx.Err()
+}
+
+// consume feeds jobs with messages until ctx is canceled. It only receives
+// as many messages as there are idle delivery goroutines, so nothing sits in
+// memory unowned.
+func (w *Worker) consume(ctx context.Context, jobs chan<- queue.Message) {
+	if w.heartbeat != nil {
+		defer w.heartbeat.Stop()
+	}
+	for ctx.Err() == nil {
+		free := int64(w.concurrency) - w.busy.Load()
+		if free <= 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(50 * time.Millisecond):
+			}
 			continue
 		}
-		if err != nil {
-			w.log.Error(ctx, "redis read failed", "error", err)
-			continue
+		// Beat only with a free delivery goroutine: a pool stuck in
+		// deliveries is not progress.
+		if w.heartbeat != nil {
+			w.heartbeat.Beat()
 		}
 
-		for _, st := range streams {
-			for _, msg := range st.Messages {
-				if err := w.handle(ctx, msg.Values); err == nil {
-					_ = w.rdb.XAck(ctx, w.stream, w.group, msg.ID).Err()
-				} else {
-					w.log.Error(ctx, "webhook handle failed", "error", err)
+		msgs, err := w.queue.Receive(ctx, int(free))
+		if err != nil {
+			if ctx.Err() == nil {
+				w.log.Error(ctx, "webhook queue receive failed", "error", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Second):
 				}
 			}
+			continue
 		}
+
+		w.enqueue(ctx, jobs, msgs)
 	}
 }
 
-func (w *Worker) handle(ctx context.Context, values map[string]any) error {
-	body, ok := values["body"].(string)
-	if !ok {
-		return fmt.Errorf("missing body")
+// enqueue hands msgs to the delivery pool. Messages not handed over before
+// ctx is canceled are not acknowledged and get redelivered by the queue.
+func (w *Worker) enqueue(ctx context.Context, jobs chan<- queue.Message, msgs []queue.Message) {
+	for _, msg := range msgs {
+		w.busy.Add(1)
+		select {
+		case jobs <- msg:
+		case <-ctx.Done():
+			w.busy.Add(-1)
+			return
+		}
 	}
+}
+
+// process handles msg and acknowledges it once every delivery succeeded.
+// Deliveries to batching subscriptions may complete after process returns,
+// so that waiting batches do not hold delivery goroutines; the batcher
+// bounds how many of them can wait per subscription.
+//
+// The message is handled in a span continuing the trace of the request that
+// enqueued the event, which ends once all its deliveries are done.
+func (w *Worker) process(ctx context.Context, msg queue.Message) {
+	defer w.busy.Add(-1)
+
+	ctx, span := tracer.Start(tracing.Extract(ctx, msg.TraceContext), "webhook process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingMessageID(msg.ID),
+			attribute.Int64("outbox.id", msg.OutboxID),
+			attribute.String("event.type", msg.EventType),
+			attribute.Int("messaging.deliveries", msg.Deliveries),
+		),
+	)
+
+	w.handle(ctx, msg.Item, func(err error) {
+		defer span.End()
+		if errors.Is(err, errDeliveryDeferred) {
+			if err := w.queue.Retry(ctx, msg.ID, deferDelay); err != nil {
+				w.log.Error(ctx, "webhook retry failed", "error", err, "message_id", msg.ID)
+			}
+			return
+		}
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			if !errors.Is(err, errDeliveryFailed) {
+				w.log.Error(ctx, "webhook handle failed", "error", err, "message_id", msg.ID)
+			}
+			return
+		}
+		if err := w.queue.Ack(ctx, msg.ID); err != nil {
+			w.log.Error(ctx, "webhook ack failed", "error", err, "message_id", msg.ID)
+		}
+	})
+}
+
+// drain waits for in-flight deliveries and flushes open batches, canceling
+// them once drainTimeout has passed.
+func (w *Worker) drain(ctx context.Context, wg *sync.WaitGroup, cancel context.CancelFunc) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		w.batches.close()
+		close(done)
+	}()
 
-	outboxIDStr, ok := values["outbox_id"].(string)
-	if !ok {
-		return fmt.Errorf("missing outbox_id")
+	select {
+	case <-done:
+	case <-time.After(w.drainTimeout):
+		w.log.Error(ctx, "webhook drain timed out, canceling in-flight deliveries", "in_flight", w.busy.Load())
+		cancel()
+		<-done
 	}
-	outboxID, err := strconv.ParseInt(outboxIDStr, 10, 64)
+}
+
+// handle delivers the message to every subscription that has not received it
+// yet and calls done once all deliveries have finished. done gets an error if
+// any delivery failed, leaving the message pending so that only the failed
+// deliveries are retried, or errDeliveryDeferred if the only failures were
+// deliveries held back by their destination. handle returns once the direct
+// deliveries are done; batched ones report to done when their batch is
+// flushed.
+func (w *Worker) handle(ctx context.Context, it queue.Item, done func(error)) {
+	outboxID := it.OutboxID
+
+	ev, err := decodeEvent(it.EventType, it.Payload, outboxID)
+	if err != nil {
+		done(err)
+		return
+	}
+
+	subs, err := w.subs.list(ctx)
 	if err != nil {
-		return fmt.Errorf("invalid outbox_id")
+		if subs == nil {
+			done(fmt.Errorf("list subscriptions: %w", err))
+			return
+		}
+		w.log.Error(ctx, "webhook subscriptions refresh failed, using cached list", "error", err)
+	}
+	if len(subs) == 0 {
+		done(nil)
+		return
+	}
+
+	out := newOutcome(len(subs), done)
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		if sub.Batching.Enabled() {
+			w.addToBatch(ctx, sub, ev, outboxID, out.report)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := w.deliver(ctx, sub, ev, outboxID)
+			if err != nil && !errors.Is(err, breaker.ErrOpen) && !errors.Is(err, errDestinationBusy) {
+				w.log.Error(ctx, "webhook delivery failed", "error", err, "subscription_id", sub.ID, "outbox_id", outboxID)
+			}
+			out.report(err)
+		}()
+	}
+	wg.Wait()
+}
+
+// outcome collects the results of delivering one message to its
+// subscriptions and calls done after the last one.
+type outcome struct {
+	total     int
+	remaining atomic.Int64
+	failed    atomic.Int64
+	deferred  atomic.Int64
+	done      func(error)
+}
+
+func newOutcome(n int, done func(error)) *outcome {
+	o := &outcome{total: n, done: done}
+	o.remaining.Store(int64(n))
+	return o
+}
+
+func (o *outcome) report(err error) {
+	switch {
+	case err == nil:
+	case errors.Is(err, breaker.ErrOpen), errors.Is(err, errDestinationBusy):
+		o.deferred.Add(1)
+	default:
+		o.failed.Add(1)
+	}
+	if o.remaining.Add(-1) > 0 {
+		return
+	}
+	if n := o.failed.Load(); n > 0 {
+		o.done(fmt.Errorf("%d of %d: %w", n, o.total, errDeliveryFailed))
+		return
 	}
+	if n := o.deferred.Load(); n > 0 {
+		o.done(fmt.Errorf("%d of %d: %w", n, o.total, errDeliveryDeferred))
+		return
+	}
+	o.done(nil)
+}
+
+func (w *Worker) delivered(ctx context.Context, outboxID, subID int64) (bool, error) {
+	if w.dedupe == nil {
+		return false, nil
+	}
+	return w.dedupe.Delivered(ctx, outboxID, subID)
+}
+
+func (w *Worker) markDelivered(ctx context.Context, outboxID, subID int64) {
+	if w.dedupe == nil {
+		return
+	}
+	if err := w.dedupe.MarkDelivered(ctx, outboxID, subID); err != nil {
+		w.log.Error(ctx, "webhook dedupe mark failed", "error", err, "outbox_id", outboxID, "subscription_id", subID)
+	}
+}
 
-	dedupeKey := fmt.Sprintf("processed:%d", outboxID)
-	okNX, err := w.rdb.SetNX(ctx, dedupeKey, "1", w.dedupeTTL).Result()
+func (w *Worker) deliver(ctx context.Context, sub webhooks.Subscription, ev events.Envelope, outboxID int64) error {
+	seen, err := w.delivered(ctx, outboxID, sub.ID)
 	if err != nil {
 		return err
 	}
-	if !okNX {
+	if seen {
 		return nil
 	}
 
-	var ev incidents.CheckCompleted
-	if err := json.Unmarshal([]byte(body), &ev); err != nil {
+	start := time.Now()
+	body, headers, err := sub.Encode(ev, w.contentMode)
+	if err == nil {
+		headers.Set("X-Event-Type", ev.Type)
+		headers.Set("Idempotency-Key", idempotencyKey(ev))
+		_, err = w.send(ctx, sub, body, headers)
+	}
+	w.observe(deliveryOutcome(err), time.Since(start))
+	w.record(ctx, sub, ev, outboxID, err)
+	if err != nil {
 		return err
 	}
+	w.markDelivered(ctx, outboxID, sub.ID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.targetURL, bytes.NewBufferString(body))
+	w.log.Info(ctx, "webhook sent", "event_id", ev.ID, "event_type", ev.Type, "outbox_id", outboxID, "subscription_id", sub.ID)
+	return nil
+}
+
+// idempotencyKey returns the Idempotency-Key header for ev. Check results
+// keep the check id their receivers deduplicated on before events were
+// wrapped in CloudEvents; every other event uses its id.
+func idempotencyKey(ev events.Envelope) string {
+	if ev.Type == incidents.EventTypeCheckCompleted && ev.Subject != "" {
+		return ev.Subject
+	}
+	return ev.ID
+}
+
+// addToBatch queues ev for a batching subscription unless it was already
+// delivered there or the subscription has too many events pending.
+func (w *Worker) addToBatch(ctx context.Context, sub webhooks.Subscription, ev events.Envelope, outboxID int64, done func(error)) {
+	seen, err := w.delivered(ctx, outboxID, sub.ID)
 	if err != nil {
-		return err
+		w.log.Error(ctx, "webhook delivery failed", "error", err, "subscription_id", sub.ID, "outbox_id", outboxID)
+		done(err)
+		return
+	}
+	if seen {
+		done(nil)
+		return
+	}
+
+	if !w.batches.add(sub, batchItem{ev: ev, outboxID: outboxID, link: trace.LinkFromContext(ctx), done: done}) {
+		// The receiver is not keeping up; the message is retried later
+		// like one held back by the destination's limits.
+		w.observe(deliveryOutcome(errDestinationBusy), 0)
+		done(errDestinationBusy)
+	}
+}
+
+// flushBatch sends items to sub in one request. Each event's id doubles as
+// its idempotency key. A 2xx response delivers every item except those the
+// receiver lists as failed (see batchResponse); anything else fails them all.
+func (w *Worker) flushBatch(ctx context.Context, sub webhooks.Subscription, items []batchItem) {
+	evs := make([]events.Envelope, 0, len(items))
+	links := make([]trace.Link, 0, len(items))
+	for _, it := range items {
+		evs = append(evs, it.ev)
+		links = append(links, it.link)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Event-Type", "location_check")
-	req.Header.Set("Idempotency-Key", fmt.Sprintf("%d", ev.CheckID))
 
-	resp, err := w.httpClient.Do(req)
+	// The batch carries events from several traces; its span links to the
+	// span handling each of them.
+	ctx, span := tracer.Start(ctx, "webhook batch",
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			attribute.Int64("webhook.subscription_id", sub.ID),
+			attribute.Int("messaging.batch.message_count", len(items)),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	var failed map[string]string
+	body, headers, err := sub.EncodeBatch(evs)
+	if err == nil {
+		headers.Set("X-Batch-Size", strconv.Itoa(len(items)))
+		var resp []byte
+		if resp, err = w.send(ctx, sub, body, headers); err == nil {
+			failed = parseBatchFailures(resp)
+		}
+	}
+	elapsed := time.Since(start)
+
 	if err != nil {
-		return err
+		span.SetStatus(codes.Error, err.Error())
+		if !errors.Is(err, breaker.ErrOpen) && !errors.Is(err, errDestinationBusy) {
+			w.log.Error(ctx, "webhook batch delivery failed", "error", err, "subscription_id", sub.ID, "size", len(items))
+		}
+		for _, it := range items {
+			w.observe(deliveryOutcome(err), elapsed)
+			w.record(ctx, sub, it.ev, it.outboxID, err)
+			it.done(err)
+		}
+		return
+	}
+
+	for _, it := range items {
+		if reason, ok := failed[it.ev.ID]; ok {
+			err := fmt.Errorf("rejected by receiver: %s", reason)
+			w.log.Error(ctx, "webhook batch item rejected", "error", reason, "event_id", it.ev.ID, "outbox_id", it.outboxID, "subscription_id", sub.ID)
+			w.observe("rejected", elapsed)
+			w.record(ctx, sub, it.ev, it.outboxID, err)
+			it.done(err)
+			continue
+		}
+		w.observe(deliveryOutcome(nil), elapsed)
+		w.record(ctx, sub, it.ev, it.outboxID, nil)
+		w.markDelivered(ctx, it.outboxID, sub.ID)
+		it.done(nil)
+	}
+
+	w.log.Info(ctx, "webhook batch sent", "subscription_id", sub.ID, "size", len(items), "rejected", len(failed))
+}
+
+// deliveryOutcome labels a delivery attempt for metrics. Attempts held back
+// by the destination's breaker or limits are told apart from failed sends.
+func deliveryOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "delivered"
+	case errors.Is(err, breaker.ErrOpen):
+		return "breaker_open"
+	case errors.Is(err, errDestinationBusy):
+		return "busy"
+	default:
+		return "failed"
+	}
+}
+
+func (w *Worker) observe(outcome string, d time.Duration) {
+	if w.metrics != nil {
+		w.metrics.ObserveWebhookDelivery(outcome, d)
+	}
+}
+
+// record stores the outcome of an attempt to deliver ev to sub.
+func (w *Worker) record(ctx context.Context, sub webhooks.Subscription, ev events.Envelope, outboxID int64, deliveryErr error) {
+	if w.deliveries == nil {
+		return
+	}
+
+	a := webhooks.DeliveryAttempt{
+		OutboxID:       outboxID,
+		SubscriptionID: sub.ID,
+		EventID:        ev.ID,
+		EventType:      ev.Type,
+	}
+	if deliveryErr != nil {
+		a.Err = deliveryErr.Error()
+	}
+	if err := w.deliveries.Record(ctx, a); err != nil {
+		w.log.Error(ctx, "webhook delivery record failed", "error", err, "outbox_id", outboxID, "subscription_id", sub.ID)
+	}
+}
+
+// decodeEvent parses the envelope stored in the outbox. Rows written before
+// events were wrapped in CloudEvents carry a bare CheckCompleted with Go
+// field names and are wrapped here with an id derived from the outbox id.
+func decodeEvent(eventType, body string, outboxID int64) (events.Envelope, error) {
+	if eventType != legacyCheckEventType {
+		return events.Decode([]byte(body))
+	}
+
+	var legacy struct {
+		CheckID     int64
+		UserID      string
+		Point       struct{ Lat, Lon float64 }
+		IncidentIDs []int64
+		OccurredAt  time.Time
+	}
+	if err := json.Unmarshal([]byte(body), &legacy); err != nil {
+		return events.Envelope{}, err
+	}
+
+	ev, err := events.New(
+		incidents.EventTypeCheckCompleted,
+		events.Schema(incidents.EventTypeCheckCompleted, incidents.CheckCompletedVersion),
+		strconv.FormatInt(legacy.CheckID, 10),
+		legacy.OccurredAt,
+		incidents.CheckCompleted{
+			CheckID:     legacy.CheckID,
+			UserID:      legacy.UserID,
+			Point:       incidents.Point{Lat: legacy.Point.Lat, Lon: legacy.Point.Lon},
+			IncidentIDs: legacy.IncidentIDs,
+			OccurredAt:  legacy.OccurredAt,
+		},
+	)
+	if err != nil {
+		return events.Envelope{}, err
+	}
+	ev.ID = fmt.Sprintf("outbox-%d", outboxID)
+	return ev, nil
+}
+
+// send posts body to the subscription's URL within the destination's limits
+// and returns the response body. Transport errors, 5xx and 429 responses
+// count against the destination's breaker; other non-2xx responses fail the
+// delivery only. The request carries the trace context of ctx in a
+// traceparent header.
+func (w *Worker) send(ctx context.Context, sub webhooks.Subscription, body []byte, headers http.Header) (_ []byte, err error) {
+	ctx, span := tracer.Start(ctx, "webhook send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodPost,
+			attribute.Int64("webhook.subscription_id", sub.ID),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	trusted := sub.ID == staticSubscriptionID
+	if w.policy != nil && !trusted {
+		if err := w.policy.CheckURL(sub.URL); err != nil {
+			return nil, err
+		}
+	}
+
+	dst := w.destinations.get(sub.URL)
+	span.SetAttributes(semconv.ServerAddress(dst.key))
+	client, err := w.clients.get(dst.key, sub.TLS, trusted)
+	if err != nil {
+		return nil, fmt.Errorf("destination %s: %w", dst.key, err)
+	}
+
+	release, err := w.destinations.acquire(ctx, dst)
+	if err != nil {
+		return nil, fmt.Errorf("destination %s: %w", dst.key, err)
+	}
+
+	healthy := false
+	defer func() { release(healthy) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		healthy = true
+		return nil, err
+	}
+	for k, vs := range headers {
+		req.Header[k] = vs
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// A blocked address says nothing about the destination's health.
+		healthy = errors.Is(err, urlpolicy.ErrBlocked)
+		return nil, err
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
 	_, _ = io.Copy(io.Discard, resp.Body)
 
+	healthy = resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("webhook non-2xx: %d", resp.StatusCode)
+		return nil, fmt.Errorf("webhook non-2xx: %d", resp.StatusCode)
 	}
-
-	w.log.Info(ctx, "webhook sent", "check_id", ev.CheckID, "outbox_id", outboxID)
-	return nil
+	return respBody, nil
 }
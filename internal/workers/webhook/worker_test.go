@@ -0,0 +1,174 @@
+package webhookworker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m1ll3r1337/geo-notifications-service/internal/domain/incidents"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/events"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/breaker"
+	"github.com/m1ll3r1337/geo-notifications-service/internal/platform/queue"
+)
+
+func testMessage(t *testing.T, id string) queue.Message {
+	t.Helper()
+	ev, err := events.New("incident.created", events.Schema("incident.created", 1), "incident/1", time.Now(), map[string]int{"id": 1})
+	if err != nil {
+		t.Fatalf("new event: %v", err)
+	}
+	payload, _ := json.Marshal(ev)
+	return queue.Message{ID: id, Item: queue.Item{EventType: ev.Type, Payload: string(payload), OutboxID: 1}, Deliveries: 1}
+}
+
+func TestWorker_RetriesDeliveriesHeldBackByBreaker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	q := &ackConsumer{}
+	w := New(q, srv.URL, nopLogger{}, WithDestinationLimits(DestinationLimits{
+		Breaker: breaker.Config{FailureThreshold: 1, OpenTimeout: time.Minute},
+	}))
+
+	// A failed send counts as a delivery: the message is left to the queue.
+	w.busy.Add(1)
+	w.process(context.Background(), testMessage(t, "1"))
+	if len(q.acked) != 0 || len(q.retried) != 0 {
+		t.Fatalf("expected the failed message to stay pending, acked %v, retried %v", q.acked, q.retried)
+	}
+
+	// With the breaker open nothing is sent, so the message is retried
+	// without counting the delivery.
+	w.busy.Add(1)
+	w.process(context.Background(), testMessage(t, "2"))
+	if len(q.acked) != 0 || len(q.retried) != 1 || q.retried[0] != "2" {
+		t.Fatalf("expected message 2 to be retried, acked %v, retried %v", q.acked, q.retried)
+	}
+}
+
+// memoryConsumer records the acknowledged messages of a queue.Memory.
+type memoryConsumer struct {
+	*queue.Memory
+
+	mu    sync.Mutex
+	acked []string
+}
+
+func (c *memoryConsumer) Ack(ctx context.Context, id string) error {
+	c.mu.Lock()
+	c.acked = append(c.acked, id)
+	c.mu.Unlock()
+	return c.Memory.Ack(ctx, id)
+}
+
+func (c *memoryConsumer) ackCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.acked)
+}
+
+func TestWorker_PoolSizeAndDrain(t *testing.T) {
+	const concurrency = 3
+
+	var (
+		inFlight, peak atomic.Int64
+		arrived        = make(chan struct{}, 10)
+		release        = make(chan struct{})
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		arrived <- struct{}{}
+		<-release
+		inFlight.Add(-1)
+	}))
+	defer srv.Close()
+
+	q := &memoryConsumer{Memory: queue.NewMemory(queue.Redelivery{Visibility: time.Minute}, 20*time.Millisecond, nopLogger{})}
+	var msgs []queue.Item
+	for i := range 5 {
+		msgs = append(msgs, queue.Item{EventType: legacyCheckEventType, Payload: `{"CheckID":1}`, OutboxID: int64(i + 1)})
+	}
+	if err := q.Publish(context.Background(), msgs); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	w := New(q, srv.URL, nopLogger{},
+		WithConcurrency(concurrency),
+		WithDrainTimeout(time.Minute),
+		WithDestinationLimits(DestinationLimits{Concurrency: 16}),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		_ = w.Run(ctx)
+		close(stopped)
+	}()
+
+	for range concurrency {
+		<-arrived
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := peak.Load(); n != concurrency {
+		t.Fatalf("expected %d deliveries in parallel, got %d", concurrency, n)
+	}
+
+	// Shutting down waits for the deliveries already on the wire and acks
+	// them; the messages not received yet stay in the queue.
+	cancel()
+	select {
+	case <-stopped:
+		t.Fatal("Run returned before in-flight deliveries finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+
+	if n := q.ackCount(); n != concurrency {
+		t.Fatalf("expected the %d in-flight messages to be acked, got %d", concurrency, n)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	legacy, err := decodeEvent(legacyCheckEventType, `{"CheckID":17,"UserID":"user1"}`, 5)
+	if err != nil {
+		t.Fatalf("decode legacy event: %v", err)
+	}
+	check, err := events.New(incidents.EventTypeCheckCompleted, "", "17", time.Now(), nil)
+	if err != nil {
+		t.Fatalf("new event: %v", err)
+	}
+	created, err := events.New("incident.created", "", "incident/1", time.Now(), nil)
+	if err != nil {
+		t.Fatalf("new event: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		ev   events.Envelope
+		want string
+	}{
+		{"legacy check row", legacy, "17"},
+		{"check event", check, "17"},
+		{"incident event", created, created.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := idempotencyKey(tt.ev); got != tt.want {
+				t.Fatalf("idempotencyKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+ALTER TABLE incidents
+    ADD COLUMN IF NOT EXISTS severity TEXT NOT NULL DEFAULT 'medium'
+        CHECK (severity IN ('low','medium','high','critical'));
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id           BIGSERIAL PRIMARY KEY,
+    url          TEXT NOT NULL,
+    payload_mode TEXT NOT NULL DEFAULT 'full' CHECK (payload_mode IN ('full','slim')),
+    active       BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_active ON webhook_subscriptions(active) WHERE active = TRUE;
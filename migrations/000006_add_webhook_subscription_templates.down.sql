@@ -0,0 +1,4 @@
+ALTER TABLE webhook_subscriptions
+    DROP COLUMN IF EXISTS body_template,
+    DROP COLUMN IF EXISTS header_templates,
+    DROP COLUMN IF EXISTS content_type;
@@ -0,0 +1,4 @@
+ALTER TABLE webhook_subscriptions
+    ADD COLUMN IF NOT EXISTS body_template    TEXT NULL,
+    ADD COLUMN IF NOT EXISTS header_templates JSONB NOT NULL DEFAULT '{}'::jsonb,
+    ADD COLUMN IF NOT EXISTS content_type     TEXT NULL;
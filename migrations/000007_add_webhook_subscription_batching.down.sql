@@ -0,0 +1,3 @@
+ALTER TABLE webhook_subscriptions
+    DROP COLUMN IF EXISTS batch_max_items,
+    DROP COLUMN IF EXISTS batch_max_wait_ms;
@@ -0,0 +1,3 @@
+ALTER TABLE webhook_subscriptions
+    ADD COLUMN IF NOT EXISTS batch_max_items   INT NOT NULL DEFAULT 0 CHECK (batch_max_items >= 0),
+    ADD COLUMN IF NOT EXISTS batch_max_wait_ms INT NOT NULL DEFAULT 0 CHECK (batch_max_wait_ms >= 0);
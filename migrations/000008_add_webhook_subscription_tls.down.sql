@@ -0,0 +1,4 @@
+ALTER TABLE webhook_subscriptions
+    DROP COLUMN IF EXISTS tls_ca_cert,
+    DROP COLUMN IF EXISTS tls_client_cert,
+    DROP COLUMN IF EXISTS tls_client_key;
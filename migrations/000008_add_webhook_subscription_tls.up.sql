@@ -0,0 +1,4 @@
+ALTER TABLE webhook_subscriptions
+    ADD COLUMN IF NOT EXISTS tls_ca_cert     TEXT NULL,
+    ADD COLUMN IF NOT EXISTS tls_client_cert TEXT NULL,
+    ADD COLUMN IF NOT EXISTS tls_client_key  TEXT NULL;
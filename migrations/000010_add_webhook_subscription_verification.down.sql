@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_webhook_subscriptions_verification;
+
+ALTER TABLE webhook_subscriptions
+    DROP COLUMN IF EXISTS verification_status,
+    DROP COLUMN IF EXISTS verification_attempts,
+    DROP COLUMN IF EXISTS verification_error,
+    DROP COLUMN IF EXISTS verified_at,
+    DROP COLUMN IF EXISTS next_verification_at;
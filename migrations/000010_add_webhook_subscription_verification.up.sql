@@ -0,0 +1,13 @@
+-- Existing subscriptions were created before verification and stay verified.
+ALTER TABLE webhook_subscriptions
+    ADD COLUMN IF NOT EXISTS verification_status TEXT NOT NULL DEFAULT 'verified'
+        CHECK (verification_status IN ('pending_verification','verified','verification_failed')),
+    ADD COLUMN IF NOT EXISTS verification_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS verification_error   TEXT NULL,
+    ADD COLUMN IF NOT EXISTS verified_at          TIMESTAMPTZ NULL,
+    ADD COLUMN IF NOT EXISTS next_verification_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+ALTER TABLE webhook_subscriptions ALTER COLUMN verification_status SET DEFAULT 'pending_verification';
+
+CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_verification
+    ON webhook_subscriptions(verification_status, next_verification_at) WHERE active = TRUE;
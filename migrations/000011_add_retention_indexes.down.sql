@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_updated_at;
+DROP INDEX IF EXISTS idx_webhook_outbox_status_created_at;
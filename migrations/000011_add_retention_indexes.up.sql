@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_webhook_outbox_status_created_at ON webhook_outbox(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_updated_at ON webhook_deliveries(updated_at);
@@ -0,0 +1,13 @@
+-- Queue between the outbox relay and the webhook worker when Postgres is
+-- used instead of Redis Streams (GEO_QUEUE_BACKEND=postgres).
+CREATE TABLE IF NOT EXISTS event_queue (
+    id          BIGSERIAL PRIMARY KEY,
+    event_type  TEXT NOT NULL,
+    payload     TEXT NOT NULL,
+    outbox_id   BIGINT NOT NULL,
+    deliveries  INT NOT NULL DEFAULT 0,
+    visible_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_queue_visible_at ON event_queue(visible_at, id);
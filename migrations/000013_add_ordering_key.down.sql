@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_event_queue_ordering_key;
+ALTER TABLE event_queue DROP COLUMN IF EXISTS ordering_key;
+
+DROP INDEX IF EXISTS idx_webhook_outbox_pending_ordering_key;
+ALTER TABLE webhook_outbox DROP COLUMN IF EXISTS ordering_key;
@@ -0,0 +1,8 @@
+-- Events with the same ordering key are delivered in id order.
+ALTER TABLE webhook_outbox ADD COLUMN IF NOT EXISTS ordering_key TEXT NULL;
+CREATE INDEX IF NOT EXISTS idx_webhook_outbox_pending_ordering_key
+    ON webhook_outbox(ordering_key, id) WHERE status = 'pending' AND ordering_key IS NOT NULL;
+
+ALTER TABLE event_queue ADD COLUMN IF NOT EXISTS ordering_key TEXT NULL;
+CREATE INDEX IF NOT EXISTS idx_event_queue_ordering_key
+    ON event_queue(ordering_key, id) WHERE ordering_key IS NOT NULL;
@@ -0,0 +1,9 @@
+-- One row per group of singleton jobs. token grows by one every time the
+-- lease changes hands and fences writes made by a deposed holder.
+CREATE TABLE IF NOT EXISTS leader_leases (
+    name        TEXT PRIMARY KEY,
+    holder      TEXT NOT NULL,
+    token       BIGINT NOT NULL,
+    acquired_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at  TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,2 @@
+ALTER TABLE event_queue DROP COLUMN IF EXISTS trace_context;
+ALTER TABLE webhook_outbox DROP COLUMN IF EXISTS trace_context;
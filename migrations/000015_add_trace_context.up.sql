@@ -0,0 +1,5 @@
+-- W3C trace context of the request that enqueued an event, encoded as a
+-- query string (traceparent=...&tracestate=...), carried on to the queue so
+-- that delivery joins the originating trace.
+ALTER TABLE webhook_outbox ADD COLUMN IF NOT EXISTS trace_context TEXT NULL;
+ALTER TABLE event_queue ADD COLUMN IF NOT EXISTS trace_context TEXT NULL;
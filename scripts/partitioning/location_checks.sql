@@ -0,0 +1,46 @@
+-- Switches location_checks to daily range partitions on created_at so that
+-- the cleanup worker can drop expired days instead of deleting rows.
+--
+-- Run once, after all migrations, with the service stopped:
+--   psql "$GEO_DB_URL" -v ON_ERROR_STOP=1 -f scripts/partitioning/location_checks.sql
+--
+-- Existing rows become the partition location_checks_legacy, bounded by the
+-- end of the current UTC day; it is dropped once all of its rows expire.
+-- Daily partitions are created by the cleanup worker.
+BEGIN;
+
+-- A foreign key to a partitioned table must include the partition key;
+-- links of dropped checks are removed by the cleanup worker instead.
+ALTER TABLE location_check_incidents DROP CONSTRAINT IF EXISTS location_check_incidents_check_id_fkey;
+
+ALTER TABLE location_checks RENAME TO location_checks_legacy;
+ALTER TABLE location_checks_legacy RENAME CONSTRAINT location_checks_pkey TO location_checks_legacy_pkey;
+ALTER TABLE location_checks_legacy ALTER COLUMN id DROP DEFAULT;
+ALTER INDEX idx_location_checks_created_at RENAME TO idx_location_checks_legacy_created_at;
+ALTER INDEX idx_location_checks_user_id_created_at RENAME TO idx_location_checks_legacy_user_id_created_at;
+
+CREATE TABLE location_checks (
+    id BIGINT NOT NULL DEFAULT nextval('location_checks_id_seq'),
+    user_id TEXT NOT NULL,
+    location GEOGRAPHY(Point) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+ALTER SEQUENCE location_checks_id_seq OWNED BY location_checks.id;
+
+CREATE INDEX idx_location_checks_created_at ON location_checks(created_at);
+CREATE INDEX idx_location_checks_user_id_created_at ON location_checks(user_id, created_at);
+
+DO $$
+BEGIN
+    EXECUTE format(
+        'ALTER TABLE location_checks ATTACH PARTITION location_checks_legacy FOR VALUES FROM (MINVALUE) TO (%L)',
+        (date_trunc('day', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC') + INTERVAL '1 day'
+    );
+END $$;
+
+-- Catches rows outside any daily partition; it should stay empty.
+CREATE TABLE location_checks_default PARTITION OF location_checks DEFAULT;
+
+COMMIT;
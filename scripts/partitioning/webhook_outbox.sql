@@ -0,0 +1,56 @@
+-- Switches webhook_outbox to daily range partitions on created_at so that
+-- the cleanup worker can drop expired days instead of deleting rows. A
+-- partition is only dropped when it has no pending events left.
+--
+-- Run once, after all migrations, with the service stopped:
+--   psql "$GEO_DB_URL" -v ON_ERROR_STOP=1 -f scripts/partitioning/webhook_outbox.sql
+--
+-- Existing rows become the partition webhook_outbox_legacy, bounded by the
+-- end of the current UTC day. Daily partitions are created by the cleanup
+-- worker.
+BEGIN;
+
+ALTER TABLE webhook_outbox RENAME TO webhook_outbox_legacy;
+ALTER TABLE webhook_outbox_legacy RENAME CONSTRAINT webhook_outbox_pkey TO webhook_outbox_legacy_pkey;
+ALTER TABLE webhook_outbox_legacy ALTER COLUMN id DROP DEFAULT;
+ALTER INDEX idx_webhook_outbox_pending RENAME TO idx_webhook_outbox_legacy_pending;
+ALTER INDEX idx_outbox_processing_until RENAME TO idx_outbox_legacy_processing_until;
+ALTER INDEX idx_webhook_outbox_status_created_at RENAME TO idx_webhook_outbox_legacy_status_created_at;
+ALTER INDEX idx_webhook_outbox_pending_ordering_key RENAME TO idx_webhook_outbox_legacy_pending_ordering_key;
+
+CREATE TABLE webhook_outbox (
+    id              BIGINT NOT NULL DEFAULT nextval('webhook_outbox_id_seq'),
+    event_type      TEXT NOT NULL,
+    payload         JSONB NOT NULL,
+    status          TEXT NOT NULL CHECK (status IN ('pending','dispatched','dead')),
+    attempts        INT NOT NULL DEFAULT 0,
+    processing_until TIMESTAMPTZ NULL,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error      TEXT NULL,
+    ordering_key    TEXT NULL,
+    trace_context   TEXT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+ALTER SEQUENCE webhook_outbox_id_seq OWNED BY webhook_outbox.id;
+
+CREATE INDEX idx_webhook_outbox_pending ON webhook_outbox(status, next_attempt_at);
+CREATE INDEX idx_outbox_processing_until ON webhook_outbox(status, processing_until);
+CREATE INDEX idx_webhook_outbox_status_created_at ON webhook_outbox(status, created_at);
+CREATE INDEX idx_webhook_outbox_pending_ordering_key ON webhook_outbox(ordering_key, id)
+    WHERE status = 'pending' AND ordering_key IS NOT NULL;
+
+DO $$
+BEGIN
+    EXECUTE format(
+        'ALTER TABLE webhook_outbox ATTACH PARTITION webhook_outbox_legacy FOR VALUES FROM (MINVALUE) TO (%L)',
+        (date_trunc('day', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC') + INTERVAL '1 day'
+    );
+END $$;
+
+-- Catches rows outside any daily partition; it should stay empty.
+CREATE TABLE webhook_outbox_default PARTITION OF webhook_outbox DEFAULT;
+
+COMMIT;